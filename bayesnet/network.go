@@ -0,0 +1,408 @@
+// Package bayesnet builds directed graphical models, which can be sampled
+// and compiled to factor graphs for inference.
+package bayesnet
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"math"
+	"math/rand"
+)
+
+// Create a new, empty Bayesian network
+func NewNetwork() *Network {
+	return &Network{
+		nodes: make(map[variable.RandomVariable]*Node),
+	}
+}
+
+// A Bayesian network: a directed acyclic graph of random variables, each with
+// a distribution conditioned on its parents. The network can be compiled to a
+// FactorGraph for inference.
+type Network struct {
+	Nodes []*Node
+	nodes map[variable.RandomVariable]*Node
+}
+
+// A random variable in a Bayesian network, and its conditional distribution
+type Node struct {
+	Var variable.RandomVariable
+
+	// The variables the distribution is conditioned on. Each is either a
+	// parent node or an observed variable holding a constant parameter.
+	Given []variable.RandomVariable
+
+	// The conditional distribution, as a factor over Var and Given
+	CPD factor.Factor
+
+	// The conditional probability table, for discrete nodes added with
+	// AddTable. Its variables are the parents followed by Var.
+	table *factor.TableFactor
+
+	// The distribution, for nodes added with AddDist
+	dist dist.Dist
+}
+
+// Add a discrete node whose distribution is given by a conditional
+// probability table. The table has one row for each joint outcome of the
+// parents, in row-major order, and each row gives the probability of each
+// outcome of the child and must sum to one.
+func (net *Network) AddTable(child *variable.DiscreteRV, parents []*variable.DiscreteRV,
+	probs []float64) *Node {
+
+	var (
+		vars  = append(append([]*variable.DiscreteRV(nil), parents...), child)
+		table = factor.NewTableFactor(vars, probs)
+		size  = child.Space().Size()
+		given = make([]variable.RandomVariable, len(parents))
+	)
+	for row := 0; row < len(probs); row += size {
+		var sum float64
+		for _, p := range probs[row : row+size] {
+			sum += p
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			panic(stats.ErrNotNormalized)
+		}
+	}
+	for i, p := range parents {
+		given[i] = p
+	}
+	return net.add(&Node{
+		Var:   child,
+		Given: given,
+		CPD:   table,
+		table: table,
+	})
+}
+
+// Add a node whose distribution is a Dist over the child, with the given
+// variables as its parameters. Parameters which are not nodes in the network
+// must be observed, and are treated as constants.
+func (net *Network) AddDist(child variable.RandomVariable, distr dist.Dist,
+	params ...variable.RandomVariable) *Node {
+
+	if distr.NumVars() != 1 || distr.NumParams() != len(params) {
+		panic(stats.ErrfFactorVarNum(distr.NumVars(), distr.NumParams(), len(params)+1))
+	}
+	var vars = append([]variable.RandomVariable{child}, params...)
+	return net.add(&Node{
+		Var:   child,
+		Given: params,
+		CPD:   factor.NewDistFactor(vars, distr),
+		dist:  distr,
+	})
+}
+
+// Add a node, replacing any existing node for the same variable
+func (net *Network) add(node *Node) *Node {
+	if old, ok := net.nodes[node.Var]; ok {
+		*old = *node
+		return old
+	}
+	net.nodes[node.Var] = node
+	net.Nodes = append(net.Nodes, node)
+	return node
+}
+
+// Get the node for a variable
+func (net Network) Node(v variable.RandomVariable) (*Node, bool) {
+	node, ok := net.nodes[v]
+	return node, ok
+}
+
+// Get the distinct parents of a node: the variables it is conditioned on
+// which are nodes in the network
+func (net Network) Parents(v variable.RandomVariable) []variable.RandomVariable {
+	var (
+		parents []variable.RandomVariable
+		given   = net.node(v).Given
+	)
+	for i, p := range given {
+		if _, ok := net.nodes[p]; ok && !containsVar(given[:i], p) {
+			parents = append(parents, p)
+		}
+	}
+	return parents
+}
+
+// Ask whether a variable is in a list
+func containsVar(vars []variable.RandomVariable, v variable.RandomVariable) bool {
+	for _, u := range vars {
+		if u == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the children of a node, in the order they were added
+func (net Network) Children(v variable.RandomVariable) []variable.RandomVariable {
+	net.node(v)
+	return net.children()[v]
+}
+
+// Index the children of every node, in the order they were added, for
+// algorithms which visit the children of many nodes
+func (net Network) children() map[variable.RandomVariable][]variable.RandomVariable {
+	var index = make(map[variable.RandomVariable][]variable.RandomVariable)
+	for _, node := range net.Nodes {
+		for _, p := range net.Parents(node.Var) {
+			index[p] = append(index[p], node.Var)
+		}
+	}
+	return index
+}
+
+// Get the node for a variable, panicking if it is not in the network
+func (net Network) node(v variable.RandomVariable) *Node {
+	node, ok := net.nodes[v]
+	if !ok {
+		panic(stats.ErrfVarNotInNetwork(v))
+	}
+	return node
+}
+
+// Check that the network is acyclic, and that every parameter which is not
+// a node is observed
+func (net Network) Validate() error {
+	_, err := net.TopologicalOrder()
+	return err
+}
+
+// Get the nodes in an order where every parent precedes its children.
+// Returns ErrGraphCycle if the network has a directed cycle.
+func (net Network) TopologicalOrder() ([]*Node, error) {
+	var (
+		order    []*Node
+		inDegree = make(map[*Node]int)
+		queue    []*Node
+		children = net.children()
+	)
+	for _, node := range net.Nodes {
+		for _, p := range node.Given {
+			if _, ok := net.nodes[p]; !ok && !variable.IsObserved(p) {
+				return nil, stats.Errorf("Parameter %v of %v is neither a node nor observed",
+					p, node.Var)
+			}
+		}
+		inDegree[node] = len(net.Parents(node.Var))
+		if inDegree[node] == 0 {
+			queue = append(queue, node)
+		}
+	}
+	for len(queue) > 0 {
+		var node = queue[0]
+		queue = queue[1:]
+		order = append(order, node)
+		for _, child := range children[node.Var] {
+			var c = net.nodes[child]
+			if inDegree[c]--; inDegree[c] == 0 {
+				queue = append(queue, c)
+			}
+		}
+	}
+	if len(order) < len(net.Nodes) {
+		return nil, stats.ErrGraphCycle
+	}
+	return order, nil
+}
+
+// Draw a joint sample of the latent nodes by ancestral sampling, visiting
+// parents before children. Observed nodes keep their values, so with
+// evidence this samples each latent node given its parents only, not from the
+// posterior.
+func (net Network) Sample() error {
+	order, err := net.TopologicalOrder()
+	if err != nil {
+		return err
+	}
+	for _, node := range order {
+		if variable.IsObserved(node.Var) {
+			continue
+		} else if err := node.sample(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sample a value for the node given the current values of its parents
+func (node Node) sample() error {
+	if node.table != nil {
+		var (
+			child    = node.Var.(*variable.DiscreteRV)
+			size     = child.Space().Size()
+			outcomes = make([]dist.Outcome, len(node.table.Vars))
+		)
+		for i, p := range node.table.Vars[:len(node.table.Vars)-1] {
+			outcomes[i] = p.Outcome()
+		}
+		var (
+			row       = node.table.Index(outcomes)
+			remaining = rand.Float64()
+		)
+		for o, p := range node.table.Values[row : row+size] {
+			if remaining -= p; remaining <= 0 || o == size-1 {
+				child.SetOutcome(dist.Outcome(o))
+				break
+			}
+		}
+		return nil
+	}
+
+	var params = make([]float64, len(node.Given))
+	for i, p := range node.Given {
+		params[i] = p.Val()
+	}
+	node.dist.SetParams(params)
+	switch d := node.dist.(type) {
+	case dist.DiscreteDist:
+		dv, ok := node.Var.(*variable.DiscreteRV)
+		if !ok {
+			return stats.ErrDiscreteOnly
+		} else if dv.IsStrict() {
+			return dv.TrySet(dv.Space().F64Value(d.Sample()))
+		}
+		dv.SetOutcome(d.Sample())
+	case dist.ContinuousDist:
+		// Strict variables report out-of-domain draws rather than panic
+		if vv, ok := node.Var.(variable.ValidatedVariable); ok && vv.IsStrict() {
+			return vv.TrySet(d.Sample())
+		}
+		node.Var.Set(d.Sample())
+	default:
+		return stats.ErrfUnsupportedDist(node.dist)
+	}
+	return nil
+}
+
+// Compile the network to a factor graph with one factor per node, so it can
+// be used for inference. The factors are the nodes' CPD factors, so the graph
+// shares the network's variables.
+func (net Network) FactorGraph() (*factor.FactorGraph, error) {
+	if err := net.Validate(); err != nil {
+		return nil, err
+	}
+	var graph = factor.NewFactorGraph()
+	for _, node := range net.Nodes {
+		graph.AddFactor(node.CPD)
+	}
+	return graph, nil
+}
+
+// Get the joint log probability of the nodes' current values
+func (net Network) LogProb() float64 {
+	var score float64
+	for _, node := range net.Nodes {
+		score += math.Log(node.CPD.Score())
+	}
+	return score
+}
+
+// Ask whether every node in x is d-separated from every node in y given the
+// nodes in z, meaning x and y are conditionally independent given z in every
+// distribution the network can represent. This uses the "Bayes ball"
+// reachability algorithm.
+func (net Network) DSeparated(x, y, z []variable.RandomVariable) bool {
+	var (
+		given     = make(map[*Node]bool)
+		ancestors = make(map[*Node]bool)
+		targets   = make(map[*Node]bool)
+		stack     []*Node
+		children  = net.children()
+	)
+	for _, v := range z {
+		var node = net.node(v)
+		given[node] = true
+		stack = append(stack, node)
+	}
+	for _, v := range y {
+		targets[net.node(v)] = true
+	}
+
+	// Find the nodes which have a descendant in z, including z itself
+	for len(stack) > 0 {
+		var node = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if ancestors[node] {
+			continue
+		}
+		ancestors[node] = true
+		for _, p := range net.Parents(node.Var) {
+			stack = append(stack, net.nodes[p])
+		}
+	}
+
+	// Follow active trails from x, tracking whether we arrived at each node
+	// from a child (moving up) or from a parent (moving down)
+	type visit struct {
+		node *Node
+		up   bool
+	}
+	var (
+		visited = make(map[visit]bool)
+		queue   []visit
+	)
+	for _, v := range x {
+		queue = append(queue, visit{node: net.node(v), up: true})
+	}
+	for len(queue) > 0 {
+		var cur = queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if visited[cur] {
+			continue
+		}
+		visited[cur] = true
+		if !given[cur.node] && targets[cur.node] {
+			return false
+		}
+		if cur.up && !given[cur.node] {
+			for _, p := range net.Parents(cur.node.Var) {
+				queue = append(queue, visit{node: net.nodes[p], up: true})
+			}
+			for _, c := range children[cur.node.Var] {
+				queue = append(queue, visit{node: net.nodes[c], up: false})
+			}
+		} else if !cur.up {
+			if !given[cur.node] {
+				for _, c := range children[cur.node.Var] {
+					queue = append(queue, visit{node: net.nodes[c], up: false})
+				}
+			}
+			if ancestors[cur.node] {
+				// A v-structure is active if the node or a descendant is given
+				for _, p := range net.Parents(cur.node.Var) {
+					queue = append(queue, visit{node: net.nodes[p], up: true})
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Get the Markov blanket of a node: its parents, its children, and its
+// children's other parents. Given its blanket, a node is independent of the
+// rest of the network.
+func (net Network) MarkovBlanket(v variable.RandomVariable) []variable.RandomVariable {
+	var (
+		blanket []variable.RandomVariable
+		seen    = map[variable.RandomVariable]bool{v: true}
+		add     = func(vars []variable.RandomVariable) {
+			for _, u := range vars {
+				if !seen[u] {
+					seen[u] = true
+					blanket = append(blanket, u)
+				}
+			}
+		}
+	)
+	add(net.Parents(v))
+	for _, c := range net.Children(v) {
+		add([]variable.RandomVariable{c})
+		add(net.Parents(c))
+	}
+	return blanket
+}
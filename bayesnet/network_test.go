@@ -0,0 +1,191 @@
+package bayesnet
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+type sprinkler struct {
+	net                          *Network
+	cloudy, sprinkler, rain, wet *variable.DiscreteRV
+}
+
+// Build the classic sprinkler network
+func newSprinkler() sprinkler {
+	var s = sprinkler{
+		net:       NewNetwork(),
+		cloudy:    variable.NewDiscreteRV(0, dist.BooleanSpace),
+		sprinkler: variable.NewDiscreteRV(0, dist.BooleanSpace),
+		rain:      variable.NewDiscreteRV(0, dist.BooleanSpace),
+		wet:       variable.NewDiscreteRV(0, dist.BooleanSpace),
+	}
+	s.net.AddTable(s.wet, []*variable.DiscreteRV{s.sprinkler, s.rain},
+		[]float64{1, 0, 0.1, 0.9, 0.1, 0.9, 0.01, 0.99})
+	s.net.AddTable(s.sprinkler, []*variable.DiscreteRV{s.cloudy}, []float64{0.5, 0.5, 0.9, 0.1})
+	s.net.AddTable(s.rain, []*variable.DiscreteRV{s.cloudy}, []float64{0.8, 0.2, 0.2, 0.8})
+	s.net.AddTable(s.cloudy, nil, []float64{0.5, 0.5})
+	return s
+}
+
+func TestNetworkStructure(t *testing.T) {
+	Convey("Given the sprinkler network", t, func() {
+		s := newSprinkler()
+
+		Convey("It is acyclic and can be ordered", func() {
+			So(s.net.Validate(), ShouldBeNil)
+			order, err := s.net.TopologicalOrder()
+			So(err, ShouldBeNil)
+			So(order[0].Var, ShouldEqual, s.cloudy)
+			So(order[3].Var, ShouldEqual, s.wet)
+		})
+
+		Convey("Parents and children are found", func() {
+			So(s.net.Parents(s.wet), ShouldResemble, []variable.RandomVariable{s.sprinkler, s.rain})
+			So(s.net.Children(s.cloudy), ShouldResemble, []variable.RandomVariable{s.sprinkler, s.rain})
+			So(func() { s.net.Parents(variable.NewDiscreteRV(0, dist.BooleanSpace)) }, ShouldPanic)
+		})
+
+		Convey("Markov blankets include co-parents", func() {
+			So(s.net.MarkovBlanket(s.sprinkler), ShouldResemble,
+				[]variable.RandomVariable{s.cloudy, s.wet, s.rain})
+			So(s.net.MarkovBlanket(s.wet), ShouldResemble,
+				[]variable.RandomVariable{s.sprinkler, s.rain})
+		})
+
+		Convey("D-separation follows the graph", func() {
+			var (
+				sp  = []variable.RandomVariable{s.sprinkler}
+				r   = []variable.RandomVariable{s.rain}
+				c   = []variable.RandomVariable{s.cloudy}
+				w   = []variable.RandomVariable{s.wet}
+				spr = []variable.RandomVariable{s.sprinkler, s.rain}
+			)
+			So(s.net.DSeparated(sp, r, nil), ShouldBeFalse)
+			So(s.net.DSeparated(sp, r, c), ShouldBeTrue)
+			So(s.net.DSeparated(sp, r, append(c, s.wet)), ShouldBeFalse)
+			So(s.net.DSeparated(c, w, nil), ShouldBeFalse)
+			So(s.net.DSeparated(c, w, spr), ShouldBeTrue)
+			So(s.net.DSeparated(w, c, spr), ShouldBeTrue)
+		})
+
+		Convey("Cycles are detected", func() {
+			s.net.AddTable(s.cloudy, []*variable.DiscreteRV{s.wet}, []float64{0.5, 0.5, 0.5, 0.5})
+			So(s.net.Validate(), ShouldEqual, stats.ErrGraphCycle)
+			_, err := s.net.FactorGraph()
+			So(err, ShouldEqual, stats.ErrGraphCycle)
+			So(s.net.Nodes, ShouldHaveLength, 4)
+		})
+
+		Convey("A parent given twice is counted once", func() {
+			s.net.AddTable(s.sprinkler, []*variable.DiscreteRV{s.cloudy, s.cloudy},
+				[]float64{0.5, 0.5, 0.9, 0.1, 0.9, 0.1, 0.5, 0.5})
+			So(s.net.Validate(), ShouldBeNil)
+			So(s.net.Parents(s.sprinkler), ShouldResemble, []variable.RandomVariable{s.cloudy})
+			So(s.net.Children(s.cloudy), ShouldResemble, []variable.RandomVariable{s.sprinkler, s.rain})
+			So(s.net.DSeparated([]variable.RandomVariable{s.sprinkler},
+				[]variable.RandomVariable{s.rain}, []variable.RandomVariable{s.cloudy}), ShouldBeTrue)
+		})
+
+		Convey("Rows must be normalized", func() {
+			So(func() {
+				s.net.AddTable(s.cloudy, nil, []float64{0.5, 0.6})
+			}, ShouldPanic)
+		})
+	})
+}
+
+func TestNetworkInference(t *testing.T) {
+	Convey("Given the sprinkler network", t, func() {
+		s := newSprinkler()
+
+		Convey("The compiled factor graph is normalized", func() {
+			graph, err := s.net.FactorGraph()
+			So(err, ShouldBeNil)
+			So(graph.Factors, ShouldHaveLength, 4)
+			_, logZ, err := factor.EnumerateMarginals(graph)
+			So(err, ShouldBeNil)
+			So(logZ, ShouldAlmostEqual, 0)
+		})
+
+		Convey("Inference on the factor graph conditions on evidence", func() {
+			graph, _ := s.net.FactorGraph()
+			s.wet.Observe(1)
+			marginals, logZ, err := factor.EnumerateMarginals(graph)
+			So(err, ShouldBeNil)
+			So(logZ, ShouldAlmostEqual, math.Log(0.6471), 1e-4)
+			So(marginals[2].Value(0, 1)+marginals[2].Value(1, 1), ShouldAlmostEqual, 0.708, 1e-3)
+		})
+
+		Convey("Ancestral samples match the marginals", func() {
+			var rain, wet float64
+			const n = 20000
+			for i := 0; i < n; i++ {
+				So(s.net.Sample(), ShouldBeNil)
+				rain += s.rain.Val()
+				wet += s.wet.Val()
+			}
+			So(rain/n, ShouldAlmostEqual, 0.5, 0.02)
+			So(wet/n, ShouldAlmostEqual, 0.6471, 0.02)
+		})
+
+		Convey("Observed nodes are not sampled", func() {
+			s.cloudy.Observe(1)
+			for i := 0; i < 10; i++ {
+				So(s.net.Sample(), ShouldBeNil)
+				So(s.cloudy.Val(), ShouldEqual, 1)
+			}
+		})
+	})
+
+	Convey("Given a network with continuous nodes", t, func() {
+		var (
+			net   = NewNetwork()
+			mu    = variable.NewContinuousRV(0, dist.AllRealSpace)
+			sigma = variable.NewContinuousRV(1, dist.PositiveRealSpace)
+			x     = variable.NewContinuousRV(0, dist.AllRealSpace)
+			one   = variable.NewContinuousRV(1, dist.PositiveRealSpace)
+		)
+		net.AddDist(x, &dist.Normal{}, mu, sigma)
+		net.AddDist(mu, &dist.Normal{}, variable.NewContinuousRV(5, dist.AllRealSpace), one)
+
+		Convey("Constant parameters must be observed", func() {
+			So(net.Validate(), ShouldNotBeNil)
+			sigma.Observe(0.1)
+			one.Observe(1)
+			net.nodes[mu].Given[0].(*variable.ContinuousRV).Observe(5)
+			So(net.Validate(), ShouldBeNil)
+
+			Convey("Samples follow their parents", func() {
+				So(net.Sample(), ShouldBeNil)
+				So(math.Abs(x.Val()-mu.Val()), ShouldBeLessThan, 1)
+				So(net.LogProb(), ShouldAlmostEqual, math.Log(
+					dist.Normal{Mu: 5, Sigma: 1}.PDF(mu.Val())*
+						dist.Normal{Mu: mu.Val(), Sigma: 0.1}.PDF(x.Val())))
+			})
+		})
+
+		Convey("Out-of-domain draws for strict variables are errors", func() {
+			var (
+				net = NewNetwork()
+				y   = variable.NewContinuousRV(1, dist.PositiveRealSpace)
+				m   = variable.NewContinuousRV(-100, dist.AllRealSpace)
+				s   = variable.NewContinuousRV(0.1, dist.PositiveRealSpace)
+			)
+			m.Observe(-100)
+			s.Observe(0.1)
+			y.SetStrict(true)
+			net.AddDist(y, &dist.Normal{}, m, s)
+			So(net.Sample(), ShouldHaveSameTypeAs, (*variable.DomainError)(nil))
+			So(y.Val(), ShouldEqual, 1)
+		})
+
+		Convey("Distributions must match their parameters", func() {
+			So(func() { net.AddDist(x, &dist.Normal{}, mu) }, ShouldPanic)
+		})
+	})
+}
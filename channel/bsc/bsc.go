@@ -99,3 +99,18 @@ func (factor BSCFactor) Score() float64 {
 		return factor.NoiseRate.Val()
 	}
 }
+
+// The score depends only on the adjacent variables
+func (factor BSCFactor) IsPure() bool {
+	return true
+}
+
+// The gradient of the log score with respect to the output, input, and noise
+// rate. Only the noise rate is continuous, so the other entries are zero.
+func (factor BSCFactor) LogScoreGrad() []float64 {
+	if factor.OutputMatchesInput() {
+		return []float64{0, 0, -1 / (1 - factor.NoiseRate.Val())}
+	} else {
+		return []float64{0, 0, 1 / factor.NoiseRate.Val()}
+	}
+}
@@ -98,3 +98,22 @@ func (factor BSCPairFactor) Score() float64 {
 		return ((1 - n1) * n2) + (n1 * (1 - n2))
 	}
 }
+
+// The score depends only on the adjacent variables
+func (factor BSCPairFactor) IsPure() bool {
+	return true
+}
+
+// The gradient of the log score with respect to the output, input, and noise
+// rates. Only the noise rates are continuous, so the other entries are zero.
+func (factor BSCPairFactor) LogScoreGrad() []float64 {
+	var (
+		n1, n2 = factor.NoiseRate1.Val(), factor.NoiseRate2.Val()
+		score  = factor.Score()
+	)
+	if factor.OutputMatchesInput() {
+		return []float64{0, 0, (2*n2 - 1) / score, (2*n1 - 1) / score}
+	} else {
+		return []float64{0, 0, (1 - 2*n2) / score, (1 - 2*n1) / score}
+	}
+}
@@ -0,0 +1,43 @@
+package bsc
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestTableConversion(t *testing.T) {
+	Convey("A BSCFactor converts to a table over its input and output", t, func() {
+		var (
+			ch     = NewBSC(0.2)
+			input  = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			output = variable.NewDiscreteRV(1, dist.BooleanSpace)
+			table  = factor.NewTableFactorFrom(ch.Factor(input, output))
+		)
+		So(table.Vars, ShouldHaveLength, 2)
+		So(table.Value(0, 0), ShouldAlmostEqual, 0.8)
+		So(table.Value(0, 1), ShouldAlmostEqual, 0.2)
+		So(table.Value(1, 0), ShouldAlmostEqual, 0.2)
+		So(table.Value(1, 1), ShouldAlmostEqual, 0.8)
+		So(input.Outcome(), ShouldEqual, 0)
+		So(output.Outcome(), ShouldEqual, 1)
+	})
+
+	Convey("A BSCPairFactor with an observed output converts to a table over its input", t, func() {
+		var (
+			ch     = NewBSCPair(0.1, 0.3)
+			input  = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			output = variable.NewDiscreteRV(0, dist.BooleanSpace)
+		)
+		output.ObserveOutcome(1)
+		f := ch.Factor(input, output)
+		table := factor.NewTableFactorFrom(f)
+		So(table.Vars, ShouldResemble, []*variable.DiscreteRV{input})
+		input.SetOutcome(1)
+		So(table.Value(1), ShouldAlmostEqual, f.Score())
+		input.SetOutcome(0)
+		So(table.Value(0), ShouldAlmostEqual, f.Score())
+	})
+}
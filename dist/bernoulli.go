@@ -1,5 +1,9 @@
 package dist
 
+import (
+	"math"
+)
+
 // Create a new Bernoulli distribution
 func NewBernoulliDist(bias float64) *BernoulliDist {
 	dist := &BernoulliDist{
@@ -71,3 +75,50 @@ func (dist BernoulliDist) Mode() float64 {
 func (dist BernoulliDist) Variance() float64 {
 	return dist.Prob(0) * dist.Prob(1)
 }
+
+// Return the natural log of Score() for the given values
+func (dist BernoulliDist) LogScore(vars, params []float64) float64 {
+	return math.Log(dist.Score(vars, params))
+}
+
+// Return the gradient of LogScore() with respect to the variables and
+// with respect to the parameters. The variable is discrete, so its gradient
+// is zero.
+func (dist BernoulliDist) LogScoreGrad(vars, params []float64) (dVars, dParams []float64) {
+	var bias = params[0]
+	dVars = []float64{0}
+	if dist.BSpace().BoolValue(dist.BSpace().Outcome(vars[0])) {
+		dParams = []float64{1 / bias}
+	} else {
+		dParams = []float64{-1 / (1 - bias)}
+	}
+	return
+}
+
+// Score each row of vars given params, writing the scores to out
+func (dist BernoulliDist) ScoreBatch(vars [][]float64, params []float64, out []float64) {
+	var sp = dist.BSpace()
+	for i, row := range vars {
+		if sp.BoolValue(sp.Outcome(row[0])) {
+			out[i] = params[0]
+		} else {
+			out[i] = 1 - params[0]
+		}
+	}
+}
+
+// Score each row of vars given params, writing the natural log of the scores
+// to out
+func (dist BernoulliDist) LogScoreBatch(vars [][]float64, params []float64, out []float64) {
+	var (
+		sp       = dist.BSpace()
+		pos, neg = math.Log(params[0]), math.Log(1 - params[0])
+	)
+	for i, row := range vars {
+		if sp.BoolValue(sp.Outcome(row[0])) {
+			out[i] = pos
+		} else {
+			out[i] = neg
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/ematvey/gostat"
 	"github.com/jesand/stats"
 	"math"
+	"math/rand"
 )
 
 // Produce a new Beta distribution
@@ -101,9 +102,19 @@ func (dist Beta) Variance() float64 {
 
 // Sample an outcome from the distribution
 func (dist Beta) Sample() float64 {
+	return dist.sample(rand.Float64)
+}
+
+// Sample an outcome from the distribution using the given source of randomness
+func (dist Beta) SampleRand(rng *rand.Rand) float64 {
+	return dist.sample(rng.Float64)
+}
+
+// Sample an outcome, given a source of uniform random numbers in [0, 1)
+func (dist Beta) sample(uniform func() float64) float64 {
 	var (
-		x = randGamma(dist.Alpha, 1, 0)
-		y = randGamma(dist.Beta, 1, 0)
+		x = randGamma(dist.Alpha, 1, 0, uniform)
+		y = randGamma(dist.Beta, 1, 0, uniform)
 	)
 	return x / (x + y)
 }
@@ -127,3 +138,53 @@ func (dist Beta) MaximizeByMoM(vals []float64) *Beta {
 	)
 	return NewBetaDist(alpha, beta)
 }
+
+// Return the natural log of Score() for the given values
+func (dist Beta) LogScore(vars, params []float64) float64 {
+	var x, a, b = vars[0], params[0], params[1]
+	if x <= 0 || x >= 1 {
+		// The density at and outside the bounds has special cases
+		return math.Log(Beta{Alpha: a, Beta: b}.PDF(x))
+	}
+	return (a-1)*math.Log(x) + (b-1)*math.Log(1-x) - LogBeta(a, b)
+}
+
+// Return the gradient of LogScore() with respect to the variables and
+// with respect to the parameters
+func (dist Beta) LogScoreGrad(vars, params []float64) (dVars, dParams []float64) {
+	var (
+		x, a, b = vars[0], params[0], params[1]
+		psiAB   = Digamma(a + b)
+	)
+	dVars = []float64{(a-1)/x - (b-1)/(1-x)}
+	dParams = []float64{
+		math.Log(x) - Digamma(a) + psiAB,
+		math.Log(1-x) - Digamma(b) + psiAB,
+	}
+	return
+}
+
+// Score each row of vars given params, writing the scores to out
+func (dist Beta) ScoreBatch(vars [][]float64, params []float64, out []float64) {
+	dist.LogScoreBatch(vars, params, out)
+	for i, v := range out {
+		out[i] = math.Exp(v)
+	}
+}
+
+// Score each row of vars given params, writing the natural log of the scores
+// to out
+func (dist Beta) LogScoreBatch(vars [][]float64, params []float64, out []float64) {
+	var (
+		a, b = params[0], params[1]
+		norm = LogBeta(a, b)
+	)
+	for i, row := range vars {
+		if x := row[0]; x > 0 && x < 1 {
+			out[i] = (a-1)*math.Log(x) + (b-1)*math.Log(1-x) - norm
+		} else {
+			// The density at and outside the bounds has special cases
+			out[i] = math.Log(Beta{Alpha: a, Beta: b}.PDF(x))
+		}
+	}
+}
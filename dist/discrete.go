@@ -2,6 +2,7 @@ package dist
 
 import (
 	"github.com/jesand/stats"
+	"math"
 )
 
 // Make a new instance of DenseMutableDiscreteDist
@@ -129,3 +130,36 @@ func (dist *DenseMutableDiscreteDist) NormalizeWithExtra(rest float64) {
 	}
 	dist.Normalize()
 }
+
+// Return the natural log of Score() for the given values
+func (dist DenseMutableDiscreteDist) LogScore(vars, params []float64) float64 {
+	return math.Log(dist.Score(vars, params))
+}
+
+// Return the gradient of LogScore() with respect to the variables and
+// with respect to the parameters. The variable is discrete, so its gradient
+// is zero.
+func (dist DenseMutableDiscreteDist) LogScoreGrad(vars, params []float64) (dVars, dParams []float64) {
+	var outcome = dist.space.(DiscreteRealSpace).Outcome(vars[0])
+	dVars = []float64{0}
+	dParams = make([]float64, len(params))
+	dParams[int(outcome)] = 1 / params[int(outcome)]
+	return
+}
+
+// Score each row of vars given params, writing the scores to out
+func (dist DenseMutableDiscreteDist) ScoreBatch(vars [][]float64, params []float64, out []float64) {
+	var sp = dist.space.(DiscreteRealSpace)
+	for i, row := range vars {
+		out[i] = params[int(sp.Outcome(row[0]))]
+	}
+}
+
+// Score each row of vars given params, writing the natural log of the scores
+// to out
+func (dist DenseMutableDiscreteDist) LogScoreBatch(vars [][]float64, params []float64, out []float64) {
+	var sp = dist.space.(DiscreteRealSpace)
+	for i, row := range vars {
+		out[i] = math.Log(params[int(sp.Outcome(row[0]))])
+	}
+}
@@ -9,7 +9,9 @@ import (
 // Represents a probability distribution
 type Dist interface {
 
-	// Return a "score" (density or probability) for the given values
+	// Return a "score" (density or probability) for the given values. The
+	// score must depend only on the arguments, not on parameters set with
+	// SetParams(), so that factors can cache it.
 	Score(vars, params []float64) float64
 
 	// The number of random variables the distribution is over
@@ -22,6 +24,58 @@ type Dist interface {
 	SetParams(vals []float64)
 }
 
+// A distribution whose log score can be differentiated with respect to its
+// variables and parameters. This supports gradient-based optimization and
+// sampling, such as MAP estimation and Hamiltonian Monte Carlo.
+type Differentiable interface {
+	Dist
+
+	// Return the natural log of Score() for the given values
+	LogScore(vars, params []float64) float64
+
+	// Return the gradient of LogScore() with respect to the variables and
+	// with respect to the parameters. Discrete variables have no gradient,
+	// and their entries are zero.
+	LogScoreGrad(vars, params []float64) (dVars, dParams []float64)
+}
+
+// A distribution which can score many points sharing the same parameters in a
+// single call, without allocating
+type BatchDist interface {
+	Dist
+
+	// Score each row of vars given params, writing the scores to out
+	ScoreBatch(vars [][]float64, params []float64, out []float64)
+
+	// Score each row of vars given params, writing the natural log of the
+	// scores to out
+	LogScoreBatch(vars [][]float64, params []float64, out []float64)
+}
+
+// Score each row of vars given params, writing the scores to out. Uses the
+// distribution's batch implementation if it has one.
+func ScoreBatch(d Dist, vars [][]float64, params []float64, out []float64) {
+	if bd, ok := d.(BatchDist); ok {
+		bd.ScoreBatch(vars, params, out)
+		return
+	}
+	for i, row := range vars {
+		out[i] = d.Score(row, params)
+	}
+}
+
+// Score each row of vars given params, writing the natural log of the scores
+// to out. Uses the distribution's batch implementation if it has one.
+func LogScoreBatch(d Dist, vars [][]float64, params []float64, out []float64) {
+	if bd, ok := d.(BatchDist); ok {
+		bd.LogScoreBatch(vars, params, out)
+		return
+	}
+	for i, row := range vars {
+		out[i] = math.Log(d.Score(row, params))
+	}
+}
+
 // Represents a distribution over reals for a random variable
 type RealDist interface {
 
@@ -62,6 +116,31 @@ type ContinuousDist interface {
 	LgProb(from, to float64) float64
 }
 
+// A continuous distribution which can sample from a given source of
+// randomness, rather than the global source, so that samples are reproducible
+type ContinuousRandSampler interface {
+	ContinuousDist
+
+	// Sample an outcome using the given source of randomness
+	SampleRand(rng *rand.Rand) float64
+}
+
+// Sample an outcome from a continuous distribution using the given source of
+// randomness. Distributions which are not ContinuousRandSamplers draw from the
+// global source instead.
+func SampleContinuousRand(d ContinuousDist, rng *rand.Rand) float64 {
+	if rs, ok := d.(ContinuousRandSampler); ok {
+		return rs.SampleRand(rng)
+	}
+	return d.Sample()
+}
+
+// Sample an outcome from a discrete distribution using the given source of
+// randomness
+func SampleDiscreteRand(d DiscreteDist, rng *rand.Rand) Outcome {
+	return sampleDiscrete(d, rng.Float64)
+}
+
 // Represents a discrete distribution over a sample space
 type DiscreteDist interface {
 	Dist
@@ -117,9 +196,15 @@ type MutableDiscreteDist interface {
 type DefDiscreteDistSample struct{ dist DiscreteDist }
 
 func (dist DefDiscreteDistSample) Sample() Outcome {
-	var remaining = rand.Float64()
-	for i := Outcome(0); int(i) < dist.dist.Space().Size(); i++ {
-		remaining -= dist.dist.Prob(i)
+	return sampleDiscrete(dist.dist, rand.Float64)
+}
+
+// Sample an outcome from a discrete distribution, given a source of uniform
+// random numbers in [0, 1)
+func sampleDiscrete(d DiscreteDist, uniform func() float64) Outcome {
+	var remaining = uniform()
+	for i := Outcome(0); int(i) < d.Space().Size(); i++ {
+		remaining -= d.Prob(i)
 		if remaining <= 0 {
 			return i
 		}
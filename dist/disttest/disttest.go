@@ -0,0 +1,521 @@
+/*
+ * Package disttest checks that an implementation of dist.ContinuousDist or
+ * dist.DiscreteDist honors the contracts shared by all distributions: the CDF
+ * is monotone and runs from 0 to 1, the density or mass sums to 1 over the
+ * sample space, sample moments match Mean() and Variance(), Score() agrees
+ * with PDF() or Prob(), LogScore() and LogScoreBatch() agree with the log of
+ * Score() inside and outside the space, and SetParams() round-trips.
+ *
+ * Typical use from a test:
+ *
+ *	func TestMyDist(t *testing.T) {
+ *		disttest.TestContinuous(t, NewMyDist(1, 2), disttest.Options{
+ *			Params: [][]float64{{1, 2}, {0.5, 3}},
+ *		})
+ *	}
+ */
+package disttest
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"math"
+	"testing"
+)
+
+const (
+	// The default number of samples drawn when checking moments
+	DefaultSamples = 20000
+
+	// The default absolute tolerance for numeric comparisons
+	DefaultTolerance = 1e-6
+
+	// The number of standard errors a sample moment may stray from its
+	// expected value before the moment check fails
+	momentStdErrs = 5
+
+	// The number of points at which functions are evaluated
+	gridSize = 101
+)
+
+// Options controlling the conformance checks
+type Options struct {
+
+	// Parameter settings to check. Each is applied in turn with SetParams(),
+	// and at least one is required.
+	Params [][]float64
+
+	// The number of samples drawn when checking moments. If zero,
+	// DefaultSamples is used.
+	Samples int
+
+	// Absolute tolerance for numeric comparisons. If zero, DefaultTolerance
+	// is used.
+	Tolerance float64
+
+	// Skip the moment checks, e.g. for distributions without finite variance
+	SkipMoments bool
+}
+
+func (opts Options) samples() int {
+	if opts.Samples <= 0 {
+		return DefaultSamples
+	}
+	return opts.Samples
+}
+
+func (opts Options) tolerance() float64 {
+	if opts.Tolerance <= 0 {
+		return DefaultTolerance
+	}
+	return opts.Tolerance
+}
+
+// Run all conformance checks for a continuous distribution, reporting each
+// violation as a test error
+func TestContinuous(t testing.TB, d dist.ContinuousDist, opts Options) {
+	for _, err := range CheckContinuous(d, opts) {
+		t.Errorf("%T: %v", d, err)
+	}
+}
+
+// Run all conformance checks for a discrete distribution, reporting each
+// violation as a test error
+func TestDiscrete(t testing.TB, d dist.DiscreteDist, opts Options) {
+	for _, err := range CheckDiscrete(d, opts) {
+		t.Errorf("%T: %v", d, err)
+	}
+}
+
+// Run all conformance checks for a continuous distribution, returning every
+// violation found
+func CheckContinuous(d dist.ContinuousDist, opts Options) (errs []error) {
+	if len(opts.Params) == 0 {
+		return []error{stats.Errorf("No parameters provided")}
+	}
+	for _, params := range opts.Params {
+		d.SetParams(params)
+		var checks = []func(dist.ContinuousDist, []float64, Options) error{
+			checkContinuousCDF,
+			checkContinuousPDFIntegral,
+			checkContinuousScore,
+			checkContinuousLogScore,
+		}
+		if !opts.SkipMoments {
+			checks = append(checks, checkContinuousMoments)
+		}
+		for _, check := range checks {
+			if err := check(d, params, opts); err != nil {
+				errs = append(errs, stats.Errorf("params %v: %v", params, err))
+			}
+		}
+	}
+	if err := checkContinuousSetParams(d, opts); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Run all conformance checks for a discrete distribution, returning every
+// violation found
+func CheckDiscrete(d dist.DiscreteDist, opts Options) (errs []error) {
+	if len(opts.Params) == 0 {
+		return []error{stats.Errorf("No parameters provided")}
+	} else if d.Space().Size() < 0 {
+		return []error{stats.Errorf("Infinite discrete spaces are not supported")}
+	}
+	for _, params := range opts.Params {
+		d.SetParams(params)
+		var checks = []func(dist.DiscreteDist, []float64, Options) error{
+			checkDiscreteCDF,
+			checkDiscreteProbSum,
+			checkDiscreteScore,
+			checkDiscreteLogScore,
+		}
+		if !opts.SkipMoments {
+			checks = append(checks, checkDiscreteMoments)
+		}
+		for _, check := range checks {
+			if err := check(d, params, opts); err != nil {
+				errs = append(errs, stats.Errorf("params %v: %v", params, err))
+			}
+		}
+	}
+	if err := checkDiscreteSetParams(d, opts); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// The CDF must be monotone, within [0, 1], and run from 0 to 1 over the space
+func checkContinuousCDF(d dist.ContinuousDist, params []float64, opts Options) error {
+	var (
+		tol    = opts.tolerance()
+		sp     = d.Space()
+		lo, hi = d.CDF(sp.Inf()), d.CDF(sp.Sup())
+		last   = math.Inf(-1)
+	)
+	if math.Abs(lo) > tol {
+		return stats.Errorf("CDF(%v) = %v, want 0", sp.Inf(), lo)
+	} else if math.Abs(hi-1) > tol {
+		return stats.Errorf("CDF(%v) = %v, want 1", sp.Sup(), hi)
+	}
+	for _, x := range grid(d) {
+		cdf := d.CDF(x)
+		if math.IsNaN(cdf) || cdf < -tol || cdf > 1+tol {
+			return stats.Errorf("CDF(%v) = %v is not a probability", x, cdf)
+		} else if cdf < last-tol {
+			return stats.Errorf("CDF is not monotone: CDF(%v) = %v < %v", x, cdf, last)
+		}
+		last = cdf
+	}
+	return nil
+}
+
+// The PDF must integrate to 1 over the space
+func checkContinuousPDFIntegral(d dist.ContinuousDist, params []float64, opts Options) error {
+	var (
+		sp    = d.Space()
+		total = integrate(d.PDF, sp.Inf(), sp.Sup(), d.Mean(), math.Sqrt(d.Variance()))
+	)
+	if math.Abs(total-1) > math.Max(opts.tolerance(), 1e-3) {
+		return stats.Errorf("PDF integrates to %v over [%v, %v], want 1",
+			total, sp.Inf(), sp.Sup())
+	}
+	return nil
+}
+
+// Score() must agree with PDF() for the current parameters
+func checkContinuousScore(d dist.ContinuousDist, params []float64, opts Options) error {
+	for _, x := range grid(d) {
+		var (
+			pdf   = d.PDF(x)
+			score = d.Score([]float64{x}, params)
+		)
+		if !almostEqual(pdf, score, opts.tolerance()) {
+			return stats.Errorf("Score([%v]) = %v, but PDF(%v) = %v", x, score, x, pdf)
+		}
+	}
+	return nil
+}
+
+// The log scores must agree with Score() over the space, and also outside a
+// bounded space, where the score is zero
+func checkContinuousLogScore(d dist.ContinuousDist, params []float64, opts Options) error {
+	var (
+		sp = d.Space()
+		xs = grid(d)
+	)
+	if !math.IsInf(sp.Inf(), -1) {
+		xs = append(xs, sp.Inf()-1)
+	}
+	if !math.IsInf(sp.Sup(), +1) {
+		xs = append(xs, sp.Sup()+1)
+	}
+	return checkLogScore(d, xs, params, opts)
+}
+
+// Sample moments must match Mean() and Variance()
+func checkContinuousMoments(d dist.ContinuousDist, params []float64, opts Options) error {
+	return checkMoments(d.SampleN(opts.samples()), d.Mean(), d.Variance(), opts)
+}
+
+// Setting other parameters and then restoring the original ones must leave
+// the distribution unchanged
+func checkContinuousSetParams(d dist.ContinuousDist, opts Options) error {
+	var snapshot = func() []float64 {
+		var vals = []float64{d.Mean(), d.Variance()}
+		for _, x := range grid(d) {
+			vals = append(vals, d.PDF(x), d.CDF(x))
+		}
+		return vals
+	}
+	return checkSetParams(d, snapshot, opts)
+}
+
+// If the distribution has a CDF, it must be monotone and run from 0 to 1 over
+// the space
+func checkDiscreteCDF(d dist.DiscreteDist, params []float64, opts Options) error {
+	var (
+		cd, hasCDF = d.(interface {
+			CDF(val float64) float64
+		})
+		sp, isReal = d.Space().(dist.DiscreteRealSpace)
+		tol        = opts.tolerance()
+		last       float64
+	)
+	if !hasCDF || !isReal {
+		return nil
+	}
+	if below := cd.CDF(math.Nextafter(sp.Inf(), math.Inf(-1))); math.Abs(below) > tol {
+		return stats.Errorf("CDF below the space is %v, want 0", below)
+	}
+	for o := 0; o < sp.Size(); o++ {
+		var (
+			x   = sp.F64Value(dist.Outcome(o))
+			cdf = cd.CDF(x)
+		)
+		if math.IsNaN(cdf) || cdf < -tol || cdf > 1+tol {
+			return stats.Errorf("CDF(%v) = %v is not a probability", x, cdf)
+		} else if cdf < last-tol {
+			return stats.Errorf("CDF is not monotone: CDF(%v) = %v < %v", x, cdf, last)
+		}
+		last = cdf
+	}
+	if top := cd.CDF(sp.Sup()); math.Abs(top-1) > tol {
+		return stats.Errorf("CDF(%v) = %v, want 1", sp.Sup(), top)
+	}
+	return nil
+}
+
+// The outcome probabilities must sum to 1
+func checkDiscreteProbSum(d dist.DiscreteDist, params []float64, opts Options) error {
+	var total float64
+	for o := 0; o < d.Space().Size(); o++ {
+		p := d.Prob(dist.Outcome(o))
+		if math.IsNaN(p) || p < 0 || p > 1 {
+			return stats.Errorf("Prob(%d) = %v is not a probability", o, p)
+		}
+		total += p
+	}
+	if math.Abs(total-1) > opts.tolerance() {
+		return stats.Errorf("Outcome probabilities sum to %v, want 1", total)
+	}
+	return nil
+}
+
+// Score() must agree with Prob() for the current parameters. This can only be
+// checked over a discrete subset of the reals.
+func checkDiscreteScore(d dist.DiscreteDist, params []float64, opts Options) error {
+	sp, ok := d.Space().(dist.DiscreteRealSpace)
+	if !ok {
+		return nil
+	}
+	for o := 0; o < sp.Size(); o++ {
+		var (
+			x     = sp.F64Value(dist.Outcome(o))
+			prob  = d.Prob(dist.Outcome(o))
+			score = d.Score([]float64{x}, params)
+		)
+		if !almostEqual(prob, score, opts.tolerance()) {
+			return stats.Errorf("Score([%v]) = %v, but Prob(%d) = %v", x, score, o, prob)
+		}
+	}
+	return nil
+}
+
+// The log scores must agree with Score() over the space. This can only be
+// checked over a discrete subset of the reals.
+func checkDiscreteLogScore(d dist.DiscreteDist, params []float64, opts Options) error {
+	sp, ok := d.Space().(dist.DiscreteRealSpace)
+	if !ok {
+		return nil
+	}
+	var xs []float64
+	for o := 0; o < sp.Size(); o++ {
+		xs = append(xs, sp.F64Value(dist.Outcome(o)))
+	}
+	return checkLogScore(d, xs, params, opts)
+}
+
+// Sample moments must match Mean() and Variance(), if the distribution is over
+// a discrete subset of the reals
+func checkDiscreteMoments(d dist.DiscreteDist, params []float64, opts Options) error {
+	var (
+		rd, isReal   = d.(dist.RealDist)
+		sp, hasReals = d.Space().(dist.DiscreteRealSpace)
+	)
+	if !isReal || !hasReals {
+		return nil
+	}
+	var vals []float64
+	for _, outcome := range d.SampleN(opts.samples()) {
+		vals = append(vals, sp.F64Value(outcome))
+	}
+	return checkMoments(vals, rd.Mean(), rd.Variance(), opts)
+}
+
+// Setting other parameters and then restoring the original ones must leave
+// the distribution unchanged
+func checkDiscreteSetParams(d dist.DiscreteDist, opts Options) error {
+	var snapshot = func() []float64 {
+		var vals []float64
+		for o := 0; o < d.Space().Size(); o++ {
+			vals = append(vals, d.Prob(dist.Outcome(o)))
+		}
+		return vals
+	}
+	return checkSetParams(d, snapshot, opts)
+}
+
+// Check that each parameter setting can be restored after applying each other
+// setting, by comparing snapshots of the distribution's behavior
+func checkSetParams(d dist.Dist, snapshot func() []float64, opts Options) error {
+	for i, params := range opts.Params {
+		if len(params) != d.NumParams() {
+			return stats.Errorf("params %v: expected %d parameter(s)", params, d.NumParams())
+		}
+		d.SetParams(params)
+		var want = snapshot()
+		for j, other := range opts.Params {
+			if i == j {
+				continue
+			}
+			d.SetParams(other)
+			d.SetParams(params)
+			for k, got := range snapshot() {
+				if !almostEqual(want[k], got, opts.tolerance()) {
+					return stats.Errorf("SetParams(%v) did not round-trip after SetParams(%v)",
+						params, other)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// LogScore(), if the distribution has it, and LogScoreBatch() must equal the
+// log of Score() at each value, including -Inf where the score is zero
+func checkLogScore(d dist.Dist, xs, params []float64, opts Options) error {
+	var (
+		ld, hasLog = d.(interface {
+			LogScore(vars, params []float64) float64
+		})
+		rows  = make([][]float64, len(xs))
+		batch = make([]float64, len(xs))
+		tol   = opts.tolerance()
+	)
+	for i, x := range xs {
+		rows[i] = []float64{x}
+	}
+	dist.LogScoreBatch(d, rows, params, batch)
+	for i, x := range xs {
+		var want = math.Log(d.Score(rows[i], params))
+		if !almostEqual(want, batch[i], tol) {
+			return stats.Errorf("LogScoreBatch() gives %v at %v, but log(Score()) = %v",
+				batch[i], x, want)
+		} else if !hasLog {
+			continue
+		} else if got := ld.LogScore(rows[i], params); !almostEqual(want, got, tol) {
+			return stats.Errorf("LogScore([%v]) = %v, but log(Score()) = %v", x, got, want)
+		}
+	}
+	return nil
+}
+
+// Compare sample moments to expected values, allowing for sampling error
+func checkMoments(vals []float64, mean, variance float64, opts Options) error {
+	var (
+		n         = float64(len(vals))
+		tol       = opts.tolerance()
+		smean     = dist.Mean(vals)
+		svariance = dist.Variance(vals)
+		m4        float64
+	)
+	for _, v := range vals {
+		m4 += math.Pow(v-smean, 4)
+	}
+	m4 /= n
+
+	// The sample variance minus var is n/(n-1) * (A - var - D) + var/(n-1),
+	// where A is the mean squared deviation from the true mean, with variance
+	// (m4 - var^2)/n, and D is the squared error of the sample mean. A's error
+	// is bounded in standard errors, and D by the square of the mean's bound.
+	// D dominates when m4 is close to var^2, as for a fair coin, where A
+	// barely varies.
+	var (
+		meanSE      = math.Sqrt(svariance / n)
+		meanErr     = momentStdErrs*meanSE + tol
+		varianceSE  = math.Sqrt(math.Max(m4-svariance*svariance, 0) / n)
+		varianceErr = n/(n-1)*(momentStdErrs*varianceSE+momentStdErrs*momentStdErrs*meanSE*meanSE) +
+			svariance/(n-1) + tol
+	)
+	if math.IsNaN(smean) || math.Abs(smean-mean) > meanErr {
+		return stats.Errorf("Sample mean %v differs from Mean() = %v by more than %v",
+			smean, mean, meanErr)
+	} else if math.IsNaN(svariance) || math.Abs(svariance-variance) > varianceErr {
+		return stats.Errorf("Sample variance %v differs from Variance() = %v by more than %v",
+			svariance, variance, varianceErr)
+	}
+	return nil
+}
+
+// Ask whether two values agree to within an absolute or relative tolerance
+func almostEqual(x, y, tol float64) bool {
+	if x == y {
+		return true
+	}
+	diff := math.Abs(x - y)
+	return diff <= tol || diff <= tol*math.Max(math.Abs(x), math.Abs(y))
+}
+
+// Choose evaluation points spanning the interior of the distribution's space.
+// Infinite bounds are replaced with points far into the distribution's tails.
+func grid(d dist.ContinuousDist) []float64 {
+	var (
+		sp     = d.Space()
+		mean   = d.Mean()
+		sd     = math.Sqrt(d.Variance())
+		lo, hi = sp.Inf(), sp.Sup()
+		pts    = make([]float64, gridSize)
+	)
+	if math.IsInf(lo, -1) {
+		lo = mean - 10*sd
+	}
+	if math.IsInf(hi, +1) {
+		hi = mean + 10*sd
+	}
+	for i := range pts {
+		pts[i] = lo + (hi-lo)*(float64(i)+0.5)/gridSize
+	}
+	return pts
+}
+
+// Integrate f over [lo, hi] using tanh-sinh quadrature, which tolerates
+// integrable singularities at the endpoints. Infinite bounds are mapped onto
+// a finite interval using the given location and scale.
+func integrate(f func(float64) float64, lo, hi, loc, scale float64) float64 {
+	const (
+		h    = 1.0 / 16
+		umax = 5.0
+	)
+	if scale <= 0 || math.IsNaN(scale) || math.IsInf(scale, 0) {
+		scale = 1
+	}
+	var total float64
+	for u := -umax; u <= umax; u += h {
+		var (
+			z = math.Pi / 2 * math.Sinh(u)
+
+			// The node t in (-1, 1) is represented by 1+t and 1-t,
+			// computed directly to keep precision near the endpoints.
+			onePlus  = 2 / (1 + math.Exp(-2*z))
+			oneMinus = 2 / (1 + math.Exp(2*z))
+			t        = math.Tanh(z)
+			w        = math.Pi / 2 * math.Cosh(u) / math.Pow(math.Cosh(z), 2)
+			x, dx    float64
+		)
+		switch {
+		case math.IsInf(lo, -1) && math.IsInf(hi, +1):
+			x = loc + scale*t/(onePlus*oneMinus)
+			dx = scale * (1 + t*t) / math.Pow(onePlus*oneMinus, 2)
+		case math.IsInf(hi, +1):
+			x = lo + scale*onePlus/oneMinus
+			dx = 2 * scale / (oneMinus * oneMinus)
+		case math.IsInf(lo, -1):
+			x = hi - scale*oneMinus/onePlus
+			dx = 2 * scale / (onePlus * onePlus)
+		default:
+			if t < 0 {
+				x = lo + (hi-lo)*onePlus/2
+			} else {
+				x = hi - (hi-lo)*oneMinus/2
+			}
+			dx = (hi - lo) / 2
+		}
+		if v := f(x) * dx * w; !math.IsNaN(v) && !math.IsInf(v, 0) {
+			total += v
+		}
+	}
+	return total * h
+}
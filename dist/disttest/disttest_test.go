@@ -0,0 +1,108 @@
+package disttest
+
+import (
+	"github.com/jesand/stats/dist"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// A Normal distribution whose sampler scales by the wrong factor
+type badSampleNormal struct{ *dist.Normal }
+
+func (d badSampleNormal) Sample() float64 {
+	return d.Mu + rand.NormFloat64()/d.Sigma
+}
+
+func (d badSampleNormal) SampleN(n int) []float64 {
+	var vals []float64
+	for i := 0; i < n; i++ {
+		vals = append(vals, d.Sample())
+	}
+	return vals
+}
+
+// A Normal distribution whose density is off by a constant factor
+type badPDFNormal struct{ *dist.Normal }
+
+func (d badPDFNormal) PDF(val float64) float64 {
+	return 2 * d.Normal.PDF(val)
+}
+
+// A Beta distribution whose batch log score ignores the bounds of the space
+type badLogScoreBeta struct{ *dist.Beta }
+
+func (d badLogScoreBeta) LogScoreBatch(vars [][]float64, params []float64, out []float64) {
+	for i, row := range vars {
+		out[i] = (params[0]-1)*math.Log(row[0]) + (params[1]-1)*math.Log(1-row[0]) -
+			dist.LogBeta(params[0], params[1])
+	}
+}
+
+func TestBuiltinDists(t *testing.T) {
+	Convey("Normal conforms", t, func() {
+		So(CheckContinuous(dist.NewStandardNormalDist(), Options{
+			Params: [][]float64{{0, 1}, {1.5, 0.5}, {-2, 3}},
+		}), ShouldBeEmpty)
+	})
+
+	Convey("Beta conforms", t, func() {
+		So(CheckContinuous(dist.NewBetaDist(1, 1), Options{
+			Params: [][]float64{{0.5, 0.5}, {2, 5}, {5, 1}},
+		}), ShouldBeEmpty)
+	})
+
+	Convey("Gamma conforms", t, func() {
+		So(CheckContinuous(dist.NewGammaDist(1, 1), Options{
+			Params: [][]float64{{0.5, 1}, {1, 2}, {9, 0.5}},
+		}), ShouldBeEmpty)
+	})
+
+	Convey("Bernoulli conforms", t, func() {
+		So(CheckDiscrete(dist.NewBernoulliDist(0.5), Options{
+			Params: [][]float64{{0.1}, {0.5}, {0.9}},
+		}), ShouldBeEmpty)
+	})
+
+	Convey("DenseMutableDiscreteDist conforms", t, func() {
+		d := dist.NewDenseMutableDiscreteDist(dist.BooleanSpace)
+		d.SetProb(0, 0.5)
+		d.SetProb(1, 0.5)
+		So(CheckDiscrete(d, Options{
+			Params: [][]float64{{0.2, 0.8}, {0.5, 0.5}, {1, 0}},
+		}), ShouldBeEmpty)
+	})
+}
+
+func TestBrokenDists(t *testing.T) {
+	Convey("A sampler with the wrong scale fails the moment check", t, func() {
+		errs := CheckContinuous(badSampleNormal{dist.NewStandardNormalDist()}, Options{
+			Params: [][]float64{{1, 3}},
+		})
+		So(errs, ShouldHaveLength, 1)
+		So(errs[0].Error(), ShouldContainSubstring, "Sample variance")
+	})
+
+	Convey("An unnormalized PDF fails the integral and score checks", t, func() {
+		errs := CheckContinuous(badPDFNormal{dist.NewStandardNormalDist()}, Options{
+			Params: [][]float64{{0, 1}},
+		})
+		So(errs, ShouldHaveLength, 2)
+		So(errs[0].Error(), ShouldContainSubstring, "integrates to")
+		So(errs[1].Error(), ShouldContainSubstring, "Score")
+	})
+
+	Convey("A log score which is NaN outside the space fails the log score check", t, func() {
+		errs := CheckContinuous(badLogScoreBeta{dist.NewBetaDist(1, 1)}, Options{
+			Params: [][]float64{{2, 3}},
+		})
+		So(errs, ShouldHaveLength, 1)
+		So(errs[0].Error(), ShouldContainSubstring, "LogScoreBatch")
+	})
+
+	Convey("Missing parameters are reported", t, func() {
+		So(CheckContinuous(dist.NewStandardNormalDist(), Options{}), ShouldHaveLength, 1)
+		So(CheckDiscrete(dist.NewBernoulliDist(0.5), Options{}), ShouldHaveLength, 1)
+	})
+}
@@ -7,9 +7,10 @@ import (
 )
 
 // Return a random value drawn from a Gamma distribution with mean
-// alpha*beta+lamba and variance alpha*beta^2.
+// alpha*beta+lamba and variance alpha*beta^2, given a source of uniform random
+// numbers in [0, 1).
 // Based on nextGamma() in Factorie: https://github.com/factorie/factorie
-func randGamma(alpha, beta, lambda float64) float64 {
+func randGamma(alpha, beta, lambda float64, uniform func() float64) float64 {
 	var gamma float64
 	if alpha <= 0 || beta <= 0 {
 		panic(stats.Errorf("Invalid Gamma distribution parameters: alpha=%f, beta=%f",
@@ -21,27 +22,239 @@ func randGamma(alpha, beta, lambda float64) float64 {
 			b = 1 + alpha*math.Exp(-1)
 		)
 		for {
-			p = b * rand.Float64()
+			p = b * uniform()
 			if p > 1 {
 				gamma = -math.Log((b - p) / alpha)
-				if rand.Float64() <= math.Pow(gamma, alpha-1) {
+				if uniform() <= math.Pow(gamma, alpha-1) {
 					break
 				}
 			} else {
 				gamma = math.Pow(p, 1/alpha)
-				if rand.Float64() <= math.Exp(-gamma) {
+				if uniform() <= math.Exp(-gamma) {
 					break
 				}
 			}
 		}
 	} else if alpha == 1 {
-		gamma = -math.Log(rand.Float64())
+		gamma = -math.Log(uniform())
 	} else {
-		var y = -math.Log(rand.Float64())
-		for rand.Float64() > math.Pow(y*math.Exp(1-y), alpha-1) {
-			y = -math.Log(rand.Float64())
+		var y = -math.Log(uniform())
+		for uniform() > math.Pow(y*math.Exp(1-y), alpha-1) {
+			y = -math.Log(uniform())
 		}
 		gamma = alpha * y
 	}
 	return beta*gamma + lambda
 }
+
+// Produce a new Gamma distribution with the given shape and scale
+func NewGammaDist(shape, scale float64) *Gamma {
+	dist := &Gamma{
+		Shape: shape,
+		Scale: scale,
+		space: PositiveRealSpace,
+	}
+	dist.DefContinuousDistSampleN.dist = dist
+	dist.DefContinuousDistProb.dist = dist
+	dist.DefContinuousDistLgProb.dist = dist
+	return dist
+}
+
+// A Gamma distribution, parameterized by shape k and scale theta.
+// See: https://en.wikipedia.org/wiki/Gamma_distribution
+type Gamma struct {
+
+	// The distribution parameters
+	Shape, Scale float64
+
+	// The space
+	space RealSpace
+
+	DefContinuousDistSampleN
+	DefContinuousDistProb
+	DefContinuousDistLgProb
+}
+
+// Return the corresponding sample space
+func (dist Gamma) Space() RealSpace {
+	return dist.space
+}
+
+// Return a "score" (density or probability) for the given values
+func (dist Gamma) Score(vars, params []float64) float64 {
+	return Gamma{Shape: params[0], Scale: params[1]}.PDF(vars[0])
+}
+
+// The number of random variables the distribution is over
+func (dist Gamma) NumVars() int {
+	return 1
+}
+
+// The number of parameters in the distribution
+func (dist Gamma) NumParams() int {
+	return 2
+}
+
+// Update the distribution parameters
+func (dist *Gamma) SetParams(vals []float64) {
+	dist.Shape, dist.Scale = vals[0], vals[1]
+}
+
+// Return the density at a given value
+func (dist Gamma) PDF(val float64) float64 {
+	if val < 0 {
+		return 0
+	} else if val == 0 {
+		if dist.Shape < 1 {
+			return math.Inf(+1)
+		} else if dist.Shape == 1 {
+			return 1 / dist.Scale
+		}
+		return 0
+	}
+	lgamma, _ := math.Lgamma(dist.Shape)
+	return math.Exp((dist.Shape-1)*math.Log(val) - val/dist.Scale - lgamma -
+		dist.Shape*math.Log(dist.Scale))
+}
+
+// The value of the CDF: Pr(X <= val) for random variable X over this space
+func (dist Gamma) CDF(val float64) float64 {
+	if val <= 0 {
+		return 0
+	} else if math.IsInf(val, +1) {
+		return 1
+	}
+	return regLowerGamma(dist.Shape, val/dist.Scale)
+}
+
+// The mean, or expected value, of the random variable
+func (dist Gamma) Mean() float64 {
+	return dist.Shape * dist.Scale
+}
+
+// The mode of the random variable
+func (dist Gamma) Mode() float64 {
+	if dist.Shape >= 1 {
+		return (dist.Shape - 1) * dist.Scale
+	}
+	panic(stats.Errorf("Gamma(%f, %f) has no mode", dist.Shape, dist.Scale))
+}
+
+// The variance of the random variable
+func (dist Gamma) Variance() float64 {
+	return dist.Shape * dist.Scale * dist.Scale
+}
+
+// Sample an outcome from the distribution
+func (dist Gamma) Sample() float64 {
+	return randGamma(dist.Shape, dist.Scale, 0, rand.Float64)
+}
+
+// Sample an outcome from the distribution using the given source of randomness
+func (dist Gamma) SampleRand(rng *rand.Rand) float64 {
+	return randGamma(dist.Shape, dist.Scale, 0, rng.Float64)
+}
+
+// The regularized lower incomplete gamma function P(a, x), computed by its
+// series expansion for small x and by a continued fraction otherwise.
+// Based on gser() and gcf() in Numerical Recipes.
+func regLowerGamma(a, x float64) float64 {
+	const (
+		maxIter = 1000
+		eps     = 1e-15
+		tiny    = 1e-300
+	)
+	lgamma, _ := math.Lgamma(a)
+	if x < a+1 {
+		var (
+			ap  = a
+			del = 1 / a
+			sum = del
+		)
+		for i := 0; i < maxIter; i++ {
+			ap++
+			del *= x / ap
+			sum += del
+			if math.Abs(del) < math.Abs(sum)*eps {
+				break
+			}
+		}
+		return sum * math.Exp(-x+a*math.Log(x)-lgamma)
+	}
+	var (
+		b = x + 1 - a
+		c = 1 / tiny
+		d = 1 / b
+		h = d
+	)
+	for i := 1; i <= maxIter; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < eps {
+			break
+		}
+	}
+	return 1 - math.Exp(-x+a*math.Log(x)-lgamma)*h
+}
+
+// Return the natural log of Score() for the given values
+func (dist Gamma) LogScore(vars, params []float64) float64 {
+	var (
+		x, k, theta = vars[0], params[0], params[1]
+		lgamma, _   = math.Lgamma(k)
+	)
+	if x <= 0 {
+		// The density at and below zero has special cases
+		return math.Log(Gamma{Shape: k, Scale: theta}.PDF(x))
+	}
+	return (k-1)*math.Log(x) - x/theta - lgamma - k*math.Log(theta)
+}
+
+// Return the gradient of LogScore() with respect to the variables and
+// with respect to the parameters
+func (dist Gamma) LogScoreGrad(vars, params []float64) (dVars, dParams []float64) {
+	var x, k, theta = vars[0], params[0], params[1]
+	dVars = []float64{(k-1)/x - 1/theta}
+	dParams = []float64{
+		math.Log(x) - Digamma(k) - math.Log(theta),
+		x/(theta*theta) - k/theta,
+	}
+	return
+}
+
+// Score each row of vars given params, writing the scores to out
+func (dist Gamma) ScoreBatch(vars [][]float64, params []float64, out []float64) {
+	dist.LogScoreBatch(vars, params, out)
+	for i, v := range out {
+		out[i] = math.Exp(v)
+	}
+}
+
+// Score each row of vars given params, writing the natural log of the scores
+// to out
+func (dist Gamma) LogScoreBatch(vars [][]float64, params []float64, out []float64) {
+	var (
+		k, theta  = params[0], params[1]
+		lgamma, _ = math.Lgamma(k)
+		norm      = -lgamma - k*math.Log(theta)
+	)
+	for i, row := range vars {
+		if x := row[0]; x > 0 {
+			out[i] = (k-1)*math.Log(x) - x/theta + norm
+		} else {
+			// The density at and below zero has special cases
+			out[i] = math.Log(Gamma{Shape: k, Scale: theta}.PDF(x))
+		}
+	}
+}
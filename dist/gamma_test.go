@@ -0,0 +1,78 @@
+package dist
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+func TestGamma(t *testing.T) {
+	Convey("Test Gamma interfaces", t, func() {
+		dist := NewGammaDist(1, 1)
+		So(dist, ShouldImplement, (*Dist)(nil))
+		So(dist, ShouldImplement, (*RealDist)(nil))
+		So(dist, ShouldImplement, (*ContinuousDist)(nil))
+		So(dist, ShouldImplement, (*Differentiable)(nil))
+
+		space := dist.Space()
+		So(space, ShouldImplement, (*Space)(nil))
+		So(space, ShouldImplement, (*RealSpace)(nil))
+	})
+
+	Convey("Test Gamma dist", t, func() {
+		dist := NewGammaDist(1, 2)
+		So(dist.NumVars(), ShouldEqual, 1)
+		So(dist.NumParams(), ShouldEqual, 2)
+		So(dist.PDF(1), ShouldAlmostEqual, math.Exp(-0.5)/2)
+		So(dist.Score([]float64{1}, []float64{2, 1}), ShouldAlmostEqual, math.Exp(-1))
+		dist.SetParams([]float64{2, 1})
+		So(dist.PDF(1), ShouldAlmostEqual, math.Exp(-1))
+	})
+
+	Convey("Test Gamma PDF", t, func() {
+		exp := NewGammaDist(1, 0.5)
+		So(exp.PDF(0), ShouldAlmostEqual, 2)
+		So(exp.PDF(0.3), ShouldAlmostEqual, 2*math.Exp(-0.6))
+		So(exp.PDF(-1), ShouldEqual, 0)
+
+		gamma := NewGammaDist(2, 1)
+		So(gamma.PDF(0), ShouldEqual, 0)
+		So(gamma.PDF(0.5), ShouldAlmostEqual, 0.5*math.Exp(-0.5))
+		So(gamma.PDF(3), ShouldAlmostEqual, 3*math.Exp(-3))
+
+		So(NewGammaDist(0.5, 1).PDF(0), ShouldEqual, math.Inf(+1))
+	})
+
+	Convey("Test Gamma CDF", t, func() {
+		exp := NewGammaDist(1, 0.5)
+		So(exp.CDF(0), ShouldEqual, 0)
+		So(exp.CDF(0.3), ShouldAlmostEqual, 1-math.Exp(-0.6))
+		So(exp.CDF(math.Inf(+1)), ShouldEqual, 1)
+
+		gamma := NewGammaDist(2, 1)
+		So(gamma.CDF(0.5), ShouldAlmostEqual, 1-1.5*math.Exp(-0.5))
+		So(gamma.CDF(3), ShouldAlmostEqual, 1-4*math.Exp(-3))
+		So(gamma.CDF(20), ShouldAlmostEqual, 1-21*math.Exp(-20))
+	})
+
+	Convey("Test Gamma moments", t, func() {
+		gamma := NewGammaDist(3, 0.5)
+		So(gamma.Mean(), ShouldAlmostEqual, 1.5)
+		So(gamma.Mode(), ShouldAlmostEqual, 1)
+		So(gamma.Variance(), ShouldAlmostEqual, 0.75)
+		So(func() { NewGammaDist(0.5, 1).Mode() }, ShouldPanic)
+	})
+
+	Convey("Test Gamma draws", t, func() {
+		const n = 100
+		gamma := NewGammaDist(2, 3)
+		mean := 0.0
+		for _, v := range gamma.SampleN(n) {
+			mean += v
+		}
+		mean /= n
+
+		std := math.Sqrt(gamma.Variance())
+		So(mean, ShouldBeBetween, gamma.Mean()-std, gamma.Mean()+std)
+	})
+}
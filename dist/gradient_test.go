@@ -0,0 +1,84 @@
+package dist
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+// Estimate the partial derivative of f at x[i] by central differences
+func numericPartial(f func(x []float64) float64, x []float64, i int) float64 {
+	const h = 1e-6
+	var (
+		above = append([]float64(nil), x...)
+		below = append([]float64(nil), x...)
+	)
+	above[i] += h
+	below[i] -= h
+	return (f(above) - f(below)) / (2 * h)
+}
+
+// Compare a continuous distribution's analytic gradient to numeric estimates
+func checkGradient(d Differentiable, vars, params []float64) {
+	dVars, dParams := d.LogScoreGrad(vars, params)
+	So(d.LogScore(vars, params), ShouldAlmostEqual, math.Log(d.Score(vars, params)))
+	So(dVars, ShouldHaveLength, d.NumVars())
+	So(dParams, ShouldHaveLength, d.NumParams())
+	for i := range vars {
+		So(dVars[i], ShouldAlmostEqual, numericPartial(func(x []float64) float64 {
+			return d.LogScore(x, params)
+		}, vars, i), 1e-5)
+	}
+	for i := range params {
+		So(dParams[i], ShouldAlmostEqual, numericPartial(func(p []float64) float64 {
+			return d.LogScore(vars, p)
+		}, params, i), 1e-5)
+	}
+}
+
+func TestLogScoreGrad(t *testing.T) {
+	Convey("Test Normal gradient", t, func() {
+		So(NewStandardNormalDist(), ShouldImplement, (*Differentiable)(nil))
+		checkGradient(NewStandardNormalDist(), []float64{0.3}, []float64{-1, 2})
+		checkGradient(NewStandardNormalDist(), []float64{5}, []float64{4.5, 0.3})
+	})
+
+	Convey("Test Beta gradient", t, func() {
+		So(NewBetaDist(1, 1), ShouldImplement, (*Differentiable)(nil))
+		checkGradient(NewBetaDist(1, 1), []float64{0.3}, []float64{2, 5})
+		checkGradient(NewBetaDist(1, 1), []float64{0.9}, []float64{0.5, 0.5})
+	})
+
+	Convey("Test Gamma gradient", t, func() {
+		checkGradient(NewGammaDist(1, 1), []float64{0.3}, []float64{2, 5})
+		checkGradient(NewGammaDist(1, 1), []float64{4}, []float64{0.5, 1.5})
+	})
+
+	Convey("Test Bernoulli gradient", t, func() {
+		dist := NewBernoulliDist(0.5)
+		So(dist, ShouldImplement, (*Differentiable)(nil))
+		for _, x := range []float64{0, 1} {
+			dVars, dParams := dist.LogScoreGrad([]float64{x}, []float64{0.3})
+			So(dVars, ShouldResemble, []float64{0})
+			So(dParams[0], ShouldAlmostEqual, numericPartial(func(p []float64) float64 {
+				return dist.LogScore([]float64{x}, p)
+			}, []float64{0.3}, 0), 1e-5)
+		}
+	})
+
+	Convey("Test DenseMutableDiscreteDist gradient", t, func() {
+		dist := NewDenseMutableDiscreteDist(BooleanSpace)
+		So(dist, ShouldImplement, (*Differentiable)(nil))
+		dVars, dParams := dist.LogScoreGrad([]float64{1}, []float64{0.2, 0.8})
+		So(dVars, ShouldResemble, []float64{0})
+		So(dParams, ShouldResemble, []float64{0, 1.25})
+	})
+
+	Convey("Test Digamma", t, func() {
+		So(Digamma(1), ShouldAlmostEqual, -0.5772156649015329)
+		So(Digamma(0.5), ShouldAlmostEqual, -1.9635100260214235)
+		So(Digamma(10), ShouldAlmostEqual, 2.251752589066721)
+		So(Digamma(-0.5), ShouldAlmostEqual, 0.03648997397857652)
+		So(math.IsNaN(Digamma(0)), ShouldBeTrue)
+	})
+}
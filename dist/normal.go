@@ -91,5 +91,53 @@ func (dist Normal) Variance() float64 {
 
 // Sample an outcome from the distribution
 func (dist Normal) Sample() float64 {
-	return dist.Mu + rand.NormFloat64()/dist.Sigma
+	return dist.Mu + rand.NormFloat64()*dist.Sigma
+}
+
+// Sample an outcome from the distribution using the given source of randomness
+func (dist Normal) SampleRand(rng *rand.Rand) float64 {
+	return dist.Mu + rng.NormFloat64()*dist.Sigma
+}
+
+// Return the natural log of Score() for the given values
+func (dist Normal) LogScore(vars, params []float64) float64 {
+	var (
+		x, mu, sigma = vars[0], params[0], params[1]
+		z            = (x - mu) / sigma
+	)
+	return -z*z/2 - math.Log(sigma) - math.Log(2*math.Pi)/2
+}
+
+// Return the gradient of LogScore() with respect to the variables and
+// with respect to the parameters
+func (dist Normal) LogScoreGrad(vars, params []float64) (dVars, dParams []float64) {
+	var (
+		x, mu, sigma = vars[0], params[0], params[1]
+		diff         = x - mu
+		variance     = sigma * sigma
+	)
+	dVars = []float64{-diff / variance}
+	dParams = []float64{diff / variance, diff*diff/(variance*sigma) - 1/sigma}
+	return
+}
+
+// Score each row of vars given params, writing the scores to out
+func (dist Normal) ScoreBatch(vars [][]float64, params []float64, out []float64) {
+	dist.LogScoreBatch(vars, params, out)
+	for i, v := range out {
+		out[i] = math.Exp(v)
+	}
+}
+
+// Score each row of vars given params, writing the natural log of the scores
+// to out
+func (dist Normal) LogScoreBatch(vars [][]float64, params []float64, out []float64) {
+	var (
+		mu, sigma = params[0], params[1]
+		norm      = -math.Log(sigma) - math.Log(2*math.Pi)/2
+	)
+	for i, row := range vars {
+		z := (row[0] - mu) / sigma
+		out[i] = norm - z*z/2
+	}
 }
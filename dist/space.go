@@ -3,6 +3,7 @@ package dist
 import (
 	"github.com/jesand/stats"
 	"math"
+	"sort"
 )
 
 // An ID for a particular outcome in a space
@@ -13,6 +14,9 @@ type Space interface {
 
 	// Ask whether the space is the same as some other space
 	Equals(other Space) bool
+
+	// Ask whether a value belongs to the space
+	Contains(value float64) bool
 }
 
 // Methods contained by spaces over real values
@@ -47,6 +51,11 @@ func (sp positiveRealSpace) Sup() float64 {
 	return math.Inf(+1)
 }
 
+// Ask whether a value belongs to the space
+func (sp positiveRealSpace) Contains(value float64) bool {
+	return value > 0
+}
+
 // Ask whether the space is the same as some other space
 func (sp positiveRealSpace) Equals(other Space) bool {
 	if _, ok := other.(*positiveRealSpace); ok {
@@ -94,6 +103,213 @@ func (sp RealIntervalSpace) Equals(other Space) bool {
 	return sp.Min == ris.Min && sp.Max == ris.Max
 }
 
+// Ask whether a value belongs to the space
+func (sp RealIntervalSpace) Contains(value float64) bool {
+	return sp.Min <= value && value <= sp.Max
+}
+
+// Ask whether the interval contains no values
+func (sp RealIntervalSpace) IsEmpty() bool {
+	return !(sp.Min <= sp.Max)
+}
+
+// Return the interval of values in both spaces. The result may be empty.
+func (sp RealIntervalSpace) Intersect(other RealIntervalSpace) RealIntervalSpace {
+	return RealIntervalSpace{
+		Min: math.Max(sp.Min, other.Min),
+		Max: math.Min(sp.Max, other.Max),
+	}
+}
+
+// Return the values in either space. This is a RealIntervalSpace if the
+// intervals overlap or touch, and a *RealUnionSpace otherwise.
+func (sp RealIntervalSpace) Union(other RealIntervalSpace) RealSpace {
+	return NewRealUnionSpace(sp, other).Simplify()
+}
+
+// Return the Cartesian product of this interval with others
+func (sp RealIntervalSpace) Product(others ...RealIntervalSpace) *RealBoxSpace {
+	return NewRealBoxSpace(append([]RealIntervalSpace{sp}, others...)...)
+}
+
+// Create a new union of closed intervals. Overlapping intervals are merged and
+// empty intervals are dropped.
+func NewRealUnionSpace(intervals ...RealIntervalSpace) *RealUnionSpace {
+	var sorted []RealIntervalSpace
+	for _, iv := range intervals {
+		if !iv.IsEmpty() {
+			sorted = append(sorted, iv)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Min < sorted[j].Min })
+	var merged []RealIntervalSpace
+	for _, iv := range sorted {
+		if last := len(merged) - 1; last >= 0 && iv.Min <= merged[last].Max {
+			merged[last].Max = math.Max(merged[last].Max, iv.Max)
+		} else {
+			merged = append(merged, iv)
+		}
+	}
+	return &RealUnionSpace{Intervals: merged}
+}
+
+// A subset of the reals formed by a union of disjoint closed intervals, sorted
+// in increasing order
+type RealUnionSpace struct {
+	Intervals []RealIntervalSpace
+}
+
+// The infimum (min) value in the space, or positive infinity if empty
+func (sp RealUnionSpace) Inf() float64 {
+	if len(sp.Intervals) == 0 {
+		return math.Inf(+1)
+	}
+	return sp.Intervals[0].Min
+}
+
+// The supremum (max) value in the space, or negative infinity if empty
+func (sp RealUnionSpace) Sup() float64 {
+	if len(sp.Intervals) == 0 {
+		return math.Inf(-1)
+	}
+	return sp.Intervals[len(sp.Intervals)-1].Max
+}
+
+// Ask whether a value belongs to the space
+func (sp RealUnionSpace) Contains(value float64) bool {
+	for _, iv := range sp.Intervals {
+		if iv.Contains(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ask whether the space is the same as some other space
+func (sp RealUnionSpace) Equals(other Space) bool {
+	var sp2 *RealUnionSpace
+	if s, ok := other.(*RealUnionSpace); ok {
+		sp2 = s
+	} else if s, ok := other.(RealUnionSpace); ok {
+		sp2 = &s
+	} else {
+		return false
+	}
+	if len(sp.Intervals) != len(sp2.Intervals) {
+		return false
+	}
+	for i, iv := range sp.Intervals {
+		if !iv.Equals(sp2.Intervals[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the values in either space
+func (sp RealUnionSpace) Union(other RealIntervalSpace) *RealUnionSpace {
+	return NewRealUnionSpace(append(append([]RealIntervalSpace(nil), sp.Intervals...),
+		other)...)
+}
+
+// Return the values in both spaces
+func (sp RealUnionSpace) Intersect(other RealIntervalSpace) *RealUnionSpace {
+	var intervals []RealIntervalSpace
+	for _, iv := range sp.Intervals {
+		intervals = append(intervals, iv.Intersect(other))
+	}
+	return NewRealUnionSpace(intervals...)
+}
+
+// Return a RealIntervalSpace if the union is a single interval, and the union
+// itself otherwise
+func (sp *RealUnionSpace) Simplify() RealSpace {
+	if len(sp.Intervals) == 1 {
+		return sp.Intervals[0]
+	}
+	return sp
+}
+
+// Create the Cartesian product of a sequence of intervals
+func NewRealBoxSpace(intervals ...RealIntervalSpace) *RealBoxSpace {
+	return &RealBoxSpace{
+		Intervals: append([]RealIntervalSpace(nil), intervals...),
+	}
+}
+
+// A subset of R^n formed by the Cartesian product of closed intervals
+type RealBoxSpace struct {
+	Intervals []RealIntervalSpace
+}
+
+// The number of dimensions of the space
+func (sp RealBoxSpace) Dims() int {
+	return len(sp.Intervals)
+}
+
+// Ask whether a value belongs to the space. Only a one-dimensional box
+// contains single values; use ContainsPoint() for points in R^n.
+func (sp RealBoxSpace) Contains(value float64) bool {
+	return sp.ContainsPoint([]float64{value})
+}
+
+// Ask whether the space is the same as some other space
+func (sp RealBoxSpace) Equals(other Space) bool {
+	var sp2 *RealBoxSpace
+	if s, ok := other.(*RealBoxSpace); ok {
+		sp2 = s
+	} else if s, ok := other.(RealBoxSpace); ok {
+		sp2 = &s
+	} else {
+		return false
+	}
+	if sp.Dims() != sp2.Dims() {
+		return false
+	}
+	for i, iv := range sp.Intervals {
+		if !iv.Equals(sp2.Intervals[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Ask whether a point belongs to the space
+func (sp RealBoxSpace) ContainsPoint(point []float64) bool {
+	if len(point) != len(sp.Intervals) {
+		return false
+	}
+	for i, iv := range sp.Intervals {
+		if !iv.Contains(point[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the points in both boxes, which must have the same dimensions
+func (sp RealBoxSpace) Intersect(other *RealBoxSpace) *RealBoxSpace {
+	if sp.Dims() != other.Dims() {
+		panic(stats.Errorf("Cannot intersect spaces of %d and %d dimensions",
+			sp.Dims(), other.Dims()))
+	}
+	var box = &RealBoxSpace{Intervals: make([]RealIntervalSpace, sp.Dims())}
+	for i, iv := range sp.Intervals {
+		box.Intervals[i] = iv.Intersect(other.Intervals[i])
+	}
+	return box
+}
+
+// Ask whether the box contains no points
+func (sp RealBoxSpace) IsEmpty() bool {
+	for _, iv := range sp.Intervals {
+		if iv.IsEmpty() {
+			return true
+		}
+	}
+	return false
+}
+
 // The space of all reals
 var AllRealSpace = RealIntervalSpace{Min: math.Inf(-1), Max: math.Inf(+1)}
 
@@ -139,6 +355,11 @@ func (sp booleanSpace) Sup() float64 {
 	return 1
 }
 
+// Ask whether a value belongs to the space
+func (sp booleanSpace) Contains(value float64) bool {
+	return value == 0 || value == 1
+}
+
 // Ask whether the space is the same as some other space
 func (sp booleanSpace) Equals(other Space) bool {
 	if _, ok := other.(*booleanSpace); ok {
@@ -186,6 +407,65 @@ func (sp booleanSpace) BoolOutcome(value bool) Outcome {
 	}
 }
 
+// Create a new space over the integers from min to max, inclusive
+func NewIntegerIntervalSpace(min, max int) IntegerIntervalSpace {
+	return IntegerIntervalSpace{Min: min, Max: max}
+}
+
+// A sample space over a closed interval of integers. The outcomes are numbered
+// from zero, so outcome i has the value Min+i.
+type IntegerIntervalSpace struct {
+	Min, Max int
+}
+
+// The infimum (min) value in the space, or negative infinity
+func (sp IntegerIntervalSpace) Inf() float64 {
+	return float64(sp.Min)
+}
+
+// The supremum (max) value in the space, or positive infinity
+func (sp IntegerIntervalSpace) Sup() float64 {
+	return float64(sp.Max)
+}
+
+// Ask whether a value belongs to the space
+func (sp IntegerIntervalSpace) Contains(value float64) bool {
+	return value == math.Trunc(value) && value >= float64(sp.Min) &&
+		value <= float64(sp.Max)
+}
+
+// Ask whether the space is the same as some other space
+func (sp IntegerIntervalSpace) Equals(other Space) bool {
+	if sp2, ok := other.(*IntegerIntervalSpace); ok {
+		return sp == *sp2
+	} else if sp2, ok := other.(IntegerIntervalSpace); ok {
+		return sp == sp2
+	}
+	return false
+}
+
+// Return the cardinality of the space
+func (sp IntegerIntervalSpace) Size() int {
+	if sp.Max < sp.Min {
+		return 0
+	}
+	return sp.Max - sp.Min + 1
+}
+
+// The real value of an outcome
+func (sp IntegerIntervalSpace) F64Value(outcome Outcome) float64 {
+	return float64(sp.Min + int(outcome))
+}
+
+// The outcome corresponding to a real value. Panics if the value is not in
+// the space.
+func (sp IntegerIntervalSpace) Outcome(value float64) Outcome {
+	if !sp.Contains(value) {
+		panic(stats.ErrfValNotInDomain(value))
+	}
+	return Outcome(int(value) - sp.Min)
+}
+
 // A discrete space over arbitrary objects
 type DiscreteObjectSpace struct {
 
@@ -214,6 +494,11 @@ func (sp DiscreteObjectSpace) Equals(other Space) bool {
 	return true
 }
 
+// Ask whether a value is the ID of an outcome in the space
+func (sp DiscreteObjectSpace) Contains(value float64) bool {
+	return value == math.Trunc(value) && value >= 0 && value < float64(len(sp.Objects))
+}
+
 // Returns the number of outcomes in the space if finite, and
 // returns -1 if infinite.
 func (sp DiscreteObjectSpace) Size() int {
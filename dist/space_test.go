@@ -2,6 +2,7 @@ package dist
 
 import (
 	. "github.com/smartystreets/goconvey/convey"
+	"math"
 	"testing"
 )
 
@@ -48,6 +49,28 @@ func TestBooleanSpace(t *testing.T) {
 	})
 }
 
+func TestIntegerIntervalSpace(t *testing.T) {
+	var sp = NewIntegerIntervalSpace(-1, 3)
+	Convey("Test IntegerIntervalSpace interfaces", t, func() {
+		So(sp, ShouldImplement, (*Space)(nil))
+		So(sp, ShouldImplement, (*DiscreteRealSpace)(nil))
+	})
+	Convey("Test IntegerIntervalSpace outcomes", t, func() {
+		So(sp.Size(), ShouldEqual, 5)
+		So(sp.Inf(), ShouldEqual, -1)
+		So(sp.Sup(), ShouldEqual, 3)
+		So(sp.F64Value(0), ShouldEqual, -1)
+		So(sp.F64Value(4), ShouldEqual, 3)
+		So(sp.Outcome(2), ShouldEqual, 3)
+		So(func() { sp.Outcome(4) }, ShouldPanic)
+		So(sp.Contains(0), ShouldBeTrue)
+		So(sp.Contains(0.5), ShouldBeFalse)
+		So(sp.Equals(NewIntegerIntervalSpace(-1, 3)), ShouldBeTrue)
+		So(sp.Equals(NewIntegerIntervalSpace(0, 3)), ShouldBeFalse)
+		So(NewIntegerIntervalSpace(1, 0).Size(), ShouldEqual, 0)
+	})
+}
+
 func TestDiscreteObjectSpace(t *testing.T) {
 	var objects = []interface{}{0, 1, 2, 3, 4, 5}
 	var sp = DiscreteObjectSpace{Objects: objects}
@@ -56,3 +79,88 @@ func TestDiscreteObjectSpace(t *testing.T) {
 		So(sp, ShouldImplement, (*DiscreteSpace)(nil))
 	})
 }
+
+func TestSpaceContains(t *testing.T) {
+	Convey("Test PositiveRealSpace.Contains()", t, func() {
+		So(PositiveRealSpace.Contains(1e-9), ShouldBeTrue)
+		So(PositiveRealSpace.Contains(0), ShouldBeFalse)
+		So(PositiveRealSpace.Contains(-1), ShouldBeFalse)
+	})
+	Convey("Test RealIntervalSpace.Contains()", t, func() {
+		So(UnitIntervalSpace.Contains(0), ShouldBeTrue)
+		So(UnitIntervalSpace.Contains(1), ShouldBeTrue)
+		So(UnitIntervalSpace.Contains(1.7), ShouldBeFalse)
+		So(UnitIntervalSpace.Contains(math.NaN()), ShouldBeFalse)
+		So(AllRealSpace.Contains(math.Inf(+1)), ShouldBeTrue)
+	})
+	Convey("Test BooleanSpace.Contains()", t, func() {
+		So(BooleanSpace.Contains(0), ShouldBeTrue)
+		So(BooleanSpace.Contains(1), ShouldBeTrue)
+		So(BooleanSpace.Contains(2), ShouldBeFalse)
+		So(BooleanSpace.Contains(0.5), ShouldBeFalse)
+	})
+	Convey("Test DiscreteObjectSpace.Contains()", t, func() {
+		sp := DiscreteObjectSpace{Objects: []interface{}{"a", "b", "c"}}
+		So(sp.Contains(0), ShouldBeTrue)
+		So(sp.Contains(2), ShouldBeTrue)
+		So(sp.Contains(3), ShouldBeFalse)
+		So(sp.Contains(1.5), ShouldBeFalse)
+		So(sp.Contains(-1), ShouldBeFalse)
+	})
+}
+
+func TestIntervalOperations(t *testing.T) {
+	var (
+		a = RealIntervalSpace{Min: 0, Max: 2}
+		b = RealIntervalSpace{Min: 1, Max: 3}
+		c = RealIntervalSpace{Min: 5, Max: 6}
+	)
+	Convey("Test RealIntervalSpace.Intersect()", t, func() {
+		So(a.Intersect(b), ShouldResemble, RealIntervalSpace{Min: 1, Max: 2})
+		So(a.Intersect(c).IsEmpty(), ShouldBeTrue)
+		So(a.Intersect(AllRealSpace), ShouldResemble, a)
+	})
+	Convey("Test RealIntervalSpace.Union()", t, func() {
+		So(a.Union(b), ShouldResemble, RealIntervalSpace{Min: 0, Max: 3})
+
+		u := a.Union(c)
+		So(u, ShouldHaveSameTypeAs, (*RealUnionSpace)(nil))
+		So(u.Inf(), ShouldEqual, 0)
+		So(u.Sup(), ShouldEqual, 6)
+		So(u.Contains(1), ShouldBeTrue)
+		So(u.Contains(4), ShouldBeFalse)
+		So(u.Contains(5.5), ShouldBeTrue)
+		So(u.Equals(NewRealUnionSpace(c, a)), ShouldBeTrue)
+		So(u.Equals(a), ShouldBeFalse)
+	})
+	Convey("Test RealUnionSpace operations", t, func() {
+		u := NewRealUnionSpace(a, c, RealIntervalSpace{Min: 1, Max: 0})
+		So(u.Intervals, ShouldResemble, []RealIntervalSpace{a, c})
+		So(u.Union(b).Intervals, ShouldResemble, []RealIntervalSpace{{Min: 0, Max: 3}, c})
+		So(u.Intersect(RealIntervalSpace{Min: 1, Max: 5.5}).Intervals,
+			ShouldResemble, []RealIntervalSpace{{Min: 1, Max: 2}, {Min: 5, Max: 5.5}})
+		So(NewRealUnionSpace().Contains(0), ShouldBeFalse)
+	})
+	Convey("Test RealIntervalSpace.Product()", t, func() {
+		box := a.Product(b, c)
+		So(box.Dims(), ShouldEqual, 3)
+		So(box.ContainsPoint([]float64{1, 1, 5}), ShouldBeTrue)
+		So(box.ContainsPoint([]float64{1, 4, 5}), ShouldBeFalse)
+		So(box.ContainsPoint([]float64{1, 1}), ShouldBeFalse)
+		So(box.IsEmpty(), ShouldBeFalse)
+		So(box, ShouldImplement, (*Space)(nil))
+		So(box.Equals(a.Product(b, c)), ShouldBeTrue)
+		So(box.Equals(*a.Product(b, c)), ShouldBeTrue)
+		So(box.Equals(a.Product(b)), ShouldBeFalse)
+		So(box.Equals(a.Product(b, b)), ShouldBeFalse)
+		So(box.Equals(a), ShouldBeFalse)
+		So(box.Contains(1), ShouldBeFalse)
+		So(NewRealBoxSpace(b).Contains(1.5), ShouldBeTrue)
+		So(NewRealBoxSpace(b).Contains(4), ShouldBeFalse)
+
+		inter := box.Intersect(NewRealBoxSpace(b, b, b))
+		So(inter.Intervals[0], ShouldResemble, RealIntervalSpace{Min: 1, Max: 2})
+		So(inter.IsEmpty(), ShouldBeTrue)
+		So(func() { box.Intersect(NewRealBoxSpace(a)) }, ShouldPanic)
+	})
+}
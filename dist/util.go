@@ -1,5 +1,9 @@
 package dist
 
+import (
+	"math"
+)
+
 // Compute the sum of an array of values
 func Sum(x []float64) float64 {
 	var total float64
@@ -92,3 +96,35 @@ func Variance(x []float64) float64 {
 	}
 	return total / (float64(len(x)) - 1)
 }
+
+// Compute the digamma function, the derivative of the log of the Gamma
+// function. Small arguments are shifted upwards with the recurrence
+// psi(x) = psi(x+1) - 1/x before applying the asymptotic expansion.
+func Digamma(x float64) float64 {
+	if math.IsNaN(x) || math.IsInf(x, -1) {
+		return math.NaN()
+	} else if x <= 0 && x == math.Floor(x) {
+		return math.NaN()
+	} else if x < 0 {
+		// Reflection: psi(1-x) - psi(x) = pi*cot(pi*x)
+		return Digamma(1-x) - math.Pi/math.Tan(math.Pi*x)
+	}
+	var result float64
+	for x < 6 {
+		result -= 1 / x
+		x++
+	}
+	var f = 1 / (x * x)
+	return result + math.Log(x) - 0.5/x -
+		f*(1.0/12-f*(1.0/120-f*(1.0/252-f*(1.0/240-f*(1.0/132)))))
+}
+
+// Compute the natural log of the Beta function, B(a, b)
+func LogBeta(a, b float64) float64 {
+	var (
+		la, _  = math.Lgamma(a)
+		lb, _  = math.Lgamma(b)
+		lab, _ = math.Lgamma(a + b)
+	)
+	return la + lb - lab
+}
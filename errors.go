@@ -11,6 +11,8 @@ const (
 	ErrDiscreteOnly   Error = "This process currently only supports discrete random variables"
 	ErrContinuousOnly Error = "This process currently only supports continuous random variables"
 	ErrBernoulliOnly  Error = "This process only supports Bernoulli random variables"
+	ErrObserved       Error = "The random variable is observed and cannot be changed"
+	ErrGraphCycle     Error = "The network has a directed cycle"
 )
 
 func ErrfNotInDomain(outcome int) Error {
@@ -34,6 +36,30 @@ func ErrfUnsupportedDist(dist interface{}) Error {
 	return Errorf("Unsupported distribution type %T", dist)
 }
 
+func ErrfVarNotInGraph(v interface{}) Error {
+	return Errorf("Random variable %v not in factor graph", v)
+}
+
+func ErrfVarNotInNetwork(v interface{}) Error {
+	return Errorf("Random variable %v not in the network", v)
+}
+
+func ErrfVarNotInFactor(v interface{}) Error {
+	return Errorf("Random variable %v not adjacent to the factor", v)
+}
+
+func ErrfInfiniteSpace(v interface{}) Error {
+	return Errorf("Random variable %v does not have a finite space", v)
+}
+
+func ErrfTableSize(expected, actual int) Error {
+	return Errorf("Table expected %d value(s), but has %d", expected, actual)
+}
+
+func ErrfNotDifferentiable(value interface{}) Error {
+	return Errorf("Type %T is not differentiable", value)
+}
+
 // An error message
 type Error string
 
@@ -0,0 +1,288 @@
+package factor
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/variable"
+)
+
+// A connected component of a factor graph
+type Component struct {
+	Variables []variable.RandomVariable
+	Factors   []Factor
+}
+
+// Summary statistics for the number of distinct neighbors of each node
+type DegreeStats struct {
+	MinVarDegree, MaxVarDegree       int
+	MeanVarDegree                    float64
+	MinFactorDegree, MaxFactorDegree int
+	MeanFactorDegree                 float64
+}
+
+// Get the indices in graph.Variables of the distinct variables adjacent to
+// each factor, in the order of graph.Factors
+func (graph FactorGraph) factorVarIdx() [][]int {
+	var adj = make([][]int, len(graph.Factors))
+	for fi, f := range graph.Factors {
+		for _, v := range f.Adjacent() {
+			vi, ok := graph.varIds[v]
+			if !ok {
+				panic(stats.ErrfVarNotInGraph(v))
+			}
+			var seen bool
+			for _, other := range adj[fi] {
+				seen = seen || other == vi
+			}
+			if !seen {
+				adj[fi] = append(adj[fi], vi)
+			}
+		}
+	}
+	return adj
+}
+
+// Get the indices in graph.Factors of the distinct factors adjacent to each
+// variable, in the order of graph.Variables
+func (graph FactorGraph) varFactorIdx(factorVars [][]int) [][]int {
+	var adj = make([][]int, len(graph.Variables))
+	for fi, vars := range factorVars {
+		for _, vi := range vars {
+			adj[vi] = append(adj[vi], fi)
+		}
+	}
+	return adj
+}
+
+// Split the graph into its connected components. Components are ordered by
+// their first variable in graph.Variables, and factors with no variables
+// each form their own component at the end.
+func (graph FactorGraph) Components() []Component {
+	var (
+		factorVars = graph.factorVarIdx()
+		varFactors = graph.varFactorIdx(factorVars)
+		varSeen    = make([]bool, len(graph.Variables))
+		factorSeen = make([]bool, len(graph.Factors))
+		comps      []Component
+	)
+	for start := range graph.Variables {
+		if varSeen[start] {
+			continue
+		}
+		var (
+			comp  Component
+			queue = []int{start}
+		)
+		varSeen[start] = true
+		for len(queue) > 0 {
+			vi := queue[0]
+			queue = queue[1:]
+			comp.Variables = append(comp.Variables, graph.Variables[vi].Variable)
+			for _, fi := range varFactors[vi] {
+				if factorSeen[fi] {
+					continue
+				}
+				factorSeen[fi] = true
+				comp.Factors = append(comp.Factors, graph.Factors[fi])
+				for _, other := range factorVars[fi] {
+					if !varSeen[other] {
+						varSeen[other] = true
+						queue = append(queue, other)
+					}
+				}
+			}
+		}
+		comps = append(comps, comp)
+	}
+	for fi, f := range graph.Factors {
+		if !factorSeen[fi] {
+			comps = append(comps, Component{Factors: []Factor{f}})
+		}
+	}
+	return comps
+}
+
+// Ask whether the graph has a cycle. A factor adjacent to the same variable
+// more than once does not form a cycle.
+func (graph FactorGraph) HasCycle() bool {
+	// A forest has one fewer edge than nodes in each component
+	var edges int
+	for _, vars := range graph.factorVarIdx() {
+		edges += len(vars)
+	}
+	var nodes = len(graph.Variables) + len(graph.Factors)
+	return edges > nodes-len(graph.Components())
+}
+
+// Ask whether the graph is a tree: connected, and without cycles
+func (graph FactorGraph) IsTree() bool {
+	return len(graph.Components()) <= 1 && !graph.HasCycle()
+}
+
+// Get statistics for the number of distinct neighbors of each variable and
+// factor
+func (graph FactorGraph) DegreeStats() DegreeStats {
+	var (
+		ds         DegreeStats
+		factorVars = graph.factorVarIdx()
+		varFactors = graph.varFactorIdx(factorVars)
+	)
+	ds.MinVarDegree, ds.MaxVarDegree, ds.MeanVarDegree = degreeStats(varFactors)
+	ds.MinFactorDegree, ds.MaxFactorDegree, ds.MeanFactorDegree = degreeStats(factorVars)
+	return ds
+}
+
+// Get the min, max and mean length of a list of adjacency lists
+func degreeStats(adj [][]int) (min, max int, mean float64) {
+	for i, nbrs := range adj {
+		if i == 0 || len(nbrs) < min {
+			min = len(nbrs)
+		}
+		if len(nbrs) > max {
+			max = len(nbrs)
+		}
+		mean += float64(len(nbrs))
+	}
+	if len(adj) > 0 {
+		mean /= float64(len(adj))
+	}
+	return
+}
+
+// Get a variable elimination order chosen greedily by the min-degree
+// heuristic, and its induced width
+func (graph FactorGraph) MinDegreeOrder() ([]variable.RandomVariable, int) {
+	return graph.eliminationOrder(func(p *primalGraph, v int) float64 {
+		return float64(len(p.adj[v]))
+	})
+}
+
+// Get a variable elimination order chosen greedily by the min-fill heuristic,
+// which eliminates the variable adding the fewest new edges, and its induced
+// width
+func (graph FactorGraph) MinFillOrder() ([]variable.RandomVariable, int) {
+	return graph.eliminationOrder(func(p *primalGraph, v int) float64 {
+		return float64(p.fill(v))
+	})
+}
+
+// Get the induced width of an elimination order: the size of the largest
+// set of neighbors a variable has when it is eliminated. The order must
+// contain each variable in the graph exactly once.
+func (graph FactorGraph) InducedWidth(order []variable.RandomVariable) int {
+	var (
+		p     = graph.primalGraph()
+		width int
+	)
+	if len(order) != len(graph.Variables) {
+		panic(stats.Errorf("Elimination order has %d variable(s), but the graph has %d",
+			len(order), len(graph.Variables)))
+	}
+	for _, v := range order {
+		vi, ok := graph.varIds[v]
+		if !ok {
+			panic(stats.ErrfVarNotInGraph(v))
+		}
+		if len(p.adj[vi]) > width {
+			width = len(p.adj[vi])
+		}
+		p.eliminate(vi)
+	}
+	return width
+}
+
+// Get an upper bound on the treewidth of the graph: the smaller induced
+// width of the min-degree and min-fill elimination orders
+func (graph FactorGraph) TreewidthUpperBound() int {
+	var (
+		_, w1 = graph.MinDegreeOrder()
+		_, w2 = graph.MinFillOrder()
+	)
+	if w1 < w2 {
+		return w1
+	}
+	return w2
+}
+
+// Greedily build an elimination order, always eliminating the variable with
+// the lowest cost and breaking ties by position in graph.Variables
+func (graph FactorGraph) eliminationOrder(cost func(p *primalGraph, v int) float64) (
+	[]variable.RandomVariable, int) {
+
+	var (
+		p     = graph.primalGraph()
+		done  = make([]bool, len(graph.Variables))
+		order []variable.RandomVariable
+		width int
+	)
+	for range graph.Variables {
+		var (
+			best     = -1
+			bestCost float64
+		)
+		for vi := range graph.Variables {
+			if done[vi] {
+				continue
+			}
+			if c := cost(p, vi); best < 0 || c < bestCost {
+				best, bestCost = vi, c
+			}
+		}
+		if len(p.adj[best]) > width {
+			width = len(p.adj[best])
+		}
+		order = append(order, graph.Variables[best].Variable)
+		done[best] = true
+		p.eliminate(best)
+	}
+	return order, width
+}
+
+// The primal (moral) graph of a factor graph: variables are adjacent if they
+// share a factor. Vertices are indices into graph.Variables.
+type primalGraph struct {
+	adj []map[int]bool
+}
+
+// Build the primal graph
+func (graph FactorGraph) primalGraph() *primalGraph {
+	var p = &primalGraph{adj: make([]map[int]bool, len(graph.Variables))}
+	for vi := range p.adj {
+		p.adj[vi] = make(map[int]bool)
+	}
+	for _, vars := range graph.factorVarIdx() {
+		for _, v1 := range vars {
+			for _, v2 := range vars {
+				if v1 != v2 {
+					p.adj[v1][v2] = true
+				}
+			}
+		}
+	}
+	return p
+}
+
+// Count the edges which eliminating a vertex would add between its neighbors
+func (p *primalGraph) fill(v int) int {
+	var fill int
+	for n1 := range p.adj[v] {
+		for n2 := range p.adj[v] {
+			if n1 < n2 && !p.adj[n1][n2] {
+				fill++
+			}
+		}
+	}
+	return fill
+}
+
+// Eliminate a vertex, connecting all of its neighbors
+func (p *primalGraph) eliminate(v int) {
+	for n1 := range p.adj[v] {
+		delete(p.adj[n1], v)
+		for n2 := range p.adj[v] {
+			if n1 != n2 {
+				p.adj[n1][n2] = true
+			}
+		}
+	}
+	p.adj[v] = nil
+}
@@ -0,0 +1,113 @@
+package factor
+
+import (
+	"bytes"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+// Build a graph with a pairwise factor between each listed pair of variables
+func pairGraph(numVars int, pairs [][2]int) (*FactorGraph, []variable.RandomVariable) {
+	var (
+		graph = NewFactorGraph()
+		vars  []variable.RandomVariable
+	)
+	for i := 0; i < numVars; i++ {
+		v := variable.NewDiscreteRV(0, dist.BooleanSpace)
+		vars = append(vars, v)
+		graph.AddFactor(NewConstFactor([]variable.RandomVariable{v}, 0.5))
+	}
+	for _, p := range pairs {
+		graph.AddFactor(NewConstFactor([]variable.RandomVariable{vars[p[0]], vars[p[1]]}, 0.5))
+	}
+	return graph, vars
+}
+
+func TestGraphAnalysis(t *testing.T) {
+	Convey("Test connected components", t, func() {
+		graph, vars := pairGraph(5, [][2]int{{0, 1}, {1, 2}, {3, 4}})
+		graph.AddFactor(NewConstFactor(nil, 0.5))
+		comps := graph.Components()
+		So(comps, ShouldHaveLength, 3)
+		So(comps[0].Variables, ShouldResemble, vars[:3])
+		So(comps[0].Factors, ShouldHaveLength, 5)
+		So(comps[1].Variables, ShouldResemble, vars[3:])
+		So(comps[2].Variables, ShouldBeEmpty)
+		So(comps[2].Factors, ShouldHaveLength, 1)
+	})
+
+	Convey("Test cycle detection", t, func() {
+		chain, _ := pairGraph(4, [][2]int{{0, 1}, {1, 2}, {2, 3}})
+		So(chain.HasCycle(), ShouldBeFalse)
+		So(chain.IsTree(), ShouldBeTrue)
+
+		forest, _ := pairGraph(4, [][2]int{{0, 1}, {2, 3}})
+		So(forest.HasCycle(), ShouldBeFalse)
+		So(forest.IsTree(), ShouldBeFalse)
+
+		loop, _ := pairGraph(3, [][2]int{{0, 1}, {1, 2}, {2, 0}})
+		So(loop.HasCycle(), ShouldBeTrue)
+		So(loop.IsTree(), ShouldBeFalse)
+
+		x := variable.NewDiscreteRV(0, dist.BooleanSpace)
+		repeated := NewFactorGraph()
+		repeated.AddFactor(NewConstFactor([]variable.RandomVariable{x, x}, 0.5))
+		So(repeated.IsTree(), ShouldBeTrue)
+	})
+
+	Convey("Test degree statistics", t, func() {
+		graph, _ := pairGraph(3, [][2]int{{0, 1}, {0, 2}})
+		ds := graph.DegreeStats()
+		So(ds.MinVarDegree, ShouldEqual, 2)
+		So(ds.MaxVarDegree, ShouldEqual, 3)
+		So(ds.MeanVarDegree, ShouldAlmostEqual, 7.0/3)
+		So(ds.MinFactorDegree, ShouldEqual, 1)
+		So(ds.MaxFactorDegree, ShouldEqual, 2)
+		So(ds.MeanFactorDegree, ShouldAlmostEqual, 7.0/5)
+	})
+
+	Convey("Test elimination orders and treewidth bounds", t, func() {
+		// A 3x3 grid has treewidth 3
+		var pairs [][2]int
+		for r := 0; r < 3; r++ {
+			for c := 0; c < 3; c++ {
+				if c < 2 {
+					pairs = append(pairs, [2]int{3*r + c, 3*r + c + 1})
+				}
+				if r < 2 {
+					pairs = append(pairs, [2]int{3*r + c, 3*r + c + 3})
+				}
+			}
+		}
+		grid, vars := pairGraph(9, pairs)
+		order, width := grid.MinFillOrder()
+		So(order, ShouldHaveLength, 9)
+		So(width, ShouldEqual, 3)
+		So(grid.InducedWidth(order), ShouldEqual, width)
+		order, width = grid.MinDegreeOrder()
+		So(width, ShouldBeGreaterThanOrEqualTo, 3)
+		So(grid.InducedWidth(order), ShouldEqual, width)
+		So(grid.TreewidthUpperBound(), ShouldEqual, 3)
+
+		// Eliminating the center of a star first connects all the leaves
+		star, vars := pairGraph(5, [][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}})
+		So(star.InducedWidth(vars), ShouldEqual, 4)
+		So(star.TreewidthUpperBound(), ShouldEqual, 1)
+		So(func() { star.InducedWidth(vars[:2]) }, ShouldPanic)
+	})
+
+	Convey("Test DOT export", t, func() {
+		graph, vars := pairGraph(2, [][2]int{{0, 1}})
+		vars[0].(*variable.DiscreteRV).SetName("x")
+		vars[1].(*variable.DiscreteRV).ObserveOutcome(1)
+		var buf bytes.Buffer
+		So(graph.WriteDOT(&buf, DOTOptions{ShowValues: true, ShowScores: true}), ShouldBeNil)
+		So(buf.String(), ShouldStartWith, "graph \"G\" {\n")
+		So(buf.String(), ShouldContainSubstring, "v0 [shape=circle, label=\"x\\n0\"];")
+		So(buf.String(), ShouldContainSubstring, "v1 [shape=circle, style=filled, fillcolor=gray")
+		So(buf.String(), ShouldContainSubstring, "f2 [shape=square, label=\"f2\\n0.5\"];")
+		So(buf.String(), ShouldContainSubstring, "v1 -- f2;")
+	})
+}
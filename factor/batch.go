@@ -0,0 +1,103 @@
+package factor
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	"math"
+	"reflect"
+)
+
+// A group of DistFactors which share a distribution and parameter variables,
+// such as the factors produced by an IIDProcess. The group is scored with a
+// single batch call into the distribution, reusing its buffers between calls.
+type distBatch struct {
+	dist    dist.Dist
+	params  []variable.RandomVariable
+	factors []*DistFactor
+	index   []int
+
+	// Buffers for variable values, parameter values and log scores
+	rows      [][]float64
+	paramVals []float64
+	out       []float64
+}
+
+// Ask whether a DistFactor can be scored as part of a batch, returning its
+// parameter variables if so
+func batchParams(factor *DistFactor) ([]variable.RandomVariable, bool) {
+	if factor.Dist == nil || !reflect.TypeOf(factor.Dist).Comparable() {
+		return nil, false
+	}
+	var numVars, numParams = factor.Dist.NumVars(), factor.Dist.NumParams()
+	if len(factor.Vars) != numVars+numParams {
+		return nil, false
+	}
+	return factor.Vars[numVars:], true
+}
+
+// Ask whether the batch holds factors with the given distribution and
+// parameter variables
+func (batch *distBatch) matches(d dist.Dist, params []variable.RandomVariable) bool {
+	if batch.dist != d || len(batch.params) != len(params) {
+		return false
+	}
+	for i, p := range params {
+		if batch.params[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// Add a factor to the batch, given its index in graph.Factors
+func (batch *distBatch) add(factor *DistFactor, idx int) {
+	var numVars = batch.dist.NumVars()
+	batch.factors = append(batch.factors, factor)
+	batch.index = append(batch.index, idx)
+	batch.rows = append(batch.rows, make([]float64, numVars))
+	batch.out = append(batch.out, 0)
+}
+
+// Ask whether every factor still has the batch's distribution and parameter
+// variables, which may have been replaced since the factor was added
+func (batch *distBatch) current() bool {
+	var numVars = batch.dist.NumVars()
+	for _, factor := range batch.factors {
+		if len(factor.Vars) != numVars+len(batch.params) ||
+			!batch.matches(factor.Dist, factor.Vars[numVars:]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Score all factors in the batch, leaving the log score of each factor in
+// batch.out and returning their sum. If a factor's distribution or parameters
+// have been replaced, each factor is scored on its own.
+func (batch *distBatch) logScore() float64 {
+	if !batch.current() {
+		var score float64
+		for i, factor := range batch.factors {
+			batch.out[i] = math.Log(factor.Score())
+			score += batch.out[i]
+		}
+		return score
+	}
+	if batch.paramVals == nil {
+		batch.paramVals = make([]float64, len(batch.params))
+	}
+	for i, p := range batch.params {
+		batch.paramVals[i] = p.Val()
+	}
+	for i, factor := range batch.factors {
+		for j := range batch.rows[i] {
+			batch.rows[i][j] = factor.Vars[j].Val()
+		}
+	}
+	dist.LogScoreBatch(batch.dist, batch.rows, batch.paramVals, batch.out)
+	var score float64
+	for _, s := range batch.out {
+		score += s
+	}
+	return score
+}
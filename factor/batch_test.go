@@ -0,0 +1,112 @@
+package factor
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+// Build a graph of Bernoulli observations sharing a bias variable
+func bernoulliGraph(n int) (*FactorGraph, *variable.ContinuousRV) {
+	var (
+		bias  = variable.NewContinuousRV(0.3, dist.UnitIntervalSpace)
+		bern  = dist.NewBernoulliDist(0.3)
+		graph = NewFactorGraph()
+	)
+	for i := 0; i < n; i++ {
+		rv := variable.NewDiscreteRV(dist.Outcome(i%3%2), dist.BooleanSpace)
+		graph.AddFactor(NewDistFactor([]variable.RandomVariable{rv, bias}, bern))
+	}
+	return graph, bias
+}
+
+func TestBatchScoring(t *testing.T) {
+	Convey("Given a graph of Bernoulli observations and a Beta prior", t, func() {
+		var (
+			graph, bias = bernoulliGraph(30)
+			alpha       = variable.NewContinuousRV(2, dist.PositiveRealSpace)
+			beta        = variable.NewContinuousRV(3, dist.PositiveRealSpace)
+		)
+		graph.AddFactor(NewDistFactor([]variable.RandomVariable{bias, alpha, beta},
+			dist.NewBetaDist(1, 1)))
+		graph.AddFactor(NewConstFactor([]variable.RandomVariable{bias}, 0.5))
+
+		naive := func() float64 {
+			var score float64
+			for _, f := range graph.Factors {
+				score += math.Log(f.Score())
+			}
+			return score
+		}
+
+		Convey("Factors are grouped by distribution and parameters", func() {
+			So(graph.batches, ShouldHaveLength, 2)
+			So(graph.batches[0].factors, ShouldHaveLength, 30)
+			So(graph.unbatched, ShouldHaveLength, 1)
+		})
+
+		Convey("The batched score matches the per-factor score", func() {
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			bias.Set(0.8)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			graph.Variables[0].Variable.(*variable.DiscreteRV).SetOutcome(1)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+		})
+
+		Convey("Directly modified factors are still scored", func() {
+			graph.Factors = append(graph.Factors, NewConstFactor(nil, 0.25))
+			So(graph.Score(), ShouldAlmostEqual, naive())
+		})
+	})
+
+	Convey("Batched scoring does not allocate", t, func() {
+		graph, _ := bernoulliGraph(1000)
+		graph.Score()
+		So(testing.AllocsPerRun(10, func() { graph.Score() }), ShouldEqual, 0)
+	})
+
+	Convey("Every built-in distribution scores batches consistently", t, func() {
+		var cases = []struct {
+			dist   dist.Dist
+			vars   [][]float64
+			params []float64
+		}{
+			{dist.NewStandardNormalDist(), [][]float64{{-1}, {0.5}, {3}}, []float64{0.5, 2}},
+			{dist.NewBetaDist(1, 1), [][]float64{{0.1}, {0.5}, {0.7}}, []float64{2, 5}},
+			{dist.NewGammaDist(1, 1), [][]float64{{0.1}, {2}, {7}}, []float64{3, 0.5}},
+			{dist.NewGammaDist(1, 1), [][]float64{{0}, {1}}, []float64{1, 2}},
+			{dist.NewGammaDist(1, 1), [][]float64{{-1}, {1}}, []float64{3, 2}},
+			{dist.NewBetaDist(1, 1), [][]float64{{-0.5}, {1.5}, {0.5}}, []float64{2, 5}},
+			{dist.NewBernoulliDist(0.5), [][]float64{{0}, {1}, {1}}, []float64{0.2}},
+			{dist.NewDenseMutableDiscreteDist(dist.BooleanSpace), [][]float64{{0}, {1}}, []float64{0.4, 0.6}},
+		}
+		for _, c := range cases {
+			var (
+				scores    = make([]float64, len(c.vars))
+				logScores = make([]float64, len(c.vars))
+			)
+			So(c.dist, ShouldImplement, (*dist.BatchDist)(nil))
+			dist.ScoreBatch(c.dist, c.vars, c.params, scores)
+			dist.LogScoreBatch(c.dist, c.vars, c.params, logScores)
+			for i, row := range c.vars {
+				So(scores[i], ShouldAlmostEqual, c.dist.Score(row, c.params))
+				if want := math.Log(c.dist.Score(row, c.params)); math.IsInf(want, -1) {
+					So(logScores[i], ShouldEqual, want)
+				} else {
+					So(logScores[i], ShouldAlmostEqual, want)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkBernoulliGraphScore(b *testing.B) {
+	graph, _ := bernoulliGraph(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		graph.Score()
+	}
+}
@@ -0,0 +1,186 @@
+package factor
+
+import (
+	"github.com/jesand/stats/variable"
+	"math"
+	"sync"
+)
+
+// A factor whose score depends only on the values of its adjacent variables,
+// and not on any other state, such as a table of values. A FactorGraph caches
+// the scores of pure factors until their variables change, and rescores other
+// factors every time it is scored.
+type PureFactor interface {
+	Factor
+
+	// Ask whether the score depends only on the adjacent variables' values
+	IsPure() bool
+}
+
+// Ask whether a factor is a PureFactor which reports that it is pure
+func isPure(f Factor) bool {
+	pf, ok := f.(PureFactor)
+	return ok && pf.IsPure()
+}
+
+// The log score of each factor in a graph, indexed like graph.Factors. Once
+// the graph is first scored, the cache listens for changes to the graph's
+// variables, so that Score() only rescores the factors adjacent to variables
+// which have changed.
+type scoreCache struct {
+
+	// Guards the cache, so that copies of a graph can be scored concurrently
+	mu sync.Mutex
+
+	logScores []float64
+	dirty     []bool
+	dirtyIdx  []int
+
+	// The sum of logScores, and the number of incremental updates made to it
+	// since it was last summed from scratch, to bound rounding error
+	total   float64
+	updates int
+
+	// The indices of the distinct factors adjacent to each variable
+	varFactors map[variable.RandomVariable][]int
+
+	// The indices of the factors rescored on every update: those which are
+	// not pure, or have a variable which cannot notify the cache of changes
+	volatile []int
+
+	// Whether the cache is subscribed to its variables, and whether it is
+	// ignoring their changes while a value is probed
+	attached, muted bool
+
+	// The mutation count of the graph which last added a factor, so that
+	// stale copies of the graph do not use the cache
+	version int
+}
+
+// Create an empty score cache
+func newScoreCache() *scoreCache {
+	return &scoreCache{
+		varFactors: make(map[variable.RandomVariable][]int),
+	}
+}
+
+// Add the factor with the given index, subscribing to its new variables if
+// the cache is attached
+func (cache *scoreCache) add(idx int, factor Factor) {
+	cache.logScores = append(cache.logScores, 0)
+	cache.dirty = append(cache.dirty, false)
+	cache.markDirty(idx)
+	var volatile = !isPure(factor)
+	for _, v := range factor.Adjacent() {
+		nv, notifies := v.(variable.NotifyingVariable)
+		volatile = volatile || !notifies
+		fs, ok := cache.varFactors[v]
+		if ok && fs[len(fs)-1] == idx {
+			continue
+		} else if !ok && notifies && cache.attached {
+			nv.Subscribe(cache)
+		}
+		cache.varFactors[v] = append(fs, idx)
+	}
+	if volatile {
+		cache.volatile = append(cache.volatile, idx)
+	}
+}
+
+// Subscribe to changes to the variables, if not already subscribed. Every
+// factor is rescored, since changes made while detached were missed.
+func (cache *scoreCache) attach() {
+	if cache.attached {
+		return
+	}
+	for v := range cache.varFactors {
+		if nv, ok := v.(variable.NotifyingVariable); ok {
+			nv.Subscribe(cache)
+		}
+	}
+	cache.attached = true
+	cache.markAllDirty()
+}
+
+// Stop listening for changes to the variables
+func (cache *scoreCache) detach() {
+	for v := range cache.varFactors {
+		if nv, ok := v.(variable.NotifyingVariable); ok {
+			nv.Unsubscribe(cache)
+		}
+	}
+	cache.attached = false
+}
+
+// Mark a factor as needing to be rescored
+func (cache *scoreCache) markDirty(idx int) {
+	if !cache.dirty[idx] {
+		cache.dirty[idx] = true
+		cache.dirtyIdx = append(cache.dirtyIdx, idx)
+	}
+}
+
+// Mark all factors as needing to be rescored
+func (cache *scoreCache) markAllDirty() {
+	for idx := range cache.dirty {
+		cache.markDirty(idx)
+	}
+}
+
+// Mark the factors adjacent to a changed variable as needing to be rescored
+func (cache *scoreCache) VariableChanged(v variable.RandomVariable) {
+	if cache.muted {
+		return
+	}
+	for _, idx := range cache.varFactors[v] {
+		cache.markDirty(idx)
+	}
+}
+
+// Bring the cached scores up to date, attaching the cache if needed. The
+// caller must hold the lock.
+func (cache *scoreCache) refresh(graph FactorGraph) {
+	cache.attach()
+	for _, idx := range cache.volatile {
+		cache.markDirty(idx)
+	}
+	if len(cache.dirtyIdx) > 0 {
+		cache.update(graph)
+	}
+}
+
+// Rescore the dirty factors of a graph and update the total. If most factors
+// are dirty, they are all rescored in batches.
+func (cache *scoreCache) update(graph FactorGraph) {
+	var full = len(cache.dirtyIdx) > len(cache.logScores)/2
+	if full {
+		for _, batch := range graph.batches {
+			batch.logScore()
+			for i, idx := range batch.index {
+				cache.logScores[idx] = batch.out[i]
+			}
+		}
+		for i, factor := range graph.unbatched {
+			cache.logScores[graph.unbatchedIdx[i]] = math.Log(factor.Score())
+		}
+	} else {
+		for _, idx := range cache.dirtyIdx {
+			s := math.Log(graph.Factors[idx].Score())
+			cache.total += s - cache.logScores[idx]
+			cache.logScores[idx] = s
+		}
+		cache.updates += len(cache.dirtyIdx)
+	}
+	for _, idx := range cache.dirtyIdx {
+		cache.dirty[idx] = false
+	}
+	cache.dirtyIdx = cache.dirtyIdx[:0]
+
+	// Resum periodically, or if an infinite score has left a NaN total
+	if full || cache.updates > len(cache.logScores) || math.IsNaN(cache.total) {
+		cache.total, cache.updates = 0, 0
+		for _, s := range cache.logScores {
+			cache.total += s
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package factor
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+// A factor which counts how often it is scored
+type countingFactor struct {
+	*DistFactor
+	count int
+}
+
+func (factor *countingFactor) Score() float64 {
+	factor.count++
+	return factor.DistFactor.Score()
+}
+
+// A variable with only the methods every RandomVariable has
+type bareVariable struct {
+	val float64
+}
+
+func (v *bareVariable) Val() float64    { return v.val }
+func (v *bareVariable) Set(val float64) { v.val = val }
+
+func (v *bareVariable) Equals(other variable.RandomVariable) bool {
+	return other.Val() == v.val
+}
+
+func TestScoreCache(t *testing.T) {
+	Convey("Given a chain of Normal factors", t, func() {
+		var (
+			graph   = NewFactorGraph()
+			sigma   = variable.NewContinuousRV(1, dist.PositiveRealSpace)
+			vars    []*variable.ContinuousRV
+			factors []*countingFactor
+		)
+		for i := 0; i < 5; i++ {
+			vars = append(vars, variable.NewContinuousRV(float64(i), dist.AllRealSpace))
+		}
+		for i := 1; i < len(vars); i++ {
+			f := &countingFactor{DistFactor: NewDistFactor([]variable.RandomVariable{
+				vars[i], vars[i-1], sigma}, dist.NewStandardNormalDist())}
+			factors = append(factors, f)
+			graph.AddFactor(f)
+		}
+		naive := func() float64 {
+			var score float64
+			for _, f := range factors {
+				score += math.Log(f.DistFactor.Score())
+			}
+			return score
+		}
+		counts := func() []int {
+			var c []int
+			for _, f := range factors {
+				c = append(c, f.count)
+				f.count = 0
+			}
+			return c
+		}
+
+		Convey("Only factors adjacent to changed variables are rescored", func() {
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			So(counts(), ShouldResemble, []int{1, 1, 1, 1})
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			So(counts(), ShouldResemble, []int{0, 0, 0, 0})
+
+			vars[0].Set(0.5)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			So(counts(), ShouldResemble, []int{1, 0, 0, 0})
+			vars[2].Set(1.5)
+			vars[2].Set(2.5)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			So(counts(), ShouldResemble, []int{0, 1, 1, 0})
+		})
+
+		Convey("ScoreDelta matches the change in Score", func() {
+			before := graph.Score()
+			delta := graph.ScoreDelta(vars[3], -1)
+			So(vars[3].Val(), ShouldEqual, 3)
+			So(graph.Score(), ShouldAlmostEqual, before)
+			vars[3].Set(-1)
+			So(graph.Score()-before, ShouldAlmostEqual, delta)
+			So(func() { graph.ScoreDelta(variable.NewContinuousRV(0, dist.AllRealSpace), 1) }, ShouldPanic)
+
+			vars[3].Observe(3)
+			So(graph.ScoreDelta(vars[3], -1), ShouldAlmostEqual, delta)
+			So(vars[3].IsObserved(), ShouldBeTrue)
+			So(vars[3].Val(), ShouldEqual, 3)
+		})
+
+		Convey("ScoreDelta leaves the cached scores clean", func() {
+			graph.Score()
+			counts()
+			graph.ScoreDelta(vars[2], 5)
+			So(counts(), ShouldResemble, []int{0, 1, 1, 0})
+			So(graph.cache.dirtyIdx, ShouldBeEmpty)
+			graph.Score()
+			So(counts(), ShouldResemble, []int{0, 0, 0, 0})
+		})
+
+		Convey("Setting a distribution's own parameters does not change the score", func() {
+			before := graph.Score()
+			factors[0].Dist.SetParams([]float64{10, 3})
+			So(graph.Score(), ShouldAlmostEqual, before)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+		})
+
+		Convey("A closed graph stops listening until it is scored again", func() {
+			graph.Score()
+			graph.Close()
+			vars[1].Set(7)
+			So(graph.cache.dirtyIdx, ShouldBeEmpty)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			counts()
+			vars[4].Set(7)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			So(counts(), ShouldResemble, []int{0, 0, 0, 1})
+		})
+
+		Convey("Copies of the graph can be scored concurrently", func() {
+			var (
+				want   = naive()
+				scores = make(chan float64)
+			)
+			for i := 0; i < 4; i++ {
+				go func(graph FactorGraph) { scores <- graph.Score() }(*graph)
+			}
+			for i := 0; i < 4; i++ {
+				So(<-scores, ShouldAlmostEqual, want)
+			}
+		})
+
+		Convey("Invalidate rescores every factor", func() {
+			graph.Score()
+			counts()
+			graph.Invalidate()
+			graph.Score()
+			So(counts(), ShouldResemble, []int{1, 1, 1, 1})
+		})
+	})
+
+	Convey("Invalidate picks up a replaced distribution in a batch", t, func() {
+		var (
+			graph   = NewFactorGraph()
+			alpha   = variable.NewContinuousRV(2, dist.PositiveRealSpace)
+			beta    = variable.NewContinuousRV(3, dist.PositiveRealSpace)
+			normal  = dist.NewStandardNormalDist()
+			factors []*DistFactor
+		)
+		for i := 0; i < 3; i++ {
+			x := variable.NewContinuousRV(0.25*float64(i+1), dist.AllRealSpace)
+			f := NewDistFactor([]variable.RandomVariable{x, alpha, beta}, normal)
+			factors = append(factors, f)
+			graph.AddFactor(f)
+		}
+		naive := func() float64 {
+			var score float64
+			for _, f := range factors {
+				score += math.Log(f.Score())
+			}
+			return score
+		}
+		So(graph.batches, ShouldHaveLength, 1)
+		So(graph.Score(), ShouldAlmostEqual, naive())
+		factors[1].Dist = dist.NewBetaDist(1, 1)
+		graph.Invalidate()
+		So(graph.Score(), ShouldAlmostEqual, naive())
+	})
+
+	Convey("Variables notify their listeners of changes", t, func() {
+		var (
+			graph = NewFactorGraph()
+			x     = variable.NewContinuousRV(0, dist.AllRealSpace)
+		)
+		graph.AddFactor(NewConstFactor([]variable.RandomVariable{x, x}, 0.5))
+		So(graph.cache.varFactors[x], ShouldResemble, []int{0})
+		graph.Score()
+		So(graph.cache.dirtyIdx, ShouldBeEmpty)
+		x.Set(1)
+		So(graph.cache.dirtyIdx, ShouldResemble, []int{0})
+	})
+
+	Convey("Factors which are not pure are rescored every time", t, func() {
+		var (
+			graph = NewFactorGraph()
+			x     = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			table = NewTableFactor([]*variable.DiscreteRV{x}, []float64{1, 3})
+		)
+		graph.AddFactor(table)
+		So(graph.Score(), ShouldAlmostEqual, 0)
+		table.Normalize()
+		So(graph.Score(), ShouldAlmostEqual, math.Log(0.25))
+		table.Values[0] = 0.5
+		So(graph.Score(), ShouldAlmostEqual, math.Log(0.5))
+		So(graph.ScoreDelta(x, 1), ShouldAlmostEqual, math.Log(0.75/0.5))
+	})
+
+	Convey("Variables which cannot notify are rescored every time", t, func() {
+		var (
+			graph = NewFactorGraph()
+			x     = &bareVariable{val: 0.5}
+			sigma = variable.NewContinuousRV(1, dist.PositiveRealSpace)
+			mu    = variable.NewContinuousRV(0, dist.AllRealSpace)
+			f     = NewDistFactor([]variable.RandomVariable{x, mu, sigma}, dist.NewStandardNormalDist())
+		)
+		graph.AddFactor(f)
+		So(graph.Score(), ShouldAlmostEqual, math.Log(f.Score()))
+		x.Set(2)
+		So(graph.Score(), ShouldAlmostEqual, math.Log(f.Score()))
+		So(graph.ScoreDelta(x, 0.5), ShouldAlmostEqual, 2-0.125)
+		So(graph.LatentVariables(), ShouldContain, variable.RandomVariable(x))
+		_, ok := graph.VariableByID(0)
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestScoreDeltaCost(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test")
+	}
+	Convey("The cost of ScoreDelta does not grow with the graph", t, func() {
+		nsPerOp := func(n int) int64 {
+			graph, _ := bernoulliGraph(n)
+			v := graph.Variables[0].Variable
+			graph.Score()
+			return testing.Benchmark(func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					graph.ScoreDelta(v, float64(i%2))
+				}
+			}).NsPerOp()
+		}
+
+		// A cost linear in the graph size would grow by a factor of 1000
+		small, large := nsPerOp(100), nsPerOp(100000)
+		So(large, ShouldBeLessThan, 10*small+1000)
+	})
+}
+
+func BenchmarkBernoulliGraphRescore(b *testing.B) {
+	graph, _ := bernoulliGraph(100000)
+	v := graph.Variables[0].Variable
+	graph.Score()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v.Set(float64(i % 2))
+		graph.Score()
+	}
+}
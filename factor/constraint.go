@@ -0,0 +1,528 @@
+package factor
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+)
+
+// A hard constraint on the values of some discrete variables, which scores
+// one when it is satisfied and zero otherwise. Constraints can compute their
+// sum-product messages directly, without enumerating joint outcomes.
+type Constraint interface {
+	DifferentiableFactor
+
+	// Ask whether the variables' current values satisfy the constraint
+	Satisfied() bool
+
+	// Given a message from each adjacent variable over its outcomes, in the
+	// order given by Adjacent(), compute the unnormalized message to each
+	// adjacent variable. Messages from observed variables are ignored, and
+	// their observed outcomes are used instead.
+	Messages(in [][]float64) [][]float64
+}
+
+// Create a constraint that all the variables have the same outcome. The
+// variables must share the same space.
+func NewEqualityFactor(vars ...*variable.DiscreteRV) *EqualityFactor {
+	for _, v := range vars[1:] {
+		if !v.Space().Equals(vars[0].Space()) {
+			panic(stats.Errorf("Random variables %v and %v have different spaces", vars[0], v))
+		}
+	}
+	return &EqualityFactor{Vars: vars}
+}
+
+// A constraint that all the variables have the same outcome
+type EqualityFactor struct {
+	Vars []*variable.DiscreteRV
+}
+
+// The adjacent random variables
+func (factor EqualityFactor) Adjacent() []variable.RandomVariable {
+	return discreteAdjacent(factor.Vars)
+}
+
+// One if the constraint is satisfied, and zero otherwise
+func (factor EqualityFactor) Score() float64 {
+	return constraintScore(factor)
+}
+
+// The gradient of the log score, which is zero for discrete variables
+func (factor EqualityFactor) LogScoreGrad() []float64 {
+	return make([]float64, len(factor.Vars))
+}
+
+// Ask whether all the variables have the same outcome
+func (factor EqualityFactor) Satisfied() bool {
+	for _, v := range factor.Vars[1:] {
+		if v.Outcome() != factor.Vars[0].Outcome() {
+			return false
+		}
+	}
+	return true
+}
+
+// Compute the message to each variable: the product of the other variables'
+// messages for each outcome
+func (factor EqualityFactor) Messages(in [][]float64) [][]float64 {
+	in = evidenceMessages(factor.Vars, in)
+	var out = make([][]float64, len(factor.Vars))
+	for i, v := range factor.Vars {
+		out[i] = make([]float64, v.Space().Size())
+		for o := range out[i] {
+			out[i][o] = 1
+			for j := range factor.Vars {
+				if j != i {
+					out[i][o] *= in[j][o]
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Create a constraint that a boolean variable is the negation of another
+func NewNotFactor(in, out *variable.DiscreteRV) *NotFactor {
+	return &NotFactor{In: in, Out: out}
+}
+
+// A constraint that a boolean variable is the negation of another
+type NotFactor struct {
+	In, Out *variable.DiscreteRV
+}
+
+// The adjacent random variables: the input, then the output
+func (factor NotFactor) Adjacent() []variable.RandomVariable {
+	return []variable.RandomVariable{factor.In, factor.Out}
+}
+
+// One if the constraint is satisfied, and zero otherwise
+func (factor NotFactor) Score() float64 {
+	return constraintScore(factor)
+}
+
+// The gradient of the log score, which is zero for discrete variables
+func (factor NotFactor) LogScoreGrad() []float64 {
+	return make([]float64, 2)
+}
+
+// Ask whether the output is the negation of the input
+func (factor NotFactor) Satisfied() bool {
+	return factor.Out.Val() == 1-factor.In.Val()
+}
+
+// Compute the message to each variable: the other variable's message,
+// reversed
+func (factor NotFactor) Messages(in [][]float64) [][]float64 {
+	in = evidenceMessages([]*variable.DiscreteRV{factor.In, factor.Out}, in)
+	return [][]float64{
+		{in[1][1], in[1][0]},
+		{in[0][1], in[0][0]},
+	}
+}
+
+// Create a constraint that an odd (or even) number of boolean variables are
+// true
+func NewParityFactor(odd bool, vars ...*variable.DiscreteRV) *ParityFactor {
+	return &ParityFactor{Vars: vars, Odd: odd}
+}
+
+// Create a constraint that a boolean output is the exclusive or of the
+// inputs. This is an even parity constraint over the inputs and output.
+func NewXORFactor(out *variable.DiscreteRV, inputs ...*variable.DiscreteRV) *ParityFactor {
+	return NewParityFactor(false, append(append([]*variable.DiscreteRV(nil), inputs...), out)...)
+}
+
+// A constraint that an odd (or even) number of boolean variables are true
+type ParityFactor struct {
+	Vars []*variable.DiscreteRV
+	Odd  bool
+}
+
+// The adjacent random variables
+func (factor ParityFactor) Adjacent() []variable.RandomVariable {
+	return discreteAdjacent(factor.Vars)
+}
+
+// One if the constraint is satisfied, and zero otherwise
+func (factor ParityFactor) Score() float64 {
+	return constraintScore(factor)
+}
+
+// The gradient of the log score, which is zero for discrete variables
+func (factor ParityFactor) LogScoreGrad() []float64 {
+	return make([]float64, len(factor.Vars))
+}
+
+// Ask whether the number of true variables has the right parity
+func (factor ParityFactor) Satisfied() bool {
+	return factor.ok(sumVals(factor.Vars), -1)
+}
+
+// Compute the message to each variable from the distribution of the number
+// of other variables which are true
+func (factor ParityFactor) Messages(in [][]float64) [][]float64 {
+	return sumMessages(factor.Vars, nil, evidenceMessages(factor.Vars, in), factor.ok)
+}
+
+// Ask whether a number of true variables has the right parity
+func (factor ParityFactor) ok(count float64, _ dist.Outcome) bool {
+	return (int(count)%2 == 1) == factor.Odd
+}
+
+// Create a constraint that a boolean output is the logical and of the inputs
+func NewAndFactor(out *variable.DiscreteRV, inputs ...*variable.DiscreteRV) *LogicFactor {
+	return &LogicFactor{Inputs: inputs, Out: out, Or: false}
+}
+
+// Create a constraint that a boolean output is the logical or of the inputs
+func NewOrFactor(out *variable.DiscreteRV, inputs ...*variable.DiscreteRV) *LogicFactor {
+	return &LogicFactor{Inputs: inputs, Out: out, Or: true}
+}
+
+// A constraint that a boolean output is the logical and (or or) of some
+// boolean inputs
+type LogicFactor struct {
+	Inputs []*variable.DiscreteRV
+	Out    *variable.DiscreteRV
+	Or     bool
+}
+
+// The adjacent random variables: the inputs, then the output
+func (factor LogicFactor) Adjacent() []variable.RandomVariable {
+	return append(discreteAdjacent(factor.Inputs), factor.Out)
+}
+
+// One if the constraint is satisfied, and zero otherwise
+func (factor LogicFactor) Score() float64 {
+	return constraintScore(factor)
+}
+
+// The gradient of the log score, which is zero for discrete variables
+func (factor LogicFactor) LogScoreGrad() []float64 {
+	return make([]float64, len(factor.Inputs)+1)
+}
+
+// Ask whether the output matches the inputs
+func (factor LogicFactor) Satisfied() bool {
+	return factor.ok(sumVals(factor.Inputs), factor.Out.Outcome())
+}
+
+// Compute the message to each variable from the distribution of the number
+// of true inputs
+func (factor LogicFactor) Messages(in [][]float64) [][]float64 {
+	var vars = append(append([]*variable.DiscreteRV(nil), factor.Inputs...), factor.Out)
+	return sumMessages(factor.Inputs, factor.Out, evidenceMessages(vars, in), factor.ok)
+}
+
+// Ask whether a number of true inputs is consistent with an output
+func (factor LogicFactor) ok(count float64, out dist.Outcome) bool {
+	var result = count == float64(len(factor.Inputs))
+	if factor.Or {
+		result = count > 0
+	}
+	return result == (factor.Out.Space().F64Value(out) == 1)
+}
+
+// Create a constraint that exactly k of the boolean variables are true
+func NewExactlyKFactor(k int, vars ...*variable.DiscreteRV) *CardinalityFactor {
+	return &CardinalityFactor{Vars: vars, Min: k, Max: k}
+}
+
+// Create a constraint that at most k of the boolean variables are true
+func NewAtMostKFactor(k int, vars ...*variable.DiscreteRV) *CardinalityFactor {
+	return &CardinalityFactor{Vars: vars, Min: 0, Max: k}
+}
+
+// A constraint that between Min and Max of the boolean variables are true,
+// inclusive
+type CardinalityFactor struct {
+	Vars     []*variable.DiscreteRV
+	Min, Max int
+}
+
+// The adjacent random variables
+func (factor CardinalityFactor) Adjacent() []variable.RandomVariable {
+	return discreteAdjacent(factor.Vars)
+}
+
+// One if the constraint is satisfied, and zero otherwise
+func (factor CardinalityFactor) Score() float64 {
+	return constraintScore(factor)
+}
+
+// The gradient of the log score, which is zero for discrete variables
+func (factor CardinalityFactor) LogScoreGrad() []float64 {
+	return make([]float64, len(factor.Vars))
+}
+
+// Ask whether the right number of variables are true
+func (factor CardinalityFactor) Satisfied() bool {
+	return factor.ok(sumVals(factor.Vars), -1)
+}
+
+// Compute the message to each variable from the distribution of the number
+// of other variables which are true
+func (factor CardinalityFactor) Messages(in [][]float64) [][]float64 {
+	return sumMessages(factor.Vars, nil, evidenceMessages(factor.Vars, in), factor.ok)
+}
+
+// Ask whether a number of true variables is in range
+func (factor CardinalityFactor) ok(count float64, _ dist.Outcome) bool {
+	return count >= float64(factor.Min) && count <= float64(factor.Max)
+}
+
+// Create a constraint that the values of some integer variables sum to the
+// value of another. Use an observed total to constrain the sum to a constant.
+func NewSumFactor(total *variable.DiscreteRV, terms ...*variable.DiscreteRV) *SumFactor {
+	return &SumFactor{Terms: terms, Total: total}
+}
+
+// A constraint that the values of some integer variables sum to the value of
+// another
+type SumFactor struct {
+	Terms []*variable.DiscreteRV
+	Total *variable.DiscreteRV
+}
+
+// The adjacent random variables: the terms, then the total
+func (factor SumFactor) Adjacent() []variable.RandomVariable {
+	return append(discreteAdjacent(factor.Terms), factor.Total)
+}
+
+// One if the constraint is satisfied, and zero otherwise
+func (factor SumFactor) Score() float64 {
+	return constraintScore(factor)
+}
+
+// The gradient of the log score, which is zero for discrete variables
+func (factor SumFactor) LogScoreGrad() []float64 {
+	return make([]float64, len(factor.Terms)+1)
+}
+
+// Ask whether the terms sum to the total
+func (factor SumFactor) Satisfied() bool {
+	return factor.ok(sumVals(factor.Terms), factor.Total.Outcome())
+}
+
+// Compute the message to each variable from the distribution of the sum of
+// the other terms
+func (factor SumFactor) Messages(in [][]float64) [][]float64 {
+	var vars = append(append([]*variable.DiscreteRV(nil), factor.Terms...), factor.Total)
+	return sumMessages(factor.Terms, factor.Total, evidenceMessages(vars, in), factor.ok)
+}
+
+// Ask whether a sum matches an outcome of the total
+func (factor SumFactor) ok(sum float64, total dist.Outcome) bool {
+	return sum == factor.Total.Space().F64Value(total)
+}
+
+// Create a constraint on the values of some discrete variables, given by a
+// predicate. Its messages are computed by enumeration.
+func NewPredicateFactor(pred func(vals []float64) bool, vars ...*variable.DiscreteRV) *PredicateFactor {
+	for _, v := range vars {
+		if v.Space().Size() < 0 {
+			panic(stats.ErrfInfiniteSpace(v))
+		}
+	}
+	return &PredicateFactor{Vars: vars, Pred: pred}
+}
+
+// Create a constraint that three pairwise preferences are transitive, where
+// ab is true if a is preferred to b, and so on: if a is preferred to b and b
+// to c, then a is preferred to c, and vice versa.
+func NewTransitivityFactor(ab, bc, ac *variable.DiscreteRV) *PredicateFactor {
+	return NewPredicateFactor(func(vals []float64) bool {
+		return vals[0] != vals[1] || vals[2] == vals[0]
+	}, ab, bc, ac)
+}
+
+// A constraint on the values of some discrete variables, given by a
+// predicate
+type PredicateFactor struct {
+	Vars []*variable.DiscreteRV
+	Pred func(vals []float64) bool
+}
+
+// The adjacent random variables
+func (factor PredicateFactor) Adjacent() []variable.RandomVariable {
+	return discreteAdjacent(factor.Vars)
+}
+
+// One if the constraint is satisfied, and zero otherwise
+func (factor PredicateFactor) Score() float64 {
+	return constraintScore(factor)
+}
+
+// The gradient of the log score, which is zero for discrete variables
+func (factor PredicateFactor) LogScoreGrad() []float64 {
+	return make([]float64, len(factor.Vars))
+}
+
+// Ask whether the predicate holds for the variables' current values
+func (factor PredicateFactor) Satisfied() bool {
+	var vals = make([]float64, len(factor.Vars))
+	for i, v := range factor.Vars {
+		vals[i] = v.Val()
+	}
+	return factor.Pred(vals)
+}
+
+// Compute the message to each variable by enumerating the joint outcomes
+// which satisfy the predicate
+func (factor PredicateFactor) Messages(in [][]float64) [][]float64 {
+	in = evidenceMessages(factor.Vars, in)
+	var (
+		out  = make([][]float64, len(factor.Vars))
+		vals = make([]float64, len(factor.Vars))
+		iter = newTableIter(factor.Vars)
+		size = 1
+	)
+	for i, v := range factor.Vars {
+		out[i] = make([]float64, v.Space().Size())
+		size *= v.Space().Size()
+	}
+	for s := 0; s < size; s++ {
+		for i, v := range factor.Vars {
+			vals[i] = v.Space().F64Value(dist.Outcome(iter.outcomes[i]))
+		}
+		if factor.Pred(vals) {
+			for i := range factor.Vars {
+				var p = 1.0
+				for j, o := range iter.outcomes {
+					if j != i {
+						p *= in[j][o]
+					}
+				}
+				out[i][iter.outcomes[i]] += p
+			}
+		}
+		iter.next()
+	}
+	return out
+}
+
+// Get the score of a constraint
+func constraintScore(c Constraint) float64 {
+	if c.Satisfied() {
+		return 1
+	}
+	return 0
+}
+
+// Convert a list of discrete variables to a list of random variables
+func discreteAdjacent(vars []*variable.DiscreteRV) []variable.RandomVariable {
+	var adj = make([]variable.RandomVariable, len(vars))
+	for i, v := range vars {
+		adj[i] = v
+	}
+	return adj
+}
+
+// Get the sum of the values of some variables
+func sumVals(vars []*variable.DiscreteRV) float64 {
+	var sum float64
+	for _, v := range vars {
+		sum += v.Val()
+	}
+	return sum
+}
+
+// Replace the messages from observed variables with an indicator of their
+// observed outcomes
+func evidenceMessages(vars []*variable.DiscreteRV, in [][]float64) [][]float64 {
+	if len(in) != len(vars) {
+		panic(stats.Errorf("Expected %d message(s), but got %d", len(vars), len(in)))
+	}
+	var result = make([][]float64, len(in))
+	for i, v := range vars {
+		if v.IsObserved() {
+			result[i] = make([]float64, v.Space().Size())
+			result[i][v.Outcome()] = 1
+		} else {
+			result[i] = in[i]
+		}
+	}
+	return result
+}
+
+// Compute messages for a constraint which depends only on the sum of the
+// values of some terms, and optionally on the outcome of one more variable
+// (or -1 if there is none). The messages are computed from the distribution
+// of the sum of all but one term, which takes time polynomial in the number
+// of terms rather than exponential.
+func sumMessages(terms []*variable.DiscreteRV, extra *variable.DiscreteRV, in [][]float64,
+	ok func(sum float64, outcome dist.Outcome) bool) [][]float64 {
+
+	// Find the distribution of the sum of each prefix and suffix of the terms
+	var (
+		n      = len(terms)
+		prefix = make([]map[float64]float64, n+1)
+		suffix = make([]map[float64]float64, n+1)
+		out    = make([][]float64, len(in))
+	)
+	prefix[0] = map[float64]float64{0: 1}
+	suffix[n] = map[float64]float64{0: 1}
+	for i := 0; i < n; i++ {
+		prefix[i+1] = addTerm(prefix[i], terms[i], in[i])
+		suffix[n-i-1] = addTerm(suffix[n-i], terms[n-i-1], in[n-i-1])
+	}
+
+	// Weight each sum of the terms by its consistency with the extra variable
+	var weight = func(sum float64) float64 {
+		if extra == nil {
+			if ok(sum, -1) {
+				return 1
+			}
+			return 0
+		}
+		var w float64
+		for o, p := range in[n] {
+			if ok(sum, dist.Outcome(o)) {
+				w += p
+			}
+		}
+		return w
+	}
+
+	for i, v := range terms {
+		var others = make(map[float64]float64)
+		for s1, p1 := range prefix[i] {
+			for s2, p2 := range suffix[i+1] {
+				others[s1+s2] += p1 * p2
+			}
+		}
+		out[i] = make([]float64, v.Space().Size())
+		for o := range out[i] {
+			var val = v.Space().F64Value(dist.Outcome(o))
+			for sum, p := range others {
+				out[i][o] += p * weight(sum+val)
+			}
+		}
+	}
+	if extra != nil {
+		out[n] = make([]float64, extra.Space().Size())
+		for o := range out[n] {
+			for sum, p := range prefix[n] {
+				if ok(sum, dist.Outcome(o)) {
+					out[n][o] += p
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Add a term to the distribution of a sum
+func addTerm(sums map[float64]float64, term *variable.DiscreteRV, msg []float64) map[float64]float64 {
+	var result = make(map[float64]float64)
+	for sum, p := range sums {
+		for o, q := range msg {
+			if q != 0 {
+				result[sum+term.Space().F64Value(dist.Outcome(o))] += p * q
+			}
+		}
+	}
+	return result
+}
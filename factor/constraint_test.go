@@ -0,0 +1,167 @@
+package factor
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"testing"
+)
+
+// Compute a constraint's messages by enumerating the joint outcomes of its
+// variables
+func enumerateMessages(c Constraint, vars []*variable.DiscreteRV, in [][]float64) [][]float64 {
+	return NewPredicateFactor(func(vals []float64) bool {
+		for i, v := range vars {
+			v.Set(vals[i])
+		}
+		return c.Satisfied()
+	}, vars...).Messages(in)
+}
+
+// Create random messages for some variables
+func randomMessages(vars []*variable.DiscreteRV) [][]float64 {
+	var in = make([][]float64, len(vars))
+	for i, v := range vars {
+		in[i] = make([]float64, v.Space().Size())
+		for o := range in[i] {
+			in[i][o] = rand.Float64()
+		}
+	}
+	return in
+}
+
+// Assert that two sets of messages are equal
+func shouldEqualMessages(actual interface{}, expected ...interface{}) string {
+	var a, e = actual.([][]float64), expected[0].([][]float64)
+	if msg := ShouldHaveLength(a, len(e)); msg != "" {
+		return msg
+	}
+	for i := range e {
+		if msg := ShouldHaveLength(a[i], len(e[i])); msg != "" {
+			return msg
+		}
+		for o := range e[i] {
+			if msg := ShouldAlmostEqual(a[i][o], e[i][o], 1e-9); msg != "" {
+				return msg
+			}
+		}
+	}
+	return ""
+}
+
+func boolVars(vals ...dist.Outcome) []*variable.DiscreteRV {
+	var vars = make([]*variable.DiscreteRV, len(vals))
+	for i, val := range vals {
+		vars[i] = variable.NewDiscreteRV(val, dist.BooleanSpace)
+	}
+	return vars
+}
+
+func TestConstraintFactors(t *testing.T) {
+	Convey("Test equality constraints", t, func() {
+		vars := boolVars(1, 1, 1)
+		f := NewEqualityFactor(vars...)
+		So(f.Score(), ShouldEqual, 1)
+		vars[1].Set(0)
+		So(f.Score(), ShouldEqual, 0)
+		in := randomMessages(vars)
+		So(f.Messages(in), shouldEqualMessages,
+			enumerateMessages(f, vars, in))
+		So(func() { NewEqualityFactor(vars[0], variable.NewDiscreteRV(0, dist.NewIntegerIntervalSpace(0, 3))) },
+			ShouldPanic)
+	})
+
+	Convey("Test not constraints", t, func() {
+		vars := boolVars(1, 0)
+		f := NewNotFactor(vars[0], vars[1])
+		So(f.Satisfied(), ShouldBeTrue)
+		vars[1].Set(1)
+		So(f.Satisfied(), ShouldBeFalse)
+		So(f.Messages([][]float64{{0.2, 0.8}, {0.3, 0.7}}), ShouldResemble,
+			[][]float64{{0.7, 0.3}, {0.8, 0.2}})
+	})
+
+	Convey("Test parity and XOR constraints", t, func() {
+		vars := boolVars(1, 0, 1)
+		So(NewParityFactor(false, vars...).Satisfied(), ShouldBeTrue)
+		So(NewParityFactor(true, vars...).Satisfied(), ShouldBeFalse)
+		xor := NewXORFactor(vars[2], vars[0], vars[1])
+		So(xor.Score(), ShouldEqual, 1)
+		vars[1].Set(1)
+		So(xor.Score(), ShouldEqual, 0)
+
+		vars = boolVars(0, 0, 0, 0, 0)
+		for _, odd := range []bool{false, true} {
+			f := NewParityFactor(odd, vars...)
+			in := randomMessages(vars)
+			So(f.Messages(in), shouldEqualMessages, enumerateMessages(f, vars, in))
+		}
+	})
+
+	Convey("Test AND and OR constraints", t, func() {
+		vars := boolVars(1, 1, 0, 1)
+		and := NewAndFactor(vars[3], vars[:3]...)
+		or := NewOrFactor(vars[3], vars[:3]...)
+		So(and.Satisfied(), ShouldBeFalse)
+		So(or.Satisfied(), ShouldBeTrue)
+		vars[2].Set(1)
+		So(and.Satisfied(), ShouldBeTrue)
+		So(and.Adjacent()[3], ShouldEqual, vars[3])
+
+		for _, f := range []*LogicFactor{and, or} {
+			in := randomMessages(vars)
+			So(f.Messages(in), shouldEqualMessages, enumerateMessages(f, vars, in))
+		}
+	})
+
+	Convey("Test cardinality constraints", t, func() {
+		vars := boolVars(1, 0, 1, 0, 1)
+		So(NewExactlyKFactor(3, vars...).Satisfied(), ShouldBeTrue)
+		So(NewExactlyKFactor(2, vars...).Satisfied(), ShouldBeFalse)
+		So(NewAtMostKFactor(3, vars...).Satisfied(), ShouldBeTrue)
+		So(NewAtMostKFactor(2, vars...).Satisfied(), ShouldBeFalse)
+
+		for _, f := range []*CardinalityFactor{NewExactlyKFactor(2, vars...), NewAtMostKFactor(1, vars...)} {
+			in := randomMessages(vars)
+			So(f.Messages(in), shouldEqualMessages, enumerateMessages(f, vars, in))
+		}
+	})
+
+	Convey("Test sum constraints", t, func() {
+		var (
+			space = dist.NewIntegerIntervalSpace(0, 3)
+			a     = variable.NewDiscreteRV(1, space)
+			b     = variable.NewDiscreteRV(2, space)
+			total = variable.NewDiscreteRV(3, dist.NewIntegerIntervalSpace(0, 6))
+			vars  = []*variable.DiscreteRV{a, b, total}
+			f     = NewSumFactor(total, a, b)
+		)
+		So(f.Satisfied(), ShouldBeTrue)
+		total.Set(4)
+		So(f.Satisfied(), ShouldBeFalse)
+		in := randomMessages(vars)
+		So(f.Messages(in), shouldEqualMessages, enumerateMessages(f, vars, in))
+
+		Convey("Observed variables use their observed outcome", func() {
+			total.Observe(2)
+			msgs := f.Messages(randomMessages(vars))
+			So(msgs[0][3], ShouldEqual, 0)
+			So(msgs[1][3], ShouldEqual, 0)
+			So(msgs[0][0], ShouldBeGreaterThan, 0)
+		})
+	})
+
+	Convey("Test transitivity constraints", t, func() {
+		vars := boolVars(1, 1, 1)
+		f := NewTransitivityFactor(vars[0], vars[1], vars[2])
+		So(f.Score(), ShouldEqual, 1)
+		vars[2].Set(0)
+		So(f.Score(), ShouldEqual, 0)
+		vars[1].Set(0)
+		So(f.Score(), ShouldEqual, 1)
+		msgs := f.Messages([][]float64{{1, 1}, {1, 1}, {1, 1}})
+		So(msgs, ShouldResemble, [][]float64{{3, 3}, {3, 3}, {3, 3}})
+		So(f, ShouldImplement, (*Constraint)(nil))
+	})
+}
@@ -0,0 +1,276 @@
+package factor
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	"math"
+)
+
+// An inference method which estimates the joint marginal distribution of the
+// latent discrete variables adjacent to each factor of a graph, given its
+// observed variables. The marginals are normalized tables indexed like
+// graph.Factors. It also estimates the log partition function. Besides
+// EnumerateMarginals, the infer/jtree and infer/bp packages provide exact and
+// approximate methods for larger graphs.
+type MarginalInference func(graph *FactorGraph) (marginals []*TableFactor, logZ float64, err error)
+
+// Compute exact factor marginals and the log partition function by
+// enumerating every joint outcome of the graph's latent variables, which must
+// all be discrete. This takes time exponential in the number of variables,
+// so it is only suitable for small graphs. Variable values are restored
+// afterwards.
+func EnumerateMarginals(graph *FactorGraph) ([]*TableFactor, float64, error) {
+	var vars []*variable.DiscreteRV
+	for _, v := range graph.LatentVariables() {
+		dv, ok := v.(*variable.DiscreteRV)
+		if !ok {
+			return nil, 0, stats.ErrDiscreteOnly
+		} else if dv.Space().Size() < 0 {
+			return nil, 0, stats.ErrfInfiniteSpace(dv)
+		}
+		vars = append(vars, dv)
+	}
+
+	var (
+		marginals = make([]*TableFactor, len(graph.Factors))
+		strides   = make([][]int, len(graph.Factors))
+		saved     = make([]dist.Outcome, len(vars))
+		states    = 1
+	)
+	for i, f := range graph.Factors {
+		var fvars []*variable.DiscreteRV
+		for _, v := range f.Adjacent() {
+			if dv, ok := v.(*variable.DiscreteRV); ok && !dv.IsObserved() &&
+				indexOfVar(fvars, dv) < 0 {
+				fvars = append(fvars, dv)
+			}
+		}
+		marginals[i] = NewTableFactor(fvars, nil)
+		strides[i] = marginals[i].strides(vars)
+	}
+	for i, v := range vars {
+		saved[i] = v.Outcome()
+		states *= v.Space().Size()
+	}
+
+	// Score every joint outcome, then accumulate the normalized probabilities
+	var (
+		logScores = make([]float64, states)
+		logZ      = math.Inf(-1)
+		iter      = newTableIter(vars, strides...)
+	)
+	for s := range logScores {
+		for i, v := range vars {
+			v.SetOutcome(dist.Outcome(iter.outcomes[i]))
+		}
+		logScores[s] = graph.Score()
+		logZ = logSumExp(logZ, logScores[s])
+		iter.next()
+	}
+	for i, v := range vars {
+		v.SetOutcome(saved[i])
+	}
+	if math.IsInf(logZ, -1) {
+		return nil, logZ, stats.ErrZeroProb
+	}
+	iter = newTableIter(vars, strides...)
+	for _, score := range logScores {
+		var p = math.Exp(score - logZ)
+		for i, m := range marginals {
+			m.Values[iter.idx[i]] += p
+		}
+		iter.next()
+	}
+	return marginals, logZ, nil
+}
+
+// Get log(exp(a) + exp(b)) without overflow
+func logSumExp(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	} else if math.IsInf(b, -1) {
+		return a
+	} else if a < b {
+		a, b = b, a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
+
+// Create a new CRF trainer for the given weights, using exact inference
+func NewCRFTrainer(weights []*variable.ContinuousRV) *CRFTrainer {
+	return &CRFTrainer{
+		Weights:      weights,
+		Inference:    EnumerateMarginals,
+		LearningRate: 0.1,
+		MaxIter:      1000,
+		Tol:          1e-6,
+	}
+}
+
+// Trains the weights of the feature factors in a set of conditional random
+// fields by maximizing the conditional log-likelihood of the labels, using
+// gradient ascent. Each training example is a factor graph whose inputs are
+// observed and whose latent variables are set to their true labels.
+type CRFTrainer struct {
+
+	// The weights to learn. Other weights are held fixed.
+	Weights []*variable.ContinuousRV
+
+	// The inference method used to compute expected feature values
+	Inference MarginalInference
+
+	// The L1 and L2 regularization strengths
+	L1, L2 float64
+
+	// The gradient ascent step size
+	LearningRate float64
+
+	// Stop after MaxIter iterations, or when no weight changes by more than Tol
+	MaxIter int
+	Tol     float64
+}
+
+// Train the weights. Returns the final regularized conditional
+// log-likelihood. The weights are observed during training, and released
+// afterwards unless they were observed already. Latent variables keep their
+// label values.
+func (trainer CRFTrainer) Train(graphs []*FactorGraph) (float64, error) {
+	var (
+		index    = make(map[*variable.ContinuousRV]int)
+		observed = make([]bool, len(trainer.Weights))
+	)
+	for i, w := range trainer.Weights {
+		index[w] = i
+		observed[i] = w.IsObserved()
+		w.Observe(w.Val())
+	}
+	defer func() {
+		for i, w := range trainer.Weights {
+			if !observed[i] {
+				w.Release()
+			}
+		}
+	}()
+
+	// The empirical feature values do not depend on the weights
+	var empirical = trainer.empirical(graphs, index)
+
+	var ll float64
+	for iter := 0; iter < trainer.MaxIter; iter++ {
+		var (
+			grad []float64
+			err  error
+		)
+		if ll, grad, err = trainer.gradient(graphs, index, empirical); err != nil {
+			return ll, err
+		}
+		var maxStep float64
+		for i, w := range trainer.Weights {
+			var (
+				old = w.Val()
+				val = old + trainer.LearningRate*grad[i]
+			)
+
+			// Apply the L1 penalty with a proximal (soft thresholding) step,
+			// so weights can become exactly zero
+			var shrink = trainer.LearningRate * trainer.L1
+			if val > shrink {
+				val -= shrink
+			} else if val < -shrink {
+				val += shrink
+			} else {
+				val = 0
+			}
+			w.Release()
+			w.Observe(val)
+			maxStep = math.Max(maxStep, math.Abs(val-old))
+		}
+		if maxStep <= trainer.Tol {
+			break
+		}
+	}
+	ll, _, err := trainer.gradient(graphs, index, empirical)
+	return ll, err
+}
+
+// Get the sum of the feature values for each weight at the true labels
+func (trainer CRFTrainer) empirical(graphs []*FactorGraph,
+	index map[*variable.ContinuousRV]int) []float64 {
+
+	var empirical = make([]float64, len(trainer.Weights))
+	for _, graph := range graphs {
+		for _, f := range graph.Factors {
+			if ff, ok := f.(*FeatureFactor); ok {
+				for k, val := range ff.FeatureValues() {
+					if i, ok := index[ff.Weights[k]]; ok {
+						empirical[i] += val
+					}
+				}
+			}
+		}
+	}
+	return empirical
+}
+
+// Compute the regularized conditional log-likelihood and its gradient with
+// respect to the weights, excluding the L1 penalty's gradient
+func (trainer CRFTrainer) gradient(graphs []*FactorGraph,
+	index map[*variable.ContinuousRV]int, empirical []float64) (float64, []float64, error) {
+
+	var (
+		ll   float64
+		grad = append([]float64(nil), empirical...)
+	)
+	for _, graph := range graphs {
+		marginals, logZ, err := trainer.Inference(graph)
+		if err != nil {
+			return 0, nil, err
+		}
+		ll += graph.Score() - logZ
+		for fi, f := range graph.Factors {
+			ff, ok := f.(*FeatureFactor)
+			if !ok {
+				continue
+			}
+			for k, val := range expectedFeatures(ff, marginals[fi]) {
+				if i, ok := index[ff.Weights[k]]; ok {
+					grad[i] -= val
+				}
+			}
+		}
+	}
+	for i, w := range trainer.Weights {
+		ll -= trainer.L1*math.Abs(w.Val()) + trainer.L2*w.Val()*w.Val()/2
+		grad[i] -= trainer.L2 * w.Val()
+	}
+	return ll, grad, nil
+}
+
+// Get the expected value of each of a factor's features under a marginal
+// distribution over its latent variables. Variable values are restored
+// afterwards.
+func expectedFeatures(factor *FeatureFactor, marginal *TableFactor) []float64 {
+	var (
+		expected = make([]float64, len(factor.Features))
+		saved    = make([]dist.Outcome, len(marginal.Vars))
+	)
+	for i, v := range marginal.Vars {
+		saved[i] = v.Outcome()
+	}
+	for idx, p := range marginal.Values {
+		if p == 0 {
+			continue
+		}
+		for i, outcome := range marginal.Outcomes(idx) {
+			marginal.Vars[i].SetOutcome(outcome)
+		}
+		for k, val := range factor.FeatureValues() {
+			expected[k] += p * val
+		}
+	}
+	for i, v := range marginal.Vars {
+		v.SetOutcome(saved[i])
+	}
+	return expected
+}
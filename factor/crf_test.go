@@ -0,0 +1,166 @@
+package factor
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+// Build a linear-chain CRF with boolean labels, observed boolean inputs,
+// and shared emission and transition weights
+func linearChainCRF(inputs, labels []float64, emit, trans *variable.ContinuousRV) *FactorGraph {
+	var (
+		graph = NewFactorGraph()
+		same  = func(vals []float64) float64 {
+			if vals[0] == vals[1] {
+				return 1
+			}
+			return 0
+		}
+		prev *variable.DiscreteRV
+	)
+	for i, in := range inputs {
+		var (
+			x = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			y = variable.NewDiscreteRV(dist.Outcome(labels[i]), dist.BooleanSpace)
+		)
+		x.Observe(in)
+		graph.AddFactor(NewFeatureFactor([]variable.RandomVariable{y, x},
+			[]Feature{same}, []*variable.ContinuousRV{emit}))
+		if prev != nil {
+			graph.AddFactor(NewFeatureFactor([]variable.RandomVariable{prev, y},
+				[]Feature{same}, []*variable.ContinuousRV{trans}))
+		}
+		prev = y
+	}
+	return graph
+}
+
+func TestFeatureFactor(t *testing.T) {
+	Convey("Given a feature factor", t, func() {
+		var (
+			x  = variable.NewDiscreteRV(1, dist.BooleanSpace)
+			y  = variable.NewContinuousRV(2, dist.AllRealSpace)
+			w1 = variable.NewContinuousRV(0.5, dist.AllRealSpace)
+			w2 = variable.NewContinuousRV(-1, dist.AllRealSpace)
+			f  = NewFeatureFactor([]variable.RandomVariable{x, y},
+				[]Feature{IndicatorFeature(1, 2), func(v []float64) float64 { return v[1] * v[1] }},
+				[]*variable.ContinuousRV{w1, w2})
+		)
+
+		Convey("The score is log-linear in the features", func() {
+			So(f.Adjacent(), ShouldHaveLength, 4)
+			So(f.FeatureValues(), ShouldResemble, []float64{1, 4})
+			So(f.LogScore(), ShouldAlmostEqual, 0.5-4)
+			So(f.Score(), ShouldAlmostEqual, math.Exp(0.5-4))
+			x.Set(0)
+			So(f.LogScore(), ShouldAlmostEqual, -4)
+		})
+
+		Convey("The gradient matches a numerical estimate", func() {
+			grad := f.LogScoreGrad()
+			So(grad[0], ShouldEqual, 0)
+			So(grad[1], ShouldAlmostEqual, -4, 1e-4)
+			So(grad[2:], ShouldResemble, []float64{1, 4})
+			So(CheckLogScoreGrad(f, 1e-6, 1e-4), ShouldBeNil)
+		})
+
+		Convey("The numerical gradient only perturbs the variables", func() {
+			var calls int
+			f.Features[1] = func(v []float64) float64 {
+				calls++
+				return v[1] * v[1]
+			}
+			f.LogScoreGrad()
+			So(calls, ShouldEqual, 3)
+		})
+
+		Convey("Mismatched weights panic", func() {
+			So(func() { NewFeatureFactor(nil, []Feature{IndicatorFeature(1)}, nil) }, ShouldPanic)
+		})
+	})
+}
+
+func TestCRFTraining(t *testing.T) {
+	Convey("Test exact marginals by enumeration", t, func() {
+		var (
+			emit  = variable.NewContinuousRV(1, dist.AllRealSpace)
+			trans = variable.NewContinuousRV(0, dist.AllRealSpace)
+			graph = linearChainCRF([]float64{1, 0}, []float64{1, 1}, emit, trans)
+		)
+		emit.Observe(1)
+		trans.Observe(0)
+		marginals, logZ, err := EnumerateMarginals(graph)
+		So(err, ShouldBeNil)
+		So(logZ, ShouldAlmostEqual, math.Log((1+math.E)*(1+math.E)))
+		So(marginals, ShouldHaveLength, 3)
+		So(marginals[0].Vars, ShouldHaveLength, 1)
+		So(marginals[0].Value(1), ShouldAlmostEqual, math.E/(1+math.E))
+		So(marginals[1].Vars, ShouldHaveLength, 1)
+		So(marginals[1].Value(1), ShouldAlmostEqual, 1/(1+math.E))
+		So(marginals[2].Vars, ShouldHaveLength, 2)
+		So(marginals[2].Sum(), ShouldAlmostEqual, 1)
+		So(marginals[2].Value(1, 0), ShouldAlmostEqual, math.E*math.E/((1+math.E)*(1+math.E)))
+		So(graph.Factors[0].Adjacent()[0].Val(), ShouldEqual, 1)
+	})
+
+	Convey("Given linear-chain training data", t, func() {
+		var (
+			emit   = variable.NewContinuousRV(0, dist.AllRealSpace)
+			trans  = variable.NewContinuousRV(0, dist.AllRealSpace)
+			graphs = []*FactorGraph{
+				linearChainCRF([]float64{1, 1, 0, 0}, []float64{1, 1, 0, 0}, emit, trans),
+				linearChainCRF([]float64{0, 1, 0, 1}, []float64{0, 1, 1, 1}, emit, trans),
+				linearChainCRF([]float64{1, 0, 1}, []float64{1, 0, 1}, emit, trans),
+			}
+			trainer = NewCRFTrainer([]*variable.ContinuousRV{emit, trans})
+			index   = map[*variable.ContinuousRV]int{emit: 0, trans: 1}
+			counts  = trainer.empirical(graphs, index)
+			fixed   = func(f func()) {
+				emit.Observe(emit.Val())
+				trans.Observe(trans.Val())
+				f()
+				emit.Release()
+				trans.Release()
+			}
+		)
+		So(counts, ShouldResemble, []float64{10, 4})
+
+		Convey("Training increases the conditional log-likelihood", func() {
+			var before float64
+			fixed(func() {
+				var err error
+				before, _, err = trainer.gradient(graphs, index, counts)
+				So(err, ShouldBeNil)
+			})
+			ll, err := trainer.Train(graphs)
+			So(err, ShouldBeNil)
+			So(ll, ShouldBeGreaterThan, before)
+			So(emit.Val(), ShouldBeGreaterThan, 0)
+			So(emit.IsObserved(), ShouldBeFalse)
+			So(graphs[1].Factors[3].Adjacent()[0].Val(), ShouldEqual, 1)
+		})
+
+		Convey("The trained weights are a stationary point", func() {
+			trainer.L2 = 0.5
+			_, err := trainer.Train(graphs)
+			So(err, ShouldBeNil)
+			fixed(func() {
+				_, grad, err := trainer.gradient(graphs, index, counts)
+				So(err, ShouldBeNil)
+				So(grad[0], ShouldAlmostEqual, 0, 1e-3)
+				So(grad[1], ShouldAlmostEqual, 0, 1e-3)
+			})
+		})
+
+		Convey("Strong L1 regularization zeroes the weights", func() {
+			trainer.L1 = 100
+			_, err := trainer.Train(graphs)
+			So(err, ShouldBeNil)
+			So(emit.Val(), ShouldEqual, 0)
+			So(trans.Val(), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package factor
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/jesand/stats/variable"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Options for writing a factor graph in the Graphviz DOT language
+type DOTOptions struct {
+
+	// The name of the graph
+	Name string
+
+	// Whether to label variables with their current values
+	ShowValues bool
+
+	// Whether to label factors with their current scores
+	ShowScores bool
+}
+
+// Write the graph in the Graphviz DOT language, with variables drawn as
+// circles and factors as squares. Observed variables are shaded.
+func (graph FactorGraph) WriteDOT(w io.Writer, opts DOTOptions) error {
+	var (
+		bw         = bufio.NewWriter(w)
+		factorVars = graph.factorVarIdx()
+		name       = opts.Name
+	)
+	if name == "" {
+		name = "G"
+	}
+	fmt.Fprintf(bw, "graph %s {\n", strconv.Quote(name))
+	for vi, v := range graph.Variables {
+		var (
+			label = fmt.Sprintf("v%d", vi)
+			attrs = []string{"shape=circle"}
+		)
+		if iv, ok := v.Variable.(variable.IdentifiedVariable); ok {
+			label = iv.String()
+		}
+		if opts.ShowValues {
+			label += fmt.Sprintf("\n%v", v.Variable.Val())
+		}
+		if variable.IsObserved(v.Variable) {
+			attrs = append(attrs, "style=filled", "fillcolor=gray")
+		}
+		attrs = append(attrs, "label="+strconv.Quote(label))
+		fmt.Fprintf(bw, "  v%d [%s];\n", vi, strings.Join(attrs, ", "))
+	}
+	for fi, f := range graph.Factors {
+		var label = fmt.Sprintf("f%d", fi)
+		if opts.ShowScores {
+			label += fmt.Sprintf("\n%.4g", f.Score())
+		}
+		fmt.Fprintf(bw, "  f%d [shape=square, label=%s];\n", fi, strconv.Quote(label))
+	}
+	for fi, vars := range factorVars {
+		for _, vi := range vars {
+			fmt.Fprintf(bw, "  v%d -- f%d;\n", vi, fi)
+		}
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
@@ -18,6 +18,31 @@ type Factor interface {
 	Score() float64
 }
 
+// A factor whose log score can be differentiated with respect to its adjacent
+// variables. A FactorGraph made of differentiable factors can compute the
+// gradient of its joint log score.
+type DifferentiableFactor interface {
+	Factor
+
+	// The gradient of the log score with respect to each adjacent variable, in
+	// the order given by Adjacent(). Discrete variables have zero gradient.
+	LogScoreGrad() []float64
+}
+
+// Ask whether a factor can compute the gradient of its log score. It must be a
+// DifferentiableFactor, and if it has a CanDifferentiate() method, such as a
+// DistFactor, that method must return true.
+func CanDifferentiate(f Factor) bool {
+	if _, ok := f.(DifferentiableFactor); !ok {
+		return false
+	} else if cf, ok := f.(interface {
+		CanDifferentiate() bool
+	}); ok {
+		return cf.CanDifferentiate()
+	}
+	return true
+}
+
 // Create a new factor which scores based on a probability distribution.
 // The variables are split into "variables" and "parameters" using the
 // distribution's NumVars() and NumParams() values.
@@ -41,6 +66,39 @@ func (factor DistFactor) Adjacent() []variable.RandomVariable {
 
 // The log probability of the variables given the parameters
 func (factor DistFactor) Score() float64 {
+	vars, params := factor.values()
+	return factor.Dist.Score(vars, params)
+}
+
+// The gradient of the log score with respect to each adjacent variable, in
+// the order given by Adjacent(). Panics if the distribution is not
+// differentiable; check with CanDifferentiate().
+func (factor DistFactor) LogScoreGrad() []float64 {
+	dd, ok := factor.Dist.(dist.Differentiable)
+	if !ok {
+		panic(stats.ErrfNotDifferentiable(factor.Dist))
+	}
+	vars, params := factor.values()
+	dVars, dParams := dd.LogScoreGrad(vars, params)
+	return append(dVars, dParams...)
+}
+
+// Ask whether the distribution is differentiable, so that LogScoreGrad() will
+// not panic
+func (factor DistFactor) CanDifferentiate() bool {
+	_, ok := factor.Dist.(dist.Differentiable)
+	return ok
+}
+
+// A DistFactor is pure, since its distribution is scored with parameters taken
+// from its variables
+func (factor DistFactor) IsPure() bool {
+	return true
+}
+
+// Split the current values of the adjacent variables into the distribution's
+// variables and parameters
+func (factor DistFactor) values() (vars, params []float64) {
 	var (
 		numVars   = factor.Dist.NumVars()
 		numParams = factor.Dist.NumParams()
@@ -48,10 +106,8 @@ func (factor DistFactor) Score() float64 {
 	if len(factor.Vars) != numVars+numParams {
 		panic(stats.ErrfFactorVarNum(numVars, numParams, len(factor.Vars)))
 	}
-	var (
-		vars   = make([]float64, numVars)
-		params = make([]float64, numParams)
-	)
+	vars = make([]float64, numVars)
+	params = make([]float64, numParams)
 	for i, rv := range factor.Vars {
 		if i < len(vars) {
 			vars[i] = rv.Val()
@@ -59,7 +115,7 @@ func (factor DistFactor) Score() float64 {
 			params[i-len(vars)] = rv.Val()
 		}
 	}
-	return factor.Dist.Score(vars, params)
+	return
 }
 
 // Create a new factor which always returns the same score
@@ -85,3 +141,9 @@ func (factor ConstFactor) Adjacent() []variable.RandomVariable {
 func (factor ConstFactor) Score() float64 {
 	return factor.Value
 }
+
+// The gradient of the log score with respect to each adjacent variable, which
+// is always zero
+func (factor ConstFactor) LogScoreGrad() []float64 {
+	return make([]float64, len(factor.Vars))
+}
@@ -1,6 +1,7 @@
 package factor
 
 import (
+	"github.com/jesand/stats"
 	"github.com/jesand/stats/dist"
 	"github.com/jesand/stats/variable"
 	. "github.com/smartystreets/goconvey/convey"
@@ -22,3 +23,57 @@ func TestDistFactor(t *testing.T) {
 		So(factor.Score(), ShouldAlmostEqual, 1.061032953945969)
 	})
 }
+
+func TestObservedGraphVariables(t *testing.T) {
+	Convey("Test latent and observed variable lists", t, func() {
+		val := variable.NewContinuousRV(0.1, dist.UnitIntervalSpace)
+		alpha := variable.NewContinuousRV(0.5, dist.NewRealIntervalSpace(0, math.Inf(+1)))
+		beta := variable.NewContinuousRV(0.5, dist.NewRealIntervalSpace(0, math.Inf(+1)))
+		graph := NewFactorGraph()
+		graph.AddFactor(NewDistFactor([]variable.RandomVariable{val, alpha, beta}, dist.NewBetaDist(0, 0)))
+		So(graph.ObservedVariables(), ShouldBeEmpty)
+		So(graph.LatentVariables(), ShouldResemble, []variable.RandomVariable{val, alpha, beta})
+
+		val.Observe(0.2)
+		So(graph.ObservedVariables(), ShouldResemble, []variable.RandomVariable{val})
+		So(graph.LatentVariables(), ShouldResemble, []variable.RandomVariable{alpha, beta})
+	})
+}
+
+func TestGraphVariableLookup(t *testing.T) {
+	Convey("Test variable lookup by name and ID", t, func() {
+		val := variable.NewContinuousRV(0.1, dist.UnitIntervalSpace)
+		alpha := variable.NewContinuousRV(0.5, dist.NewRealIntervalSpace(0, math.Inf(+1)))
+		beta := variable.NewContinuousRV(0.5, dist.NewRealIntervalSpace(0, math.Inf(+1)))
+		graph := NewFactorGraph()
+		graph.AddFactor(NewDistFactor([]variable.RandomVariable{val, alpha, beta}, dist.NewBetaDist(0, 0)))
+		alpha.SetName("alpha")
+
+		v, ok := graph.VariableByName("alpha")
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, alpha)
+		_, ok = graph.VariableByName("beta")
+		So(ok, ShouldBeFalse)
+
+		beta.SetName("beta")
+		v, ok = graph.VariableByName("beta")
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, beta)
+
+		v, ok = graph.VariableByID(val.ID())
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, val)
+		val.SetID(-1)
+		v, ok = graph.VariableByID(-1)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, val)
+	})
+
+	Convey("Test missing variable errors", t, func() {
+		graph := NewFactorGraph()
+		x := variable.NewContinuousRV(0, dist.UnitIntervalSpace)
+		x.SetName("x")
+		So(func() { graph.AdjToVariable(x) }, ShouldPanicWith,
+			stats.Error("Random variable x not in factor graph"))
+	})
+}
@@ -0,0 +1,106 @@
+package factor
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/variable"
+	"math"
+)
+
+// A feature function of the values of a factor's variables
+type Feature func(vals []float64) float64
+
+// Create a feature which is one when the variables have exactly the given
+// values, and zero otherwise
+func IndicatorFeature(vals ...float64) Feature {
+	return func(actual []float64) float64 {
+		if len(actual) != len(vals) {
+			return 0
+		}
+		for i, v := range vals {
+			if actual[i] != v {
+				return 0
+			}
+		}
+		return 1
+	}
+}
+
+// Create a new log-linear factor. There must be one weight per feature.
+// Weights may be shared across factors, which ties their parameters.
+func NewFeatureFactor(vars []variable.RandomVariable, features []Feature,
+	weights []*variable.ContinuousRV) *FeatureFactor {
+
+	if len(features) != len(weights) {
+		panic(stats.Errorf("Factor has %d feature(s), but %d weight(s)",
+			len(features), len(weights)))
+	}
+	return &FeatureFactor{
+		Vars:     vars,
+		Features: features,
+		Weights:  weights,
+	}
+}
+
+// A log-linear factor, whose log score is a weighted sum of feature functions
+// of its variables. The weights are also adjacent to the factor, after the
+// variables, so they can be learned or held fixed as evidence.
+type FeatureFactor struct {
+	Vars     []variable.RandomVariable
+	Features []Feature
+	Weights  []*variable.ContinuousRV
+}
+
+// The adjacent random variables: the variables followed by the weights
+func (factor FeatureFactor) Adjacent() []variable.RandomVariable {
+	var adj = append([]variable.RandomVariable(nil), factor.Vars...)
+	for _, w := range factor.Weights {
+		adj = append(adj, w)
+	}
+	return adj
+}
+
+// The exponentiated weighted sum of the features
+func (factor FeatureFactor) Score() float64 {
+	return math.Exp(factor.LogScore())
+}
+
+// The weighted sum of the features
+func (factor FeatureFactor) LogScore() float64 {
+	var (
+		vals  = factor.values()
+		score float64
+	)
+	for i, f := range factor.Features {
+		score += factor.Weights[i].Val() * f(vals)
+	}
+	return score
+}
+
+// Get the value of each feature for the variables' current values
+func (factor FeatureFactor) FeatureValues() []float64 {
+	var (
+		vals   = factor.values()
+		result = make([]float64, len(factor.Features))
+	)
+	for i, f := range factor.Features {
+		result[i] = f(vals)
+	}
+	return result
+}
+
+// The gradient of the log score with respect to each adjacent variable, in
+// the order given by Adjacent(). The gradient for each weight is its
+// feature's value. Features are not assumed to be differentiable, so the
+// gradient for continuous variables is estimated numerically.
+func (factor FeatureFactor) LogScoreGrad() []float64 {
+	return append(numericalGrad(factor, factor.Vars, 1e-6), factor.FeatureValues()...)
+}
+
+// Get the current values of the variables
+func (factor FeatureFactor) values() []float64 {
+	var vals = make([]float64, len(factor.Vars))
+	for i, v := range factor.Vars {
+		vals[i] = v.Val()
+	}
+	return vals
+}
@@ -0,0 +1,75 @@
+package factor
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/variable"
+	"math"
+)
+
+// Estimate the gradient of a factor's log score with respect to each adjacent
+// variable using central finite differences with step size h. Discrete and
+// observed variables are given zero gradient. Variable values are restored
+// afterwards.
+func NumericalLogScoreGrad(factor Factor, h float64) []float64 {
+	return numericalGrad(factor, factor.Adjacent(), h)
+}
+
+// Estimate the gradient of a factor's log score with respect to the given
+// variables, as NumericalLogScoreGrad() does
+func numericalGrad(factor Factor, vars []variable.RandomVariable, h float64) []float64 {
+	var grad = make([]float64, len(vars))
+	for i, v := range vars {
+		cv, ok := v.(*variable.ContinuousRV)
+		if !ok || cv.IsObserved() {
+			continue
+		}
+		var val = cv.Val()
+		cv.Set(val + h)
+		var above = math.Log(factor.Score())
+		cv.Set(val - h)
+		var below = math.Log(factor.Score())
+		cv.Set(val)
+		grad[i] = (above - below) / (2 * h)
+	}
+	return grad
+}
+
+// Get the gradient of a factor's log score with respect to each adjacent
+// variable: analytically if the factor can differentiate, and otherwise by
+// central finite differences with step size h
+func LogScoreGradOf(factor Factor, h float64) []float64 {
+	if CanDifferentiate(factor) {
+		return factor.(DifferentiableFactor).LogScoreGrad()
+	}
+	return NumericalLogScoreGrad(factor, h)
+}
+
+// Check a factor's analytic gradient against a finite difference estimate
+// with step size h. Returns an error naming the first adjacent variable whose
+// partial derivatives differ by more than tol, in absolute or relative terms.
+// Observed variables are not checked.
+func CheckLogScoreGrad(factor DifferentiableFactor, h, tol float64) error {
+	var (
+		adj      = factor.Adjacent()
+		analytic = factor.LogScoreGrad()
+		numeric  = NumericalLogScoreGrad(factor, h)
+	)
+	if len(analytic) != len(numeric) {
+		return stats.Errorf("Gradient has %d entries, but the factor has %d adjacent variables",
+			len(analytic), len(numeric))
+	}
+	for i, a := range analytic {
+		if variable.IsObserved(adj[i]) {
+			continue
+		}
+		var (
+			n    = numeric[i]
+			diff = math.Abs(a - n)
+		)
+		if diff > tol && diff > tol*math.Max(math.Abs(a), math.Abs(n)) {
+			return stats.Errorf("Gradient for adjacent variable %d is %f, but the numeric estimate is %f",
+				i, a, n)
+		}
+	}
+	return nil
+}
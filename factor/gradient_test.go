@@ -0,0 +1,85 @@
+package factor
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+func TestLogScoreGrad(t *testing.T) {
+	Convey("Test DistFactor gradient", t, func() {
+		var (
+			x      = variable.NewContinuousRV(0.5, dist.AllRealSpace)
+			mu     = variable.NewContinuousRV(0.2, dist.AllRealSpace)
+			sigma  = variable.NewContinuousRV(1.5, dist.PositiveRealSpace)
+			factor = NewDistFactor([]variable.RandomVariable{x, mu, sigma},
+				dist.NewStandardNormalDist())
+		)
+		So(factor, ShouldImplement, (*DifferentiableFactor)(nil))
+		So(CanDifferentiate(factor), ShouldBeTrue)
+		So(factor.LogScoreGrad(), ShouldHaveLength, 3)
+		So(CheckLogScoreGrad(factor, 1e-6, 1e-5), ShouldBeNil)
+	})
+
+	Convey("Test non-differentiable DistFactor", t, func() {
+		var (
+			x      = variable.NewContinuousRV(0.5, dist.AllRealSpace)
+			factor = NewDistFactor([]variable.RandomVariable{x}, nil)
+		)
+		So(CanDifferentiate(factor), ShouldBeFalse)
+		So(func() { factor.LogScoreGrad() }, ShouldPanic)
+	})
+
+	Convey("Test CheckLogScoreGrad catches a bad gradient", t, func() {
+		var (
+			x      = variable.NewContinuousRV(0.5, dist.AllRealSpace)
+			factor = badGradFactor{NewConstFactor([]variable.RandomVariable{x}, 2)}
+		)
+		So(CheckLogScoreGrad(factor, 1e-6, 1e-5), ShouldNotBeNil)
+	})
+
+	Convey("Test FactorGraph gradient", t, func() {
+		var (
+			x     = variable.NewContinuousRV(0.3, dist.UnitIntervalSpace)
+			y     = variable.NewContinuousRV(0.6, dist.UnitIntervalSpace)
+			alpha = variable.NewContinuousRV(2, dist.PositiveRealSpace)
+			beta  = variable.NewContinuousRV(3, dist.PositiveRealSpace)
+			graph = NewFactorGraph()
+		)
+		graph.AddFactor(NewDistFactor([]variable.RandomVariable{x, alpha, beta},
+			dist.NewBetaDist(1, 1)))
+		graph.AddFactor(NewDistFactor([]variable.RandomVariable{y, alpha, beta},
+			dist.NewBetaDist(1, 1)))
+		graph.AddFactor(NewConstFactor([]variable.RandomVariable{x}, 0.5))
+
+		var grad = graph.LogScoreGrad()
+		So(grad, ShouldHaveLength, 4)
+		for i, v := range graph.Variables {
+			var (
+				cv  = v.Variable.(*variable.ContinuousRV)
+				val = cv.Val()
+			)
+			cv.Set(val + 1e-6)
+			var above = graph.Score()
+			cv.Set(val - 1e-6)
+			var below = graph.Score()
+			cv.Set(val)
+			So(grad[i], ShouldAlmostEqual, (above-below)/2e-6, 1e-4)
+			So(graph.LogScoreGradVar(cv), ShouldAlmostEqual, grad[i])
+		}
+		So(math.IsNaN(graph.Score()), ShouldBeFalse)
+	})
+}
+
+// A factor which reports the wrong gradient
+type badGradFactor struct{ *ConstFactor }
+
+func (f badGradFactor) Score() float64 {
+	return math.Exp(f.Vars[0].Val())
+}
+
+func (f badGradFactor) LogScoreGrad() []float64 {
+	return []float64{2}
+}
@@ -2,6 +2,7 @@ package factor
 
 import (
 	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
 	"github.com/jesand/stats/variable"
 	"math"
 )
@@ -10,16 +11,46 @@ import (
 func NewFactorGraph() *FactorGraph {
 	return &FactorGraph{
 		varIds: make(map[variable.RandomVariable]int),
+		cache:  newScoreCache(),
 	}
 }
 
 // A FactorGraph is a bipartite graph between random variables and factors.
 // The joint probability distribution over all random variables is the product
 // of all factors, calculated over their adjacent random variables.
+//
+// Variables is derived from Factors, and both should be changed through the
+// graph's methods. Direct changes which alter the length of Factors or
+// Variables are detected: scoring falls back to rescoring every factor until
+// the graph is next modified through its methods. Other direct changes, such
+// as replacing an element in place, are not detected, and must be followed by
+// Compact(). Changes to a factor itself, such as its Dist, need Invalidate().
 type FactorGraph struct {
 	Factors   []Factor
 	Variables []factorGraphVar
 	varIds    map[variable.RandomVariable]int
+
+	// The number of variables and the mutation count as of the last update
+	// to the indexes, to detect direct modification and stale copies
+	numVars int
+	version int
+
+	// Indexes of Variables by name and ID, rebuilt on demand because names
+	// and IDs can change after a variable is added
+	varsByName map[string]int
+	varsByID   map[int64]int
+
+	// DistFactors grouped by shared distribution and parameters, and all
+	// other factors, for batch scoring. These cover the first numBatched
+	// entries of Factors.
+	batches       []*distBatch
+	batchesByDist map[dist.Dist][]*distBatch
+	unbatched     []Factor
+	unbatchedIdx  []int
+	numBatched    int
+
+	// The log score of each factor, as of the last change to its variables
+	cache *scoreCache
 }
 
 type factorGraphVar struct {
@@ -29,7 +60,12 @@ type factorGraphVar struct {
 
 // Add a factor and its adjacent random variables to the graph
 func (graph *FactorGraph) AddFactor(factor Factor) {
+	if !graph.isIndexed() {
+		graph.Compact()
+	}
 	graph.Factors = append(graph.Factors, factor)
+	graph.addToBatch(factor)
+	graph.cache.add(len(graph.Factors)-1, factor)
 	for _, v := range factor.Adjacent() {
 		idx, ok := graph.varIds[v]
 		if ok {
@@ -42,6 +78,9 @@ func (graph *FactorGraph) AddFactor(factor Factor) {
 			graph.varIds[v] = idx
 		}
 	}
+	graph.numVars = len(graph.Variables)
+	graph.version++
+	graph.cache.version = graph.version
 }
 
 // Add multiple factors to the graph
@@ -56,15 +95,95 @@ func (graph FactorGraph) AdjToFactor(factor Factor) []variable.RandomVariable {
 	return factor.Adjacent()
 }
 
+// Ask whether a variable is adjacent to any factor in the graph
+func (graph FactorGraph) HasVariable(v variable.RandomVariable) bool {
+	_, ok := graph.varIds[v]
+	return ok
+}
+
 // Get factors adjacent to a variable
 func (graph FactorGraph) AdjToVariable(v variable.RandomVariable) []Factor {
 	if idx, ok := graph.varIds[v]; !ok {
-		panic(stats.Errorf("Random variable %#v not in factor graph", v))
+		panic(stats.ErrfVarNotInGraph(v))
 	} else {
 		return graph.Variables[idx].Factors
 	}
 }
 
+// Get the variable with a given name. If several variables share the name,
+// the first one added to the graph is returned.
+func (graph *FactorGraph) VariableByName(name string) (variable.RandomVariable, bool) {
+	if idx, ok := graph.varsByName[name]; ok && idx < len(graph.Variables) &&
+		variableName(graph.Variables[idx].Variable) == name {
+		return graph.Variables[idx].Variable, true
+	}
+	graph.indexVariables()
+	if idx, ok := graph.varsByName[name]; ok {
+		return graph.Variables[idx].Variable, true
+	}
+	return nil, false
+}
+
+// Get the variable with a given ID. If several variables share the ID, the
+// first one added to the graph is returned.
+func (graph *FactorGraph) VariableByID(id int64) (variable.RandomVariable, bool) {
+	if idx, ok := graph.varsByID[id]; ok && idx < len(graph.Variables) {
+		if iv, ok := graph.Variables[idx].Variable.(variable.IdentifiedVariable); ok && iv.ID() == id {
+			return iv, true
+		}
+	}
+	graph.indexVariables()
+	if idx, ok := graph.varsByID[id]; ok {
+		return graph.Variables[idx].Variable, true
+	}
+	return nil, false
+}
+
+// Rebuild the name and ID indexes of Variables
+func (graph *FactorGraph) indexVariables() {
+	graph.varsByName = make(map[string]int)
+	graph.varsByID = make(map[int64]int)
+	for idx := len(graph.Variables) - 1; idx >= 0; idx-- {
+		v, ok := graph.Variables[idx].Variable.(variable.IdentifiedVariable)
+		if !ok {
+			continue
+		} else if name := v.Name(); name != "" {
+			graph.varsByName[name] = idx
+		}
+		graph.varsByID[v.ID()] = idx
+	}
+}
+
+// Get a variable's name, or the empty string if it has no metadata
+func variableName(v variable.RandomVariable) string {
+	if iv, ok := v.(variable.IdentifiedVariable); ok {
+		return iv.Name()
+	}
+	return ""
+}
+
+// Get the variables which have not been observed
+func (graph FactorGraph) LatentVariables() []variable.RandomVariable {
+	var vars []variable.RandomVariable
+	for _, v := range graph.Variables {
+		if !variable.IsObserved(v.Variable) {
+			vars = append(vars, v.Variable)
+		}
+	}
+	return vars
+}
+
+// Get the variables which are clamped to observed values
+func (graph FactorGraph) ObservedVariables() []variable.RandomVariable {
+	var vars []variable.RandomVariable
+	for _, v := range graph.Variables {
+		if variable.IsObserved(v.Variable) {
+			vars = append(vars, v.Variable)
+		}
+	}
+	return vars
+}
+
 // Get the score (log probability measure) for a particular variable
 func (graph FactorGraph) ScoreVar(v variable.RandomVariable) float64 {
 	var score float64
@@ -74,11 +193,173 @@ func (graph FactorGraph) ScoreVar(v variable.RandomVariable) float64 {
 	return score
 }
 
-// Get the score (log probability) for the entire factor graph
+// Get the score (log probability) for the entire factor graph. The scores of
+// pure factors (see PureFactor) are cached, and only those adjacent to
+// variables which have changed since the last call are rescored. Other
+// factors are rescored every time. DistFactors which share a distribution and
+// parameter variables are scored in batches.
+//
+// The first call subscribes the graph to changes to its variables; call
+// Close() to unsubscribe. Score() is safe for concurrent use, as long as the
+// graph and its variables are not changed at the same time.
 func (graph FactorGraph) Score() float64 {
 	var score float64
-	for _, factor := range graph.Factors {
-		score += math.Log(factor.Score())
+	if !graph.isIndexed() {
+		// Factors was modified directly, so the batches and cache are stale
+		for _, factor := range graph.Factors {
+			score += math.Log(factor.Score())
+		}
+		return score
 	}
-	return score
+	graph.cache.mu.Lock()
+	defer graph.cache.mu.Unlock()
+	graph.cache.refresh(graph)
+	return graph.cache.total
+}
+
+// Get the change in the score (log probability) of the graph which would
+// result from setting a variable to a new value. Only the factors adjacent to
+// the variable are scored, and the variable's value is left unchanged.
+func (graph FactorGraph) ScoreDelta(v variable.RandomVariable, val float64) float64 {
+	var old = v.Val()
+	if !graph.isIndexed() {
+		before := graph.ScoreVar(v)
+		probe(v, val)
+		after := graph.ScoreVar(v)
+		probe(v, old)
+		return after - before
+	}
+
+	graph.cache.mu.Lock()
+	defer graph.cache.mu.Unlock()
+	factors, ok := graph.cache.varFactors[v]
+	if !ok {
+		panic(stats.ErrfVarNotInGraph(v))
+	}
+	graph.cache.refresh(graph)
+
+	// The value is restored, so the cached scores stay valid
+	var delta float64
+	graph.cache.muted = true
+	defer func() { graph.cache.muted = false }()
+	probe(v, val)
+	for _, idx := range factors {
+		delta += math.Log(graph.Factors[idx].Score()) - graph.cache.logScores[idx]
+	}
+	probe(v, old)
+	return delta
+}
+
+// Set a variable to a value in order to score it, even if it is observed. An
+// observed variable is released for the assignment and observed again.
+func probe(v variable.RandomVariable, val float64) {
+	if ov, ok := v.(variable.ObservableVariable); ok && ov.IsObserved() {
+		ov.Release()
+		ov.Set(val)
+		ov.Observe(val)
+	} else {
+		v.Set(val)
+	}
+}
+
+// Discard all cached factor scores. This is only needed after changing
+// something a pure factor's score depends on other than its variables'
+// values, such as replacing the Dist of a DistFactor. Changes to Factors or
+// Variables themselves need Compact() instead.
+func (graph FactorGraph) Invalidate() {
+	if graph.cache != nil {
+		graph.cache.mu.Lock()
+		defer graph.cache.mu.Unlock()
+		graph.cache.markAllDirty()
+	}
+}
+
+// Stop listening for changes to the graph's variables, so that a graph which
+// is no longer needed does not slow down changes to variables which are still
+// in use, or stay reachable through them. The graph listens again the next
+// time it is scored.
+func (graph *FactorGraph) Close() {
+	if graph.cache != nil {
+		graph.cache.mu.Lock()
+		defer graph.cache.mu.Unlock()
+		graph.cache.detach()
+	}
+}
+
+// Ask whether the indexes, batches and score cache cover every factor. This
+// is the case unless Factors or Variables was resized directly, or this is a
+// stale copy of a graph which has since been modified through its methods.
+func (graph FactorGraph) isIndexed() bool {
+	return graph.cache != nil && graph.numBatched == len(graph.Factors) &&
+		graph.numVars == len(graph.Variables) &&
+		graph.version == graph.cache.version
+}
+
+// Add a newly-added factor to a batch with the same distribution and
+// parameters, if it is a DistFactor, or to the unbatched factors otherwise
+func (graph *FactorGraph) addToBatch(factor Factor) {
+	graph.numBatched++
+	var idx = len(graph.Factors) - 1
+	df, ok := factor.(*DistFactor)
+	if !ok {
+		graph.unbatched = append(graph.unbatched, factor)
+		graph.unbatchedIdx = append(graph.unbatchedIdx, idx)
+		return
+	}
+	params, ok := batchParams(df)
+	if !ok {
+		graph.unbatched = append(graph.unbatched, factor)
+		graph.unbatchedIdx = append(graph.unbatchedIdx, idx)
+		return
+	}
+	if graph.batchesByDist == nil {
+		graph.batchesByDist = make(map[dist.Dist][]*distBatch)
+	}
+	for _, batch := range graph.batchesByDist[df.Dist] {
+		if batch.matches(df.Dist, params) {
+			batch.add(df, idx)
+			return
+		}
+	}
+	batch := &distBatch{dist: df.Dist, params: params}
+	batch.add(df, idx)
+	graph.batches = append(graph.batches, batch)
+	graph.batchesByDist[df.Dist] = append(graph.batchesByDist[df.Dist], batch)
+}
+
+// Get the gradient of the score (log probability) for the entire factor graph
+// with respect to each variable, in the order of graph.Variables. Panics if
+// any factor is not a DifferentiableFactor.
+func (graph FactorGraph) LogScoreGrad() []float64 {
+	var grad = make([]float64, len(graph.Variables))
+	for _, f := range graph.Factors {
+		df, ok := f.(DifferentiableFactor)
+		if !ok {
+			panic(stats.ErrfNotDifferentiable(f))
+		}
+		var adj = f.Adjacent()
+		for i, d := range df.LogScoreGrad() {
+			grad[graph.varIds[adj[i]]] += d
+		}
+	}
+	return grad
+}
+
+// Get the gradient of the score (log probability) for a particular variable,
+// summed over its adjacent factors
+func (graph FactorGraph) LogScoreGradVar(v variable.RandomVariable) float64 {
+	var grad float64
+	for _, f := range graph.AdjToVariable(v) {
+		df, ok := f.(DifferentiableFactor)
+		if !ok {
+			panic(stats.ErrfNotDifferentiable(f))
+		}
+		var d = df.LogScoreGrad()
+		for i, adj := range f.Adjacent() {
+			if adj == v {
+				grad += d[i]
+			}
+		}
+	}
+	return grad
 }
@@ -0,0 +1,119 @@
+package factor
+
+import (
+	"github.com/jesand/stats/variable"
+	"reflect"
+)
+
+// Rebuild the graph's indexes, batches and score cache from Factors.
+// Variables which are no longer adjacent to any factor are dropped, and the
+// rest keep their order. This must be called after replacing elements of
+// Factors or Variables directly; direct changes to their lengths are detected
+// by the graph's methods, which call this as needed.
+func (graph *FactorGraph) Compact() {
+	var (
+		factors = graph.Factors
+		used    = make(map[variable.RandomVariable]bool)
+	)
+	for _, f := range factors {
+		for _, v := range f.Adjacent() {
+			used[v] = true
+		}
+	}
+	graph.Close()
+	var old = graph.Variables
+	*graph = FactorGraph{
+		varIds: make(map[variable.RandomVariable]int),
+		cache:  newScoreCache(),
+	}
+	for _, v := range old {
+		if _, ok := graph.varIds[v.Variable]; !ok && used[v.Variable] {
+			graph.varIds[v.Variable] = len(graph.Variables)
+			graph.Variables = append(graph.Variables, factorGraphVar{Variable: v.Variable})
+		}
+	}
+	graph.numVars = len(graph.Variables)
+	for _, f := range factors {
+		graph.AddFactor(f)
+	}
+}
+
+// Remove a factor from the graph, along with any variables which are no
+// longer adjacent to a factor. Factors are compared with ==, so they should
+// be pointers. Returns false if the factor is not in the graph.
+func (graph *FactorGraph) RemoveFactor(factor Factor) bool {
+	return graph.RemoveFactors(factor) > 0
+}
+
+// Remove several factors from the graph, along with any variables which are
+// no longer adjacent to a factor. This is faster than removing them one at a
+// time. Returns the number of factors removed.
+func (graph *FactorGraph) RemoveFactors(factors ...Factor) int {
+	var kept = make([]Factor, 0, len(graph.Factors))
+	for _, f := range graph.Factors {
+		var remove bool
+		for _, other := range factors {
+			if sameFactor(f, other) {
+				remove = true
+				break
+			}
+		}
+		if !remove {
+			kept = append(kept, f)
+		}
+	}
+	var removed = len(graph.Factors) - len(kept)
+	if removed > 0 {
+		graph.Factors = kept
+		graph.Compact()
+	}
+	return removed
+}
+
+// Remove a variable and all factors adjacent to it from the graph, along with
+// any other variables which are no longer adjacent to a factor. Returns the
+// removed factors, or nil if the variable is not in the graph.
+func (graph *FactorGraph) RemoveVariable(v variable.RandomVariable) []Factor {
+	if !graph.isIndexed() {
+		graph.Compact()
+	}
+	if !graph.HasVariable(v) {
+		return nil
+	}
+	var factors []Factor
+	for _, f := range graph.AdjToVariable(v) {
+		var seen bool
+		for _, other := range factors {
+			seen = seen || sameFactor(f, other)
+		}
+		if !seen {
+			factors = append(factors, f)
+		}
+	}
+	graph.RemoveFactors(factors...)
+	return factors
+}
+
+// Replace a factor with another, which takes its position in Factors.
+// Variables are added and removed as needed. Returns false if the old
+// factor is not in the graph.
+func (graph *FactorGraph) ReplaceFactor(old, replacement Factor) bool {
+	for i, f := range graph.Factors {
+		if sameFactor(f, old) {
+			graph.Factors[i] = replacement
+			graph.Compact()
+			return true
+		}
+	}
+	return false
+}
+
+// Ask whether two factors are the same. Factors whose types cannot be
+// compared with == are never the same.
+func sameFactor(f1, f2 Factor) bool {
+	if f1 == nil || f2 == nil {
+		return f1 == f2
+	}
+	var t1, t2 = reflect.TypeOf(f1), reflect.TypeOf(f2)
+	return t1 == t2 && t1.Comparable() && f1 == f2
+}
@@ -0,0 +1,103 @@
+package factor
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+func TestMutableGraph(t *testing.T) {
+	Convey("Given a graph of Bernoulli observations and a Beta prior", t, func() {
+		var (
+			graph, bias = bernoulliGraph(5)
+			alpha       = variable.NewContinuousRV(2, dist.PositiveRealSpace)
+			beta        = variable.NewContinuousRV(3, dist.PositiveRealSpace)
+			prior       = NewDistFactor([]variable.RandomVariable{bias, alpha, beta}, dist.NewBetaDist(1, 1))
+		)
+		graph.AddFactor(prior)
+		naive := func() float64 {
+			var score float64
+			for _, f := range graph.Factors {
+				score += math.Log(f.Score())
+			}
+			return score
+		}
+		graph.Score()
+
+		Convey("RemoveFactor drops the factor and unused variables", func() {
+			obs := graph.Factors[1]
+			x := obs.Adjacent()[0]
+			So(graph.RemoveFactor(obs), ShouldBeTrue)
+			So(graph.RemoveFactor(obs), ShouldBeFalse)
+			So(graph.Factors, ShouldHaveLength, 5)
+			So(graph.Variables, ShouldHaveLength, 7)
+			So(graph.HasVariable(x), ShouldBeFalse)
+			So(graph.Variables[0].Variable, ShouldEqual, graph.Factors[0].Adjacent()[0])
+			So(graph.AdjToVariable(bias), ShouldHaveLength, 5)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+
+			x.Set(1 - x.Val())
+			So(graph.cache.dirtyIdx, ShouldBeEmpty)
+		})
+
+		Convey("RemoveVariable drops its factors", func() {
+			removed := graph.RemoveVariable(alpha)
+			So(removed, ShouldResemble, []Factor{prior})
+			So(graph.HasVariable(beta), ShouldBeFalse)
+			So(graph.HasVariable(bias), ShouldBeTrue)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+
+			So(graph.RemoveVariable(alpha), ShouldBeNil)
+			graph.RemoveVariable(bias)
+			So(graph.Factors, ShouldBeEmpty)
+			So(graph.Variables, ShouldBeEmpty)
+			So(graph.Score(), ShouldEqual, 0)
+		})
+
+		Convey("ReplaceFactor keeps the factor's position", func() {
+			c := NewConstFactor([]variable.RandomVariable{alpha}, 0.5)
+			So(graph.ReplaceFactor(prior, c), ShouldBeTrue)
+			So(graph.Factors[5], ShouldEqual, c)
+			So(graph.HasVariable(beta), ShouldBeFalse)
+			So(graph.AdjToVariable(alpha), ShouldResemble, []Factor{c})
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			So(graph.ReplaceFactor(prior, c), ShouldBeFalse)
+		})
+
+		Convey("Direct modification is detected by later method calls", func() {
+			graph.Factors = graph.Factors[1:]
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			graph.AddFactor(NewConstFactor([]variable.RandomVariable{alpha}, 0.25))
+			So(graph.isIndexed(), ShouldBeTrue)
+			So(graph.Variables, ShouldHaveLength, 7)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+
+			graph.Factors[0] = NewConstFactor([]variable.RandomVariable{beta}, 0.5)
+			graph.Compact()
+			So(graph.isIndexed(), ShouldBeTrue)
+			So(graph.Score(), ShouldAlmostEqual, naive())
+			So(graph.Variables, ShouldHaveLength, 6)
+
+			graph.Variables = graph.Variables[:5]
+			So(graph.isIndexed(), ShouldBeFalse)
+			graph.AddFactor(NewConstFactor([]variable.RandomVariable{beta}, 0.5))
+			So(graph.isIndexed(), ShouldBeTrue)
+			So(graph.Variables, ShouldHaveLength, 6)
+		})
+
+		Convey("Stale copies of a graph are detected", func() {
+			var copied = *graph
+			graph.AddFactor(NewConstFactor([]variable.RandomVariable{alpha}, 0.25))
+			So(copied.isIndexed(), ShouldBeFalse)
+			So(copied.Score(), ShouldAlmostEqual, naive()-math.Log(0.25))
+		})
+
+		Convey("A zero-valued graph can be built", func() {
+			var g FactorGraph
+			g.AddFactor(prior)
+			So(g.Score(), ShouldAlmostEqual, math.Log(prior.Score()))
+		})
+	})
+}
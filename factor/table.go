@@ -0,0 +1,305 @@
+package factor
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	"math"
+)
+
+// Create a new table factor over the given discrete variables, which must
+// have finite spaces. The values are given in row-major order, with the last
+// variable varying fastest. If values is nil, all values are zero.
+func NewTableFactor(vars []*variable.DiscreteRV, values []float64) *TableFactor {
+	var size = 1
+	for _, v := range vars {
+		if v.Space().Size() < 0 {
+			panic(stats.ErrfInfiniteSpace(v))
+		}
+		size *= v.Space().Size()
+	}
+	if values == nil {
+		values = make([]float64, size)
+	} else if len(values) != size {
+		panic(stats.ErrfTableSize(size, len(values)))
+	}
+	return &TableFactor{
+		Vars:   vars,
+		Values: values,
+	}
+}
+
+// Create a table factor with the same scores as another factor, by
+// enumerating the outcomes of its adjacent latent discrete variables. Other
+// adjacent variables are held at their current values, so the table is
+// conditioned on them. Variable values are restored afterwards.
+func NewTableFactorFrom(factor Factor) *TableFactor {
+	var vars []*variable.DiscreteRV
+	for _, v := range factor.Adjacent() {
+		if dv, ok := v.(*variable.DiscreteRV); ok && !dv.IsObserved() &&
+			indexOfVar(vars, dv) < 0 {
+			vars = append(vars, dv)
+		}
+	}
+	var (
+		table = NewTableFactor(vars, nil)
+		saved = make([]dist.Outcome, len(vars))
+	)
+	for i, v := range vars {
+		saved[i] = v.Outcome()
+		v.SetOutcome(0)
+	}
+	for i := range table.Values {
+		table.Values[i] = factor.Score()
+		for j := len(vars) - 1; j >= 0; j-- {
+			if next := vars[j].Outcome() + 1; int(next) < vars[j].Space().Size() {
+				vars[j].SetOutcome(next)
+				break
+			}
+			vars[j].SetOutcome(0)
+		}
+	}
+	for i, v := range vars {
+		v.SetOutcome(saved[i])
+	}
+	return table
+}
+
+// A factor defined by an explicit table of non-negative values, one for each
+// joint outcome of a set of discrete random variables. Values are stored in
+// row-major order, with the last variable varying fastest.
+type TableFactor struct {
+	Vars   []*variable.DiscreteRV
+	Values []float64
+}
+
+// The adjacent random variables
+func (factor TableFactor) Adjacent() []variable.RandomVariable {
+	var vars = make([]variable.RandomVariable, len(factor.Vars))
+	for i, v := range factor.Vars {
+		vars[i] = v
+	}
+	return vars
+}
+
+// The table value for the current outcomes of the variables
+func (factor TableFactor) Score() float64 {
+	var idx int
+	for _, v := range factor.Vars {
+		idx = idx*v.Space().Size() + int(v.Outcome())
+	}
+	return factor.Values[idx]
+}
+
+// The gradient of the log score with respect to each adjacent variable, which
+// is always zero because all variables are discrete
+func (factor TableFactor) LogScoreGrad() []float64 {
+	return make([]float64, len(factor.Vars))
+}
+
+// Get the index into Values of a joint outcome of the variables
+func (factor TableFactor) Index(outcomes []dist.Outcome) int {
+	var idx int
+	for i, v := range factor.Vars {
+		size := v.Space().Size()
+		if outcomes[i] < 0 || int(outcomes[i]) >= size {
+			panic(stats.ErrfNotInDomain(int(outcomes[i])))
+		}
+		idx = idx*size + int(outcomes[i])
+	}
+	return idx
+}
+
+// Get the joint outcome of the variables for an index into Values
+func (factor TableFactor) Outcomes(idx int) []dist.Outcome {
+	var outcomes = make([]dist.Outcome, len(factor.Vars))
+	for i := len(factor.Vars) - 1; i >= 0; i-- {
+		size := factor.Vars[i].Space().Size()
+		outcomes[i] = dist.Outcome(idx % size)
+		idx /= size
+	}
+	return outcomes
+}
+
+// Get the table value for a joint outcome of the variables
+func (factor TableFactor) Value(outcomes ...dist.Outcome) float64 {
+	return factor.Values[factor.Index(outcomes)]
+}
+
+// Get the sum of all table values
+func (factor TableFactor) Sum() float64 {
+	var sum float64
+	for _, v := range factor.Values {
+		sum += v
+	}
+	return sum
+}
+
+// Scale the table values so they sum to one. Panics if they sum to zero.
+func (factor *TableFactor) Normalize() {
+	var sum = factor.Sum()
+	if sum == 0 {
+		panic(stats.ErrZeroProb)
+	}
+	for i, v := range factor.Values {
+		factor.Values[i] = v / sum
+	}
+}
+
+// Return the product of two table factors, over the union of their variables
+func (factor TableFactor) Product(other *TableFactor) *TableFactor {
+	var vars = append([]*variable.DiscreteRV(nil), factor.Vars...)
+	for _, v := range other.Vars {
+		if indexOfVar(vars, v) < 0 {
+			vars = append(vars, v)
+		}
+	}
+	var (
+		result = NewTableFactor(vars, nil)
+		iter   = newTableIter(vars, factor.strides(vars), other.strides(vars))
+	)
+	for i := range result.Values {
+		result.Values[i] = factor.Values[iter.idx[0]] * other.Values[iter.idx[1]]
+		iter.next()
+	}
+	return result
+}
+
+// Return a table factor with the given variables summed out
+func (factor TableFactor) SumOut(vars ...*variable.DiscreteRV) *TableFactor {
+	return factor.marginalize(vars, 0, func(a, b float64) float64 {
+		return a + b
+	})
+}
+
+// Return a table factor with the given variables maximized out
+func (factor TableFactor) MaxOut(vars ...*variable.DiscreteRV) *TableFactor {
+	return factor.marginalize(vars, math.Inf(-1), math.Max)
+}
+
+// Return a table factor over the remaining variables, combining the values
+// for each outcome of the eliminated variables
+func (factor TableFactor) marginalize(vars []*variable.DiscreteRV, init float64,
+	combine func(a, b float64) float64) *TableFactor {
+
+	var keep []*variable.DiscreteRV
+	for _, v := range factor.Vars {
+		if indexOfVar(vars, v) < 0 {
+			keep = append(keep, v)
+		}
+	}
+	var (
+		result = NewTableFactor(keep, nil)
+		iter   = newTableIter(factor.Vars, result.strides(factor.Vars))
+	)
+	for i := range result.Values {
+		result.Values[i] = init
+	}
+	for _, val := range factor.Values {
+		result.Values[iter.idx[0]] = combine(result.Values[iter.idx[0]], val)
+		iter.next()
+	}
+	return result
+}
+
+// Return a table factor with a variable fixed to a particular outcome, which
+// is no longer one of its variables. Panics if the variable is not adjacent.
+func (factor TableFactor) Reduce(v *variable.DiscreteRV, outcome dist.Outcome) *TableFactor {
+	var pos = indexOfVar(factor.Vars, v)
+	if pos < 0 {
+		panic(stats.ErrfVarNotInFactor(v))
+	} else if outcome < 0 || int(outcome) >= v.Space().Size() {
+		panic(stats.ErrfNotInDomain(int(outcome)))
+	}
+	var keep = append(append([]*variable.DiscreteRV(nil), factor.Vars[:pos]...),
+		factor.Vars[pos+1:]...)
+	var (
+		result = NewTableFactor(keep, nil)
+		iter   = newTableIter(keep, factor.strides(keep))
+		offset = factor.strides(factor.Vars[pos : pos+1])[0] * int(outcome)
+	)
+	for i := range result.Values {
+		result.Values[i] = factor.Values[offset+iter.idx[0]]
+		iter.next()
+	}
+	return result
+}
+
+// Return a table factor with every observed variable fixed to its observed
+// outcome
+func (factor TableFactor) ReduceEvidence() *TableFactor {
+	var result = NewTableFactor(factor.Vars, append([]float64(nil), factor.Values...))
+	for _, v := range factor.Vars {
+		if v.IsObserved() {
+			result = result.Reduce(v, v.Outcome())
+		}
+	}
+	return result
+}
+
+// For each of the given variables, the stride of that variable in Values,
+// or zero if it is not one of the factor's variables
+func (factor TableFactor) strides(vars []*variable.DiscreteRV) []int {
+	var (
+		strides = make([]int, len(vars))
+		stride  = 1
+	)
+	for i := len(factor.Vars) - 1; i >= 0; i-- {
+		if pos := indexOfVar(vars, factor.Vars[i]); pos >= 0 {
+			strides[pos] = stride
+		}
+		stride *= factor.Vars[i].Space().Size()
+	}
+	return strides
+}
+
+// Iterates over the joint outcomes of a list of variables in row-major order,
+// tracking the matching index into each of several tables
+type tableIter struct {
+	sizes    []int
+	strides  [][]int
+	outcomes []int
+	idx      []int
+}
+
+// Create an iterator over the joint outcomes of vars, given the strides of
+// each variable in each table
+func newTableIter(vars []*variable.DiscreteRV, strides ...[]int) *tableIter {
+	var iter = &tableIter{
+		sizes:    make([]int, len(vars)),
+		strides:  strides,
+		outcomes: make([]int, len(vars)),
+		idx:      make([]int, len(strides)),
+	}
+	for i, v := range vars {
+		iter.sizes[i] = v.Space().Size()
+	}
+	return iter
+}
+
+// Advance to the next joint outcome
+func (iter *tableIter) next() {
+	for j := len(iter.sizes) - 1; j >= 0; j-- {
+		iter.outcomes[j]++
+		for t, s := range iter.strides {
+			iter.idx[t] += s[j]
+		}
+		if iter.outcomes[j] < iter.sizes[j] {
+			return
+		}
+		iter.outcomes[j] = 0
+		for t, s := range iter.strides {
+			iter.idx[t] -= s[j] * iter.sizes[j]
+		}
+	}
+}
+
+// Get the position of a variable in a list, or -1 if it is not present
+func indexOfVar(vars []*variable.DiscreteRV, v *variable.DiscreteRV) int {
+	for i, other := range vars {
+		if other == v {
+			return i
+		}
+	}
+	return -1
+}
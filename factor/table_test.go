@@ -0,0 +1,95 @@
+package factor
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestTableFactor(t *testing.T) {
+	Convey("Test TableFactor interfaces", t, func() {
+		So(&TableFactor{}, ShouldImplement, (*Factor)(nil))
+		So(&TableFactor{}, ShouldImplement, (*DifferentiableFactor)(nil))
+	})
+
+	Convey("Given tables over discrete variables", t, func() {
+		var (
+			three = dist.NewIntegerIntervalSpace(0, 2)
+			x     = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			y     = variable.NewDiscreteRV(0, three)
+			z     = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			fxy   = NewTableFactor([]*variable.DiscreteRV{x, y}, []float64{1, 2, 3, 4, 5, 6})
+			fyz   = NewTableFactor([]*variable.DiscreteRV{y, z}, []float64{1, 2, 3, 4, 5, 6})
+		)
+
+		Convey("Values are indexed in row-major order", func() {
+			So(fxy.Value(1, 0), ShouldEqual, 4)
+			So(fxy.Index([]dist.Outcome{0, 2}), ShouldEqual, 2)
+			So(fxy.Outcomes(4), ShouldResemble, []dist.Outcome{1, 1})
+			y.SetOutcome(2)
+			So(fxy.Score(), ShouldEqual, 3)
+			So(func() { fxy.Value(2, 0) }, ShouldPanic)
+			So(func() { NewTableFactor([]*variable.DiscreteRV{x}, []float64{1}) }, ShouldPanic)
+		})
+
+		Convey("Product multiplies matching entries", func() {
+			f := fxy.Product(fyz)
+			So(f.Vars, ShouldResemble, []*variable.DiscreteRV{x, y, z})
+			for _, xo := range []dist.Outcome{0, 1} {
+				for _, yo := range []dist.Outcome{0, 1, 2} {
+					for _, zo := range []dist.Outcome{0, 1} {
+						So(f.Value(xo, yo, zo), ShouldEqual, fxy.Value(xo, yo)*fyz.Value(yo, zo))
+					}
+				}
+			}
+		})
+
+		Convey("SumOut and MaxOut eliminate variables", func() {
+			f := fxy.SumOut(x)
+			So(f.Vars, ShouldResemble, []*variable.DiscreteRV{y})
+			So(f.Values, ShouldResemble, []float64{5, 7, 9})
+			So(fxy.SumOut(y).Values, ShouldResemble, []float64{6, 15})
+			So(fxy.MaxOut(y).Values, ShouldResemble, []float64{3, 6})
+			So(fxy.SumOut(x, y).Values, ShouldResemble, []float64{21})
+		})
+
+		Convey("Reduce conditions on an outcome", func() {
+			f := fxy.Reduce(y, 1)
+			So(f.Vars, ShouldResemble, []*variable.DiscreteRV{x})
+			So(f.Values, ShouldResemble, []float64{2, 5})
+			So(fxy.Reduce(x, 1).Values, ShouldResemble, []float64{4, 5, 6})
+			So(func() { fxy.Reduce(z, 0) }, ShouldPanic)
+
+			x.ObserveOutcome(0)
+			So(fxy.ReduceEvidence().Values, ShouldResemble, []float64{1, 2, 3})
+		})
+
+		Convey("Normalize scales values to sum to one", func() {
+			f := fxy.SumOut(y)
+			f.Normalize()
+			So(f.Values[0], ShouldAlmostEqual, 6.0/21)
+			So(f.Sum(), ShouldAlmostEqual, 1)
+			So(func() { NewTableFactor(nil, []float64{0}).Normalize() }, ShouldPanic)
+		})
+	})
+
+	Convey("Test conversion from a DistFactor over Bernoulli variables", t, func() {
+		var (
+			x    = variable.NewDiscreteRV(1, dist.BooleanSpace)
+			z    = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			bias = variable.NewContinuousRV(0.3, dist.UnitIntervalSpace)
+			bern = dist.NewBernoulliDist(0.5)
+		)
+		f := NewTableFactorFrom(NewDistFactor([]variable.RandomVariable{x, bias}, bern))
+		So(f.Vars, ShouldResemble, []*variable.DiscreteRV{x})
+		So(f.Values[0], ShouldAlmostEqual, 0.7)
+		So(f.Values[1], ShouldAlmostEqual, 0.3)
+		So(x.Outcome(), ShouldEqual, 1)
+
+		z.ObserveOutcome(1)
+		g := NewTableFactorFrom(NewTableFactor([]*variable.DiscreteRV{x, z}, []float64{1, 2, 3, 4}))
+		So(g.Vars, ShouldResemble, []*variable.DiscreteRV{x})
+		So(g.Values, ShouldResemble, []float64{2, 4})
+	})
+}
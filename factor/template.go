@@ -0,0 +1,195 @@
+package factor
+
+import (
+	"fmt"
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/variable"
+)
+
+// Create a new plate with the given item keys
+func NewPlate(name string, keys ...string) *Plate {
+	var plate = &Plate{Name: name}
+	for _, key := range keys {
+		plate.Add(key)
+	}
+	return plate
+}
+
+// A plate is a named set of items, such as the items or workers of a
+// crowdsourcing data set, which are identified by key and indexed in the
+// order they were added
+type Plate struct {
+	Name  string
+	Keys  []string
+	index map[string]int
+}
+
+// Get the index of an item, adding it if it is new
+func (plate *Plate) Add(key string) int {
+	if idx, ok := plate.index[key]; ok {
+		return idx
+	} else if plate.index == nil {
+		plate.index = make(map[string]int)
+	}
+	plate.index[key] = len(plate.Keys)
+	plate.Keys = append(plate.Keys, key)
+	return len(plate.Keys) - 1
+}
+
+// Get the index of an item, and whether it is in the plate
+func (plate Plate) Index(key string) (int, bool) {
+	idx, ok := plate.index[key]
+	return idx, ok
+}
+
+// Get the number of items in the plate
+func (plate Plate) Size() int {
+	return len(plate.Keys)
+}
+
+// Create a new family of variables over the items of a plate. The function
+// creates the variable for an item the first time it is requested.
+func NewPlateVar(name string, plate *Plate,
+	create func(key string) variable.RandomVariable) *PlateVar {
+
+	return &PlateVar{
+		Name:   name,
+		Plate:  plate,
+		Create: create,
+	}
+}
+
+// A family of random variables with one variable for each item of a plate,
+// such as the true label of each item
+type PlateVar struct {
+	Name   string
+	Plate  *Plate
+	Create func(key string) variable.RandomVariable
+
+	// The variable for each item, indexed like Plate.Keys, or nil if it has
+	// not been created
+	Vars []variable.RandomVariable
+}
+
+// Get the variable for an item, creating it if necessary. New variables are
+// named "Name[key]" unless Create names them, and tagged with the plate name
+// and item key.
+func (pv *PlateVar) Get(item int) variable.RandomVariable {
+	if item < 0 || item >= pv.Plate.Size() {
+		panic(stats.Errorf("Item %d not in plate %s", item, pv.Plate.Name))
+	}
+	for len(pv.Vars) <= item {
+		pv.Vars = append(pv.Vars, nil)
+	}
+	if pv.Vars[item] == nil {
+		var (
+			key = pv.Plate.Keys[item]
+			v   = pv.Create(key)
+		)
+		if tv, ok := v.(taggedVariable); ok {
+			if variableName(v) == "" {
+				tv.SetName(fmt.Sprintf("%s[%s]", pv.Name, key))
+			}
+			tv.SetTag("plate", pv.Plate.Name)
+			tv.SetTag("item", key)
+		}
+		pv.Vars[item] = v
+	}
+	return pv.Vars[item]
+}
+
+// A variable whose metadata can be changed
+type taggedVariable interface {
+	SetName(name string)
+	SetTag(key, val string)
+}
+
+// A row of a data table: the key of an item in each of a template's plates,
+// and any observed values
+type Row struct {
+	Keys   []string
+	Values []float64
+}
+
+// Create a new template over the given plates, whose factors share the given
+// parameter variables
+func NewTemplate(name string, plates []*Plate, params []variable.RandomVariable,
+	build func(items []int, values []float64) Factor) *Template {
+
+	return &Template{
+		Name:   name,
+		Plates: plates,
+		Params: params,
+		Build:  build,
+	}
+}
+
+// A template for a factor repeated over the items of one or more plates,
+// such as one factor per (item, worker) judgment. The factors' parameters are
+// tied: every instance shares the variables in Params.
+type Template struct {
+	Name   string
+	Plates []*Plate
+	Params []variable.RandomVariable
+
+	// Build the factor for an item in each plate, indexed like Plates, given
+	// the values from the row of data. Returns nil to skip the row.
+	Build func(items []int, values []float64) Factor
+
+	// The factors built from the template, in order
+	Instances []TemplateInstance
+}
+
+// A factor built from a template, and the items it was built for
+type TemplateInstance struct {
+	Factor Factor
+	Items  []int
+}
+
+// Build a factor for each row of data and add it to the graph. Items new to
+// a plate are added to it. Returns an error, without adding any factors, if a
+// row does not have one key per plate.
+func (tmpl *Template) Instantiate(graph *FactorGraph, rows []Row) error {
+	for i, row := range rows {
+		if len(row.Keys) != len(tmpl.Plates) {
+			return stats.Errorf("Row %d of template %s has %d key(s), but expected %d",
+				i, tmpl.Name, len(row.Keys), len(tmpl.Plates))
+		}
+	}
+	for _, row := range rows {
+		var items = make([]int, len(tmpl.Plates))
+		for j, plate := range tmpl.Plates {
+			items[j] = plate.Add(row.Keys[j])
+		}
+		if f := tmpl.Build(items, row.Values); f != nil {
+			graph.AddFactor(f)
+			tmpl.Instances = append(tmpl.Instances, TemplateInstance{Factor: f, Items: items})
+		}
+	}
+	return nil
+}
+
+// Get the factors built from the template
+func (tmpl Template) Factors() []Factor {
+	var factors = make([]Factor, len(tmpl.Instances))
+	for i, inst := range tmpl.Instances {
+		factors[i] = inst.Factor
+	}
+	return factors
+}
+
+// Get the factors built for a particular item of one of the template's plates
+func (tmpl Template) FactorsForItem(plate *Plate, item int) []Factor {
+	var factors []Factor
+	for j, p := range tmpl.Plates {
+		if p != plate {
+			continue
+		}
+		for _, inst := range tmpl.Instances {
+			if inst.Items[j] == item {
+				factors = append(factors, inst.Factor)
+			}
+		}
+	}
+	return factors
+}
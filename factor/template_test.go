@@ -0,0 +1,88 @@
+package factor
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	Convey("Test plates", t, func() {
+		plate := NewPlate("items", "a", "b")
+		So(plate.Size(), ShouldEqual, 2)
+		So(plate.Add("b"), ShouldEqual, 1)
+		So(plate.Add("c"), ShouldEqual, 2)
+		idx, ok := plate.Index("c")
+		So(ok, ShouldBeTrue)
+		So(idx, ShouldEqual, 2)
+		_, ok = plate.Index("d")
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Given a crowdsourcing model built from templates", t, func() {
+		var (
+			graph   = NewFactorGraph()
+			items   = NewPlate("items")
+			workers = NewPlate("workers")
+			bias    = variable.NewContinuousRV(0.5, dist.UnitIntervalSpace)
+			bern    = dist.NewBernoulliDist(0.5)
+			labels  = NewPlateVar("label", items, func(key string) variable.RandomVariable {
+				return variable.NewDiscreteRV(0, dist.BooleanSpace)
+			})
+			skill = NewPlateVar("skill", workers, func(key string) variable.RandomVariable {
+				return variable.NewContinuousRV(0.8, dist.UnitIntervalSpace)
+			})
+			prior = NewTemplate("prior", []*Plate{items}, []variable.RandomVariable{bias},
+				func(idx []int, vals []float64) Factor {
+					return NewDistFactor([]variable.RandomVariable{labels.Get(idx[0]), bias}, bern)
+				})
+			judgment = NewTemplate("judgment", []*Plate{items, workers}, nil,
+				func(idx []int, vals []float64) Factor {
+					out := variable.NewDiscreteRV(0, dist.BooleanSpace)
+					out.Observe(vals[0])
+					return NewDistFactor([]variable.RandomVariable{out, skill.Get(idx[1])}, bern)
+				})
+		)
+		So(judgment.Instantiate(graph, []Row{
+			{Keys: []string{"q1", "alice"}, Values: []float64{1}},
+			{Keys: []string{"q1", "bob"}, Values: []float64{0}},
+			{Keys: []string{"q2", "alice"}, Values: []float64{1}},
+		}), ShouldBeNil)
+		So(prior.Instantiate(graph, []Row{{Keys: []string{"q1"}}, {Keys: []string{"q2"}}}), ShouldBeNil)
+
+		Convey("Factors are added for each row", func() {
+			So(graph.Factors, ShouldHaveLength, 5)
+			So(items.Keys, ShouldResemble, []string{"q1", "q2"})
+			So(workers.Keys, ShouldResemble, []string{"alice", "bob"})
+			So(graph.Score(), ShouldAlmostEqual, math.Log(0.8*0.2*0.8*0.5*0.5))
+		})
+
+		Convey("Plate variables are shared and named", func() {
+			So(skill.Vars, ShouldHaveLength, 2)
+			So(variableName(skill.Get(0)), ShouldEqual, "skill[alice]")
+			v, ok := graph.VariableByName("label[q2]")
+			So(ok, ShouldBeTrue)
+			So(v, ShouldEqual, labels.Get(1))
+			tag, _ := v.(*variable.DiscreteRV).Tag("plate")
+			So(tag, ShouldEqual, "items")
+			So(graph.AdjToVariable(skill.Get(0)), ShouldHaveLength, 2)
+		})
+
+		Convey("Templates record the factors built for each item", func() {
+			So(judgment.Factors(), ShouldHaveLength, 3)
+			So(judgment.FactorsForItem(workers, 0), ShouldResemble,
+				[]Factor{judgment.Instances[0].Factor, judgment.Instances[2].Factor})
+			So(judgment.FactorsForItem(items, 1), ShouldResemble, []Factor{judgment.Instances[2].Factor})
+			So(prior.Params, ShouldResemble, []variable.RandomVariable{bias})
+			So(prior.Factors(), ShouldResemble, graph.AdjToVariable(bias))
+		})
+
+		Convey("Rows with the wrong number of keys are rejected", func() {
+			So(judgment.Instantiate(graph, []Row{{Keys: []string{"q3"}}}), ShouldNotBeNil)
+			So(graph.Factors, ShouldHaveLength, 5)
+			So(items.Size(), ShouldEqual, 2)
+		})
+	})
+}
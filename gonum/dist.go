@@ -0,0 +1,352 @@
+/*
+ * Package gonum adapts this library to gonum (https://www.gonum.org). Gonum's
+ * univariate distributions can be wrapped as dist.ContinuousDist values, and
+ * our distributions can be used wherever gonum expects a distuv.Rander or
+ * distuv.LogProber. Sample arrays convert to and from gonum matrices, and
+ * factor graphs can be handed to gonum's optimizers.
+ */
+package gonum
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"gonum.org/v1/gonum/stat/distuv"
+	"math"
+	"math/rand/v2"
+)
+
+// The methods shared by gonum's univariate continuous distributions, such as
+// distuv.Normal, distuv.Beta and distuv.Gamma
+type Univariate interface {
+	distuv.RandLogProber
+
+	// The value of the CDF at x
+	CDF(x float64) float64
+
+	// The density at x
+	Prob(x float64) float64
+
+	// The mean of the distribution
+	Mean() float64
+
+	// The variance of the distribution
+	Variance() float64
+}
+
+// A parametric family of gonum distributions
+type Family struct {
+
+	// The number of parameters which index the family
+	NumParams int
+
+	// Construct the member of the family with the given parameters
+	New func(params []float64, src rand.Source) Univariate
+
+	// The sample space of the member with the given parameters
+	Space func(params []float64) dist.RealSpace
+}
+
+// Normal distributions with parameters (Mu, Sigma)
+var NormalFamily = Family{
+	NumParams: 2,
+	New: func(params []float64, src rand.Source) Univariate {
+		return distuv.Normal{Mu: params[0], Sigma: params[1], Src: src}
+	},
+	Space: func(params []float64) dist.RealSpace { return dist.AllRealSpace },
+}
+
+// Beta distributions with parameters (Alpha, Beta)
+var BetaFamily = Family{
+	NumParams: 2,
+	New: func(params []float64, src rand.Source) Univariate {
+		return distuv.Beta{Alpha: params[0], Beta: params[1], Src: src}
+	},
+	Space: func(params []float64) dist.RealSpace { return dist.UnitIntervalSpace },
+}
+
+// Gamma distributions with parameters (Alpha, Beta), where Alpha is the shape
+// and Beta is the rate
+var GammaFamily = Family{
+	NumParams: 2,
+	New: func(params []float64, src rand.Source) Univariate {
+		return distuv.Gamma{Alpha: params[0], Beta: params[1], Src: src}
+	},
+	Space: func(params []float64) dist.RealSpace { return dist.PositiveRealSpace },
+}
+
+// Exponential distributions with parameter (Rate)
+var ExponentialFamily = Family{
+	NumParams: 1,
+	New: func(params []float64, src rand.Source) Univariate {
+		return distuv.Exponential{Rate: params[0], Src: src}
+	},
+	Space: func(params []float64) dist.RealSpace { return dist.PositiveRealSpace },
+}
+
+// Log-normal distributions with parameters (Mu, Sigma)
+var LogNormalFamily = Family{
+	NumParams: 2,
+	New: func(params []float64, src rand.Source) Univariate {
+		return distuv.LogNormal{Mu: params[0], Sigma: params[1], Src: src}
+	},
+	Space: func(params []float64) dist.RealSpace { return dist.PositiveRealSpace },
+}
+
+// Uniform distributions with parameters (Min, Max)
+var UniformFamily = Family{
+	NumParams: 2,
+	New: func(params []float64, src rand.Source) Univariate {
+		return distuv.Uniform{Min: params[0], Max: params[1], Src: src}
+	},
+	Space: func(params []float64) dist.RealSpace {
+		return dist.NewRealIntervalSpace(params[0], params[1])
+	},
+}
+
+// Create a new continuous distribution from a member of a gonum family. The
+// random source may be nil to use the global source.
+func NewContinuous(family Family, params []float64, src rand.Source) *Continuous {
+	if len(params) != family.NumParams {
+		panic(stats.Errorf("Expected %d parameter(s), but got %d",
+			family.NumParams, len(params)))
+	}
+	d := &Continuous{
+		Family: family,
+		Src:    src,
+	}
+	d.SetParams(params)
+	return d
+}
+
+// A dist.ContinuousDist backed by a gonum distribution
+type Continuous struct {
+
+	// The family of the underlying distribution
+	Family Family
+
+	// The current underlying distribution
+	Dist Univariate
+
+	// The source of randomness, or nil for the global source
+	Src rand.Source
+
+	// The current parameters
+	params []float64
+}
+
+// Return the corresponding sample space
+func (d Continuous) Space() dist.RealSpace {
+	return d.Family.Space(d.params)
+}
+
+// Return a "score" (density or probability) for the given values
+func (d Continuous) Score(vars, params []float64) float64 {
+	return d.Family.New(params, nil).Prob(vars[0])
+}
+
+// Return the natural log of Score() for the given values
+func (d Continuous) LogScore(vars, params []float64) float64 {
+	return d.Family.New(params, nil).LogProb(vars[0])
+}
+
+// The number of random variables the distribution is over
+func (d Continuous) NumVars() int {
+	return 1
+}
+
+// The number of parameters in the distribution
+func (d Continuous) NumParams() int {
+	return d.Family.NumParams
+}
+
+// Update the distribution parameters
+func (d *Continuous) SetParams(vals []float64) {
+	d.params = append(d.params[:0], vals...)
+	d.Dist = d.Family.New(d.params, d.Src)
+}
+
+// Return the current distribution parameters
+func (d Continuous) Params() []float64 {
+	return append([]float64(nil), d.params...)
+}
+
+// Return the density at a given value
+func (d Continuous) PDF(val float64) float64 {
+	return d.Dist.Prob(val)
+}
+
+// The value of the CDF: Pr(X <= val) for random variable X over this space
+func (d Continuous) CDF(val float64) float64 {
+	return d.Dist.CDF(val)
+}
+
+// Return the probability of a given interval
+func (d Continuous) Prob(from, to float64) float64 {
+	return d.CDF(to) - d.CDF(from)
+}
+
+// Return the log probability (base 2) of a given interval
+func (d Continuous) LgProb(from, to float64) float64 {
+	return math.Log2(d.Prob(from, to))
+}
+
+// The mean, or expected value, of the random variable
+func (d Continuous) Mean() float64 {
+	return d.Dist.Mean()
+}
+
+// The mode of the random variable, or NaN if the gonum distribution does not
+// provide one
+func (d Continuous) Mode() float64 {
+	if m, ok := d.Dist.(interface {
+		Mode() float64
+	}); ok {
+		return m.Mode()
+	}
+	return math.NaN()
+}
+
+// The variance of the random variable
+func (d Continuous) Variance() float64 {
+	return d.Dist.Variance()
+}
+
+// Sample an outcome from the distribution
+func (d Continuous) Sample() float64 {
+	return d.Dist.Rand()
+}
+
+// Sample a sequence of n outcomes from the distribution
+func (d Continuous) SampleN(n int) []float64 {
+	var outcomes []float64
+	for i := 0; i < n; i++ {
+		outcomes = append(outcomes, d.Dist.Rand())
+	}
+	return outcomes
+}
+
+// Wrap a continuous distribution so it satisfies gonum's distuv.Rander,
+// distuv.LogProber and distuv.Quantiler interfaces
+func NewDistuv(d dist.ContinuousDist) *Distuv {
+	return &Distuv{Dist: d}
+}
+
+// A continuous distribution exposed through gonum's interfaces
+type Distuv struct {
+	Dist dist.ContinuousDist
+}
+
+// Return a random sample drawn from the distribution
+func (d Distuv) Rand() float64 {
+	return d.Dist.Sample()
+}
+
+// Return the natural log of the density at x
+func (d Distuv) LogProb(x float64) float64 {
+	return math.Log(d.Dist.PDF(x))
+}
+
+// Return the density at x
+func (d Distuv) Prob(x float64) float64 {
+	return d.Dist.PDF(x)
+}
+
+// Return the value of the CDF at x
+func (d Distuv) CDF(x float64) float64 {
+	return d.Dist.CDF(x)
+}
+
+// Return the mean of the distribution
+func (d Distuv) Mean() float64 {
+	return d.Dist.Mean()
+}
+
+// Return the variance of the distribution
+func (d Distuv) Variance() float64 {
+	return d.Dist.Variance()
+}
+
+// Return the smallest x with CDF(x) >= p, found by bisection
+func (d Distuv) Quantile(p float64) float64 {
+	if p < 0 || p > 1 {
+		panic(stats.ErrfInvalidProb(p))
+	}
+	var (
+		sp     = d.Dist.Space()
+		lo, hi = sp.Inf(), sp.Sup()
+		step   = math.Max(1, math.Sqrt(d.Dist.Variance()))
+	)
+	if math.IsInf(lo, -1) {
+		for lo = d.Dist.Mean() - step; d.Dist.CDF(lo) > p; step *= 2 {
+			lo -= step
+		}
+	}
+	if math.IsInf(hi, +1) {
+		for hi = d.Dist.Mean() + step; d.Dist.CDF(hi) < p; step *= 2 {
+			hi += step
+		}
+	}
+	for i := 0; i < 200 && hi-lo > 1e-12*math.Max(1, math.Abs(lo)); i++ {
+		mid := lo + (hi-lo)/2
+		if d.Dist.CDF(mid) >= p {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi
+}
+
+// Wrap a discrete distribution over a subset of the reals so it satisfies
+// gonum's distuv.Rander and distuv.LogProber interfaces
+func NewDiscreteDistuv(d dist.DiscreteDist) *DiscreteDistuv {
+	if _, ok := d.Space().(dist.DiscreteRealSpace); !ok {
+		panic(stats.Errorf("Distribution space %T is not a subset of the reals", d.Space()))
+	}
+	return &DiscreteDistuv{Dist: d}
+}
+
+// A discrete distribution exposed through gonum's interfaces
+type DiscreteDistuv struct {
+	Dist dist.DiscreteDist
+}
+
+// Return a random sample drawn from the distribution
+func (d DiscreteDistuv) Rand() float64 {
+	return d.space().F64Value(d.Dist.Sample())
+}
+
+// Return the natural log of the probability mass at x
+func (d DiscreteDistuv) LogProb(x float64) float64 {
+	return math.Log(d.Prob(x))
+}
+
+// Return the probability mass at x, which is zero outside the space
+func (d DiscreteDistuv) Prob(x float64) float64 {
+	var sp = d.space()
+	if !sp.Contains(x) {
+		return 0
+	}
+	return d.Dist.Prob(sp.Outcome(x))
+}
+
+func (d DiscreteDistuv) space() dist.DiscreteRealSpace {
+	return d.Dist.Space().(dist.DiscreteRealSpace)
+}
+
+// Score each row of vars given params, writing the scores to out
+func (d Continuous) ScoreBatch(vars [][]float64, params []float64, out []float64) {
+	var u = d.Family.New(params, nil)
+	for i, row := range vars {
+		out[i] = u.Prob(row[0])
+	}
+}
+
+// Score each row of vars given params, writing the natural log of the scores
+// to out
+func (d Continuous) LogScoreBatch(vars [][]float64, params []float64, out []float64) {
+	var u = d.Family.New(params, nil)
+	for i, row := range vars {
+		out[i] = u.LogProb(row[0])
+	}
+}
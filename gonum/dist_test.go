@@ -0,0 +1,94 @@
+package gonum
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/dist/disttest"
+	. "github.com/smartystreets/goconvey/convey"
+	"gonum.org/v1/gonum/stat/distuv"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestContinuous(t *testing.T) {
+	Convey("Test Continuous interfaces", t, func() {
+		d := NewContinuous(NormalFamily, []float64{0, 1}, nil)
+		So(d, ShouldImplement, (*dist.Dist)(nil))
+		So(d, ShouldImplement, (*dist.ContinuousDist)(nil))
+	})
+
+	Convey("Gonum families conform", t, func() {
+		So(disttest.CheckContinuous(NewContinuous(NormalFamily, []float64{0, 1}, nil),
+			disttest.Options{Params: [][]float64{{0, 1}, {2, 0.5}}}), ShouldBeEmpty)
+		So(disttest.CheckContinuous(NewContinuous(BetaFamily, []float64{1, 1}, nil),
+			disttest.Options{Params: [][]float64{{2, 5}, {0.5, 0.5}}}), ShouldBeEmpty)
+		So(disttest.CheckContinuous(NewContinuous(GammaFamily, []float64{1, 1}, nil),
+			disttest.Options{Params: [][]float64{{2, 3}, {0.5, 1}}}), ShouldBeEmpty)
+		So(disttest.CheckContinuous(NewContinuous(UniformFamily, []float64{0, 1}, nil),
+			disttest.Options{Params: [][]float64{{-1, 3}, {0, 1}}}), ShouldBeEmpty)
+	})
+
+	Convey("Test Continuous matches our distributions", t, func() {
+		var (
+			ours   = dist.NewNormalDist(1, 2)
+			theirs = NewContinuous(NormalFamily, []float64{1, 2}, nil)
+		)
+		for _, x := range []float64{-3, 0, 1, 2.5} {
+			So(theirs.PDF(x), ShouldAlmostEqual, ours.PDF(x))
+			So(theirs.CDF(x), ShouldAlmostEqual, ours.CDF(x))
+			So(theirs.Score([]float64{x}, []float64{0, 1}),
+				ShouldAlmostEqual, ours.Score([]float64{x}, []float64{0, 1}))
+			So(theirs.LogScore([]float64{x}, []float64{0, 1}),
+				ShouldAlmostEqual, math.Log(ours.Score([]float64{x}, []float64{0, 1})))
+		}
+		So(theirs.Mode(), ShouldEqual, 1)
+		So(theirs.Params(), ShouldResemble, []float64{1, 2})
+		So(math.IsNaN(NewContinuous(UniformFamily, []float64{0, 1}, nil).Mode()), ShouldBeTrue)
+		So(func() { NewContinuous(NormalFamily, []float64{0}, nil) }, ShouldPanic)
+	})
+
+	Convey("Test Continuous uses its random source", t, func() {
+		var (
+			d1 = NewContinuous(NormalFamily, []float64{0, 1}, rand.NewPCG(1, 2))
+			d2 = NewContinuous(NormalFamily, []float64{0, 1}, rand.NewPCG(1, 2))
+		)
+		So(d1.SampleN(5), ShouldResemble, d2.SampleN(5))
+	})
+}
+
+func TestDistuv(t *testing.T) {
+	Convey("Test Distuv interfaces", t, func() {
+		d := NewDistuv(dist.NewStandardNormalDist())
+		So(d, ShouldImplement, (*distuv.Rander)(nil))
+		So(d, ShouldImplement, (*distuv.LogProber)(nil))
+		So(d, ShouldImplement, (*distuv.Quantiler)(nil))
+		So(d, ShouldImplement, (*Univariate)(nil))
+	})
+
+	Convey("Test Distuv matches gonum", t, func() {
+		var (
+			ours   = NewDistuv(dist.NewBetaDist(2, 5))
+			theirs = distuv.Beta{Alpha: 2, Beta: 5}
+		)
+		for _, x := range []float64{0.1, 0.3, 0.5, 0.9} {
+			So(ours.LogProb(x), ShouldAlmostEqual, theirs.LogProb(x))
+			So(ours.CDF(x), ShouldAlmostEqual, theirs.CDF(x))
+		}
+		for _, p := range []float64{0.05, 0.5, 0.95} {
+			So(ours.Quantile(p), ShouldAlmostEqual, theirs.Quantile(p), 1e-8)
+		}
+		So(NewDistuv(dist.NewNormalDist(3, 2)).Quantile(0.975),
+			ShouldAlmostEqual, distuv.Normal{Mu: 3, Sigma: 2}.Quantile(0.975), 1e-8)
+		So(func() { ours.Quantile(2) }, ShouldPanic)
+	})
+
+	Convey("Test DiscreteDistuv", t, func() {
+		d := NewDiscreteDistuv(dist.NewBernoulliDist(0.3))
+		So(d, ShouldImplement, (*distuv.RandLogProber)(nil))
+		So(d.Prob(1), ShouldAlmostEqual, 0.3)
+		So(d.LogProb(0), ShouldAlmostEqual, math.Log(0.7))
+		So(d.Prob(0.5), ShouldEqual, 0)
+		So(d.Prob(2), ShouldEqual, 0)
+		So(d.Rand(), ShouldBeIn, 0.0, 1.0)
+	})
+}
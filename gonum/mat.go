@@ -0,0 +1,76 @@
+package gonum
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Copy a sample array into a new gonum vector
+func ToVecDense(vals []float64) *mat.VecDense {
+	if len(vals) == 0 {
+		panic(stats.Errorf("Cannot create an empty vector"))
+	}
+	return mat.NewVecDense(len(vals), append([]float64(nil), vals...))
+}
+
+// Copy a gonum vector into a new sample array
+func FromVector(v mat.Vector) []float64 {
+	var vals = make([]float64, v.Len())
+	for i := range vals {
+		vals[i] = v.AtVec(i)
+	}
+	return vals
+}
+
+// Copy a sequence of outcomes into a new gonum vector of outcome IDs
+func OutcomesToVecDense(outcomes []dist.Outcome) *mat.VecDense {
+	var vals = make([]float64, len(outcomes))
+	for i, o := range outcomes {
+		vals[i] = float64(o)
+	}
+	return ToVecDense(vals)
+}
+
+// Copy a gonum vector of outcome IDs into a new sequence of outcomes
+func OutcomesFromVector(v mat.Vector) []dist.Outcome {
+	var outcomes = make([]dist.Outcome, v.Len())
+	for i := range outcomes {
+		outcomes[i] = dist.Outcome(v.AtVec(i))
+	}
+	return outcomes
+}
+
+// Copy a sequence of sample arrays into a new gonum matrix with one row per
+// array. All arrays must have the same length.
+func ToDense(rows [][]float64) *mat.Dense {
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		panic(stats.Errorf("Cannot create an empty matrix"))
+	}
+	var (
+		cols = len(rows[0])
+		data = make([]float64, 0, len(rows)*cols)
+	)
+	for i, row := range rows {
+		if len(row) != cols {
+			panic(stats.Errorf("Row %d has %d column(s), but expected %d", i, len(row), cols))
+		}
+		data = append(data, row...)
+	}
+	return mat.NewDense(len(rows), cols, data)
+}
+
+// Copy a gonum matrix into a new sequence of sample arrays, one per row
+func FromMatrix(m mat.Matrix) [][]float64 {
+	var (
+		r, c = m.Dims()
+		rows = make([][]float64, r)
+	)
+	for i := range rows {
+		rows[i] = make([]float64, c)
+		for j := range rows[i] {
+			rows[i][j] = m.At(i, j)
+		}
+	}
+	return rows
+}
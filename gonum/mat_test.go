@@ -0,0 +1,87 @@
+package gonum
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/optimize"
+	"testing"
+)
+
+func TestMat(t *testing.T) {
+	Convey("Test vector conversions", t, func() {
+		var (
+			vals = []float64{1, 2, 3}
+			v    = ToVecDense(vals)
+		)
+		vals[0] = 10
+		So(v.AtVec(0), ShouldEqual, 1)
+		So(FromVector(v), ShouldResemble, []float64{1, 2, 3})
+
+		outcomes := []dist.Outcome{0, 1, 1}
+		So(OutcomesFromVector(OutcomesToVecDense(outcomes)), ShouldResemble, outcomes)
+		So(func() { ToVecDense(nil) }, ShouldPanic)
+	})
+
+	Convey("Test matrix conversions", t, func() {
+		var (
+			rows = [][]float64{{1, 2}, {3, 4}, {5, 6}}
+			m    = ToDense(rows)
+		)
+		r, c := m.Dims()
+		So(r, ShouldEqual, 3)
+		So(c, ShouldEqual, 2)
+		So(m.At(2, 1), ShouldEqual, 6)
+		So(FromMatrix(m), ShouldResemble, rows)
+		So(FromMatrix(m.T()), ShouldResemble, [][]float64{{1, 3, 5}, {2, 4, 6}})
+		So(func() { ToDense([][]float64{{1, 2}, {3}}) }, ShouldPanic)
+		So(func() { ToDense(nil) }, ShouldPanic)
+	})
+}
+
+func TestProblem(t *testing.T) {
+	Convey("Given a graph of Normal observations with an unknown mean", t, func() {
+		var (
+			mu    = variable.NewContinuousRV(0, dist.AllRealSpace)
+			sigma = variable.NewContinuousRV(1, dist.PositiveRealSpace)
+			graph = factor.NewFactorGraph()
+		)
+		for _, x := range []float64{1, 2, 3, 6} {
+			graph.AddFactor(factor.NewDistFactor([]variable.RandomVariable{
+				variable.NewContinuousRV(x, dist.AllRealSpace), mu, sigma,
+			}, dist.NewStandardNormalDist()))
+		}
+
+		Convey("Gonum finds the maximum likelihood mean", func() {
+			var (
+				vars    = []*variable.ContinuousRV{mu}
+				problem = Problem(graph, vars)
+			)
+			So(problem.Grad, ShouldNotBeNil)
+			result, err := optimize.Minimize(problem, Variables(vars), nil, &optimize.BFGS{})
+			So(err, ShouldBeNil)
+			SetVariables(vars, result.X)
+			So(mu.Val(), ShouldAlmostEqual, 3, 1e-6)
+			So(FromVector(mat.NewVecDense(1, result.X)), ShouldResemble, Variables(vars))
+		})
+
+		Convey("Factors which cannot differentiate get numerical gradients", func() {
+			graph.AddFactor(factor.NewDistFactor([]variable.RandomVariable{
+				variable.NewContinuousRV(3, dist.AllRealSpace), mu, sigma,
+			}, NewContinuous(NormalFamily, []float64{0, 1}, nil)))
+			var (
+				vars    = []*variable.ContinuousRV{mu}
+				problem = Problem(graph, vars)
+				grad    = make([]float64, 1)
+			)
+			// The sum of x - mu over x in {1, 2, 3, 6, 3} at mu = 1, negated
+			problem.Grad(grad, []float64{1})
+			So(grad[0], ShouldAlmostEqual, -10, 1e-4)
+			result, err := optimize.Minimize(problem, Variables(vars), nil, &optimize.BFGS{})
+			So(err, ShouldBeNil)
+			So(result.X[0], ShouldAlmostEqual, 3, 1e-4)
+		})
+	})
+}
@@ -0,0 +1,56 @@
+package gonum
+
+import (
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"gonum.org/v1/gonum/optimize"
+)
+
+// The step size for finite difference gradients of factors which cannot
+// differentiate
+const gradStep = 1e-6
+
+// Build a gonum optimization problem which minimizes the negative score of a
+// factor graph over the given continuous variables. The variables are set to
+// each point the optimizer evaluates, so callers should set them to the
+// optimum when finished, e.g. with SetVariables(). The gradient is analytic
+// for factors which can differentiate (see factor.CanDifferentiate()), and
+// estimated by finite differences for the rest.
+func Problem(graph *factor.FactorGraph, vars []*variable.ContinuousRV) optimize.Problem {
+	return optimize.Problem{
+		Func: func(x []float64) float64 {
+			SetVariables(vars, x)
+			return -graph.Score()
+		},
+		Grad: func(grad, x []float64) {
+			SetVariables(vars, x)
+			for i, v := range vars {
+				grad[i] = 0
+				for _, f := range graph.AdjToVariable(v) {
+					var d = factor.LogScoreGradOf(f, gradStep)
+					for j, adj := range f.Adjacent() {
+						if adj == variable.RandomVariable(v) {
+							grad[i] -= d[j]
+						}
+					}
+				}
+			}
+		},
+	}
+}
+
+// Return the current values of a sequence of variables
+func Variables(vars []*variable.ContinuousRV) []float64 {
+	var vals = make([]float64, len(vars))
+	for i, v := range vars {
+		vals[i] = v.Val()
+	}
+	return vals
+}
+
+// Set the values of a sequence of variables
+func SetVariables(vars []*variable.ContinuousRV, vals []float64) {
+	for i, v := range vars {
+		v.Set(vals[i])
+	}
+}
@@ -0,0 +1,476 @@
+package graphio
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/channel/bsc"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// The JSON representation of a factor graph. Factors refer to variables by
+// key: the variable's name, or "#" and its position in Variables if it has no
+// name. Keys do not depend on variable IDs, which are only unique within the
+// process that created the variables, so writing the same graph in different
+// processes gives the same keys.
+type jsonGraph struct {
+	Variables []jsonVariable `json:"variables"`
+	Factors   []jsonFactor   `json:"factors"`
+}
+
+type jsonVariable struct {
+	Name     string            `json:"name,omitempty"`
+	ID       int64             `json:"id"`
+	Type     string            `json:"type"`
+	Space    jsonSpace         `json:"space"`
+	Value    jsonFloat         `json:"value"`
+	Observed bool              `json:"observed,omitempty"`
+	Strict   bool              `json:"strict,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+type jsonSpace struct {
+	Type string     `json:"type"`
+	Min  *jsonFloat `json:"min,omitempty"`
+	Max  *jsonFloat `json:"max,omitempty"`
+}
+
+type jsonFactor struct {
+	Type   string    `json:"type"`
+	Vars   []string  `json:"vars"`
+	Dist   *jsonDist `json:"dist,omitempty"`
+	Value  float64   `json:"value,omitempty"`
+	Values []float64 `json:"values,omitempty"`
+}
+
+// A distribution. Factors which shared a distribution when written have the
+// same ID; distributions without an ID are not shared.
+type jsonDist struct {
+	ID     int        `json:"id,omitempty"`
+	Type   string     `json:"type"`
+	Params []float64  `json:"params,omitempty"`
+	Space  *jsonSpace `json:"space,omitempty"`
+}
+
+// A float which is encoded as a string if it is infinite or NaN
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	var val = float64(f)
+	if math.IsInf(val, 0) || math.IsNaN(val) {
+		return json.Marshal(strconv.FormatFloat(val, 'g', -1, 64))
+	}
+	return json.Marshal(val)
+}
+
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		val, err := strconv.ParseFloat(s, 64)
+		*f = jsonFloat(val)
+		return err
+	}
+	var val float64
+	err := json.Unmarshal(data, &val)
+	*f = jsonFloat(val)
+	return err
+}
+
+// Write a factor graph as JSON. Supports DistFactor over the built-in
+// distributions, ConstFactor, TableFactor, BSCFactor and BSCPairFactor. Each
+// variable must have a unique name or be unnamed.
+func WriteJSON(w io.Writer, graph *factor.FactorGraph) error {
+	var (
+		g       jsonGraph
+		keys    = make(map[variable.RandomVariable]string)
+		used    = make(map[string]bool)
+		distIDs = make(map[dist.Dist]int)
+	)
+	for i, v := range graph.Variables {
+		jv, err := encodeVariable(v.Variable)
+		if err != nil {
+			return err
+		}
+		key := jsonKey(i, jv)
+		if used[key] {
+			return stats.Errorf("Duplicate variable name %q", key)
+		}
+		used[key] = true
+		keys[v.Variable] = key
+		g.Variables = append(g.Variables, jv)
+	}
+	for _, f := range graph.Factors {
+		jf, err := encodeFactor(f)
+		if err != nil {
+			return err
+		}
+		if jf.Dist != nil {
+			d := f.(*factor.DistFactor).Dist
+			if distIDs[d] == 0 {
+				distIDs[d] = len(distIDs) + 1
+			}
+			jf.Dist.ID = distIDs[d]
+		}
+		for _, v := range f.Adjacent() {
+			jf.Vars = append(jf.Vars, keys[v])
+		}
+		g.Factors = append(g.Factors, jf)
+	}
+	var enc = json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// Read a factor graph written by WriteJSON(). Variables keep their names,
+// IDs, tags, values and observed state. The IDs are those of the process which
+// wrote the graph, so they may repeat the IDs of variables created by this
+// process. Factors which shared a distribution share one again, so they can
+// be scored in batches.
+func ReadJSON(r io.Reader) (*factor.FactorGraph, error) {
+	var g jsonGraph
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+	var vars = make(map[string]variable.RandomVariable)
+	for i, jv := range g.Variables {
+		var key = jsonKey(i, jv)
+		if _, ok := vars[key]; ok {
+			return nil, stats.Errorf("Duplicate variable key %q", key)
+		}
+		v, err := decodeVariable(key, jv)
+		if err != nil {
+			return nil, err
+		}
+		vars[key] = v
+	}
+	var (
+		graph = factor.NewFactorGraph()
+		dists = make(map[int]dist.Dist)
+	)
+	for _, jf := range g.Factors {
+		f, err := decodeFactor(jf, vars, dists)
+		if err != nil {
+			return nil, err
+		}
+		graph.AddFactor(f)
+	}
+	return graph, nil
+}
+
+// Get the key used to refer to a variable
+func jsonKey(i int, jv jsonVariable) string {
+	if jv.Name != "" {
+		return jv.Name
+	}
+	return fmt.Sprintf("#%d", i)
+}
+
+// Encode a variable
+func encodeVariable(v variable.RandomVariable) (jsonVariable, error) {
+	var jv = jsonVariable{
+		Value:    jsonFloat(v.Val()),
+		Observed: variable.IsObserved(v),
+	}
+	if iv, ok := v.(variable.IdentifiedVariable); ok {
+		jv.Name, jv.ID = iv.Name(), iv.ID()
+	}
+	var (
+		space dist.Space
+		err   error
+	)
+	switch v := v.(type) {
+	case *variable.ContinuousRV:
+		jv.Type, space = "continuous", v.Space()
+		jv.Strict, jv.Tags = v.IsStrict(), v.Tags()
+	case *variable.DiscreteRV:
+		jv.Type, space = "discrete", v.Space()
+		jv.Strict, jv.Tags = v.IsStrict(), v.Tags()
+	default:
+		return jv, stats.Errorf("Unsupported variable type %T", v)
+	}
+	jv.Space, err = encodeSpace(space)
+	return jv, err
+}
+
+// Decode a variable
+func decodeVariable(key string, jv jsonVariable) (variable.RandomVariable, error) {
+	space, err := decodeSpace(jv.Space)
+	if err != nil {
+		return nil, err
+	}
+	var val = float64(jv.Value)
+	switch jv.Type {
+	case "continuous":
+		rs, ok := space.(dist.RealSpace)
+		if !ok {
+			return nil, stats.Errorf("Variable %s needs a real space", key)
+		}
+		v := variable.NewContinuousRV(val, rs)
+		v.SetName(jv.Name)
+		v.SetID(jv.ID)
+		for key, tag := range jv.Tags {
+			v.SetTag(key, tag)
+		}
+		v.SetStrict(jv.Strict)
+		if jv.Observed {
+			if !rs.Contains(val) {
+				return nil, stats.ErrfValNotInDomain(val)
+			}
+			v.Observe(val)
+		}
+		return v, nil
+	case "discrete":
+		ds, ok := space.(dist.DiscreteRealSpace)
+		if !ok {
+			return nil, stats.Errorf("Variable %s needs a discrete space", key)
+		} else if !ds.Contains(val) {
+			return nil, stats.ErrfValNotInDomain(val)
+		}
+		v := variable.NewDiscreteRV(ds.Outcome(val), ds)
+		v.SetName(jv.Name)
+		v.SetID(jv.ID)
+		for key, tag := range jv.Tags {
+			v.SetTag(key, tag)
+		}
+		v.SetStrict(jv.Strict)
+		if jv.Observed {
+			v.Observe(val)
+		}
+		return v, nil
+	}
+	return nil, stats.Errorf("Unsupported variable type %q", jv.Type)
+}
+
+// Encode a space
+func encodeSpace(space dist.Space) (jsonSpace, error) {
+	var bounds = func(typ string, min, max float64) jsonSpace {
+		var lo, hi = jsonFloat(min), jsonFloat(max)
+		return jsonSpace{Type: typ, Min: &lo, Max: &hi}
+	}
+	switch sp := space.(type) {
+	case dist.RealIntervalSpace:
+		return bounds("interval", sp.Min, sp.Max), nil
+	case *dist.RealIntervalSpace:
+		return bounds("interval", sp.Min, sp.Max), nil
+	case dist.IntegerIntervalSpace:
+		return bounds("integers", float64(sp.Min), float64(sp.Max)), nil
+	}
+	if space.Equals(dist.BooleanSpace) {
+		return jsonSpace{Type: "boolean"}, nil
+	} else if space.Equals(dist.PositiveRealSpace) {
+		return jsonSpace{Type: "positive"}, nil
+	}
+	return jsonSpace{}, stats.Errorf("Unsupported space type %T", space)
+}
+
+// Decode a space
+func decodeSpace(js jsonSpace) (dist.Space, error) {
+	switch js.Type {
+	case "boolean":
+		return dist.BooleanSpace, nil
+	case "positive":
+		return dist.PositiveRealSpace, nil
+	case "interval", "integers":
+		if js.Min == nil || js.Max == nil {
+			return nil, stats.Errorf("Space %q needs min and max", js.Type)
+		} else if js.Type == "interval" {
+			return dist.NewRealIntervalSpace(float64(*js.Min), float64(*js.Max)), nil
+		}
+		return dist.NewIntegerIntervalSpace(int(*js.Min), int(*js.Max)), nil
+	}
+	return nil, stats.Errorf("Unsupported space type %q", js.Type)
+}
+
+// Encode a factor, except for its variables
+func encodeFactor(f factor.Factor) (jsonFactor, error) {
+	switch f := f.(type) {
+	case *factor.DistFactor:
+		jd, err := encodeDist(f.Dist)
+		return jsonFactor{Type: "dist", Dist: jd}, err
+	case *factor.ConstFactor:
+		return jsonFactor{Type: "const", Value: f.Value}, nil
+	case *factor.TableFactor:
+		return jsonFactor{Type: "table", Values: f.Values}, nil
+	case *bsc.BSCFactor:
+		return jsonFactor{Type: "bsc"}, nil
+	case *bsc.BSCPairFactor:
+		return jsonFactor{Type: "bscpair"}, nil
+	}
+	return jsonFactor{}, stats.Errorf("Unsupported factor type %T", f)
+}
+
+// Decode a factor, reusing the distribution decoded for the same dist ID
+func decodeFactor(jf jsonFactor, vars map[string]variable.RandomVariable,
+	dists map[int]dist.Dist) (factor.Factor, error) {
+
+	var (
+		adj      []variable.RandomVariable
+		discrete []*variable.DiscreteRV
+	)
+	for _, key := range jf.Vars {
+		v, ok := vars[key]
+		if !ok {
+			return nil, stats.Errorf("Factor refers to unknown variable %q", key)
+		}
+		adj = append(adj, v)
+		if dv, ok := v.(*variable.DiscreteRV); ok {
+			discrete = append(discrete, dv)
+		}
+	}
+	switch jf.Type {
+	case "dist":
+		if jf.Dist == nil {
+			return nil, stats.Errorf("Dist factor has no distribution")
+		}
+		d, ok := dists[jf.Dist.ID]
+		if !ok || jf.Dist.ID == 0 {
+			var err error
+			if d, err = decodeDist(*jf.Dist); err != nil {
+				return nil, err
+			}
+			if jf.Dist.ID != 0 {
+				dists[jf.Dist.ID] = d
+			}
+		} else if jd, _ := encodeDist(d); jd.Type != jf.Dist.Type ||
+			!reflect.DeepEqual(jd.Params, jf.Dist.Params) {
+			return nil, stats.Errorf("Distribution %d is given differently by two factors", jf.Dist.ID)
+		}
+		if len(adj) != d.NumVars()+d.NumParams() {
+			return nil, stats.ErrfFactorVarNum(d.NumVars(), d.NumParams(), len(adj))
+		}
+		return factor.NewDistFactor(adj, d), nil
+	case "const":
+		return factor.NewConstFactor(adj, jf.Value), nil
+	case "table":
+		if len(discrete) != len(adj) {
+			return nil, stats.ErrDiscreteOnly
+		}
+		var size = 1
+		for _, v := range discrete {
+			size *= v.Space().Size()
+		}
+		if size != len(jf.Values) {
+			return nil, stats.ErrfTableSize(size, len(jf.Values))
+		}
+		return factor.NewTableFactor(discrete, jf.Values), nil
+	case "bsc", "bscpair":
+		var numNoise = 1
+		if jf.Type == "bscpair" {
+			numNoise = 2
+		}
+		if len(adj) != 2+numNoise || len(discrete) != 2 {
+			return nil, stats.Errorf("Factor %q has the wrong variables", jf.Type)
+		}
+		var noise []*variable.ContinuousRV
+		for _, v := range adj[2:] {
+			cv, ok := v.(*variable.ContinuousRV)
+			if !ok {
+				return nil, stats.ErrContinuousOnly
+			}
+			noise = append(noise, cv)
+		}
+		if numNoise == 1 {
+			return &bsc.BSCFactor{Output: discrete[0], Input: discrete[1],
+				NoiseRate: noise[0]}, nil
+		}
+		return &bsc.BSCPairFactor{Output: discrete[0], Input: discrete[1],
+			NoiseRate1: noise[0], NoiseRate2: noise[1]}, nil
+	}
+	return nil, stats.Errorf("Unsupported factor type %q", jf.Type)
+}
+
+// Encode a distribution
+func encodeDist(d dist.Dist) (*jsonDist, error) {
+	switch d := d.(type) {
+	case *dist.Normal:
+		return &jsonDist{Type: "normal", Params: []float64{d.Mu, d.Sigma}}, nil
+	case *dist.Beta:
+		return &jsonDist{Type: "beta", Params: []float64{d.Alpha, d.Beta}}, nil
+	case *dist.Gamma:
+		return &jsonDist{Type: "gamma", Params: []float64{d.Shape, d.Scale}}, nil
+	case *dist.BernoulliDist:
+		return &jsonDist{Type: "bernoulli", Params: []float64{d.Prob(1)}}, nil
+	case *dist.DenseMutableDiscreteDist:
+		space, err := encodeSpace(d.Space())
+		if err != nil {
+			return nil, err
+		}
+		var probs = make([]float64, d.NumParams())
+		for i := range probs {
+			probs[i] = d.Prob(dist.Outcome(i))
+		}
+		return &jsonDist{Type: "discrete", Params: probs, Space: &space}, nil
+	}
+	return nil, stats.ErrfUnsupportedDist(d)
+}
+
+// Decode a distribution
+func decodeDist(jd jsonDist) (dist.Dist, error) {
+	var numParams = map[string]int{"normal": 2, "beta": 2, "gamma": 2, "bernoulli": 1}
+	if n, ok := numParams[jd.Type]; ok && len(jd.Params) != n {
+		return nil, stats.Errorf("Distribution %q expected %d parameter(s), but has %d",
+			jd.Type, n, len(jd.Params))
+	}
+	for _, p := range jd.Params {
+		if math.IsNaN(p) || math.IsInf(p, 0) {
+			return nil, stats.Errorf("Distribution %q has a non-finite parameter %v",
+				jd.Type, p)
+		}
+	}
+	switch jd.Type {
+	case "normal":
+		if jd.Params[1] <= 0 {
+			return nil, stats.Errorf("Normal distribution has non-positive sigma %v",
+				jd.Params[1])
+		}
+		return dist.NewNormalDist(jd.Params[0], jd.Params[1]), nil
+	case "beta", "gamma":
+		if jd.Params[0] <= 0 || jd.Params[1] <= 0 {
+			return nil, stats.Errorf("Distribution %q has non-positive parameters %v",
+				jd.Type, jd.Params)
+		} else if jd.Type == "beta" {
+			return dist.NewBetaDist(jd.Params[0], jd.Params[1]), nil
+		}
+		return dist.NewGammaDist(jd.Params[0], jd.Params[1]), nil
+	case "bernoulli":
+		if jd.Params[0] < 0 || jd.Params[0] > 1 {
+			return nil, stats.ErrfInvalidProb(jd.Params[0])
+		}
+		return dist.NewBernoulliDist(jd.Params[0]), nil
+	case "discrete":
+		if jd.Space == nil {
+			return nil, stats.Errorf("Discrete distribution has no space")
+		}
+		space, err := decodeSpace(*jd.Space)
+		if err != nil {
+			return nil, err
+		}
+		ds, ok := space.(dist.DiscreteSpace)
+		if !ok || ds.Size() != len(jd.Params) {
+			return nil, stats.Errorf("Discrete distribution has the wrong space")
+		}
+		var total float64
+		for _, p := range jd.Params {
+			if p < 0 {
+				return nil, stats.ErrfInvalidProb(p)
+			}
+			total += p
+		}
+		if total == 0 || math.IsInf(total, 0) {
+			return nil, stats.ErrZeroProb
+		}
+		d := dist.NewDenseMutableDiscreteDist(ds)
+		for i, p := range jd.Params {
+			d.SetWeight(dist.Outcome(i), p/total)
+		}
+		d.Normalize()
+		return d, nil
+	}
+	return nil, stats.Errorf("Unsupported distribution type %q", jd.Type)
+}
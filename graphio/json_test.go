@@ -0,0 +1,150 @@
+package graphio
+
+import (
+	"bytes"
+	"github.com/jesand/stats/channel/bsc"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	Convey("Given a graph with each supported factor type", t, func() {
+		var (
+			graph = factor.NewFactorGraph()
+			bias  = variable.NewContinuousRV(0.3, dist.UnitIntervalSpace)
+			alpha = variable.NewContinuousRV(2, dist.PositiveRealSpace)
+			beta  = variable.NewContinuousRV(3, dist.PositiveRealSpace)
+			mu    = variable.NewContinuousRV(0.5, dist.AllRealSpace)
+			level = variable.NewDiscreteRV(2, dist.NewIntegerIntervalSpace(0, 3))
+			ch    = bsc.NewBSC(0.2)
+			pair  = bsc.NewBSCPair(0.1, 0.4)
+			bern  = dist.NewBernoulliDist(0.5)
+			input = variable.NewDiscreteRV(1, dist.BooleanSpace)
+		)
+		bias.SetName("bias")
+		bias.SetTag("kind", "parameter")
+		input.SetName("input")
+		ch.NoiseRate.SetName("noise")
+		for i := 0; i < 3; i++ {
+			x := variable.NewDiscreteRV(dist.Outcome(i%2), dist.BooleanSpace)
+			graph.AddFactor(factor.NewDistFactor([]variable.RandomVariable{x, bias}, bern))
+		}
+		graph.AddFactor(factor.NewDistFactor([]variable.RandomVariable{bias, alpha, beta}, dist.NewBetaDist(1, 1)))
+		graph.AddFactor(factor.NewDistFactor([]variable.RandomVariable{mu, alpha, beta}, dist.NewNormalDist(0, 1)))
+		graph.AddFactor(factor.NewConstFactor([]variable.RandomVariable{mu}, 0.5))
+		graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{level, input},
+			[]float64{1, 2, 3, 4, 5, 6, 7, 8}))
+		output := variable.NewDiscreteRV(0, dist.BooleanSpace)
+		output.ObserveOutcome(0)
+		graph.AddFactor(ch.Factor(input, output))
+		graph.AddFactor(pair.Factor(input, variable.NewDiscreteRV(1, dist.BooleanSpace)))
+
+		Convey("It round-trips through JSON", func() {
+			var buf bytes.Buffer
+			So(WriteJSON(&buf, graph), ShouldBeNil)
+			g2, err := ReadJSON(&buf)
+			So(err, ShouldBeNil)
+			So(g2.Factors, ShouldHaveLength, len(graph.Factors))
+			So(g2.Variables, ShouldHaveLength, len(graph.Variables))
+			So(g2.Score(), ShouldAlmostEqual, graph.Score())
+
+			v, ok := g2.VariableByName("bias")
+			So(ok, ShouldBeTrue)
+			So(v.Val(), ShouldEqual, 0.3)
+			tag, _ := v.(*variable.ContinuousRV).Tag("kind")
+			So(tag, ShouldEqual, "parameter")
+			_, ok = g2.VariableByID(level.ID())
+			So(ok, ShouldBeTrue)
+
+			So(g2.Factors[0].(*factor.DistFactor).Dist, ShouldEqual, g2.Factors[2].(*factor.DistFactor).Dist)
+			So(g2.Factors[3].(*factor.DistFactor).Dist, ShouldNotEqual, g2.Factors[4].(*factor.DistFactor).Dist)
+			bf := g2.Factors[7].(*bsc.BSCFactor)
+			So(bf.Output.IsObserved(), ShouldBeTrue)
+			So(bf.NoiseRate.Name(), ShouldEqual, "noise")
+			So(bf.Input, ShouldEqual, g2.Factors[8].(*bsc.BSCPairFactor).Input)
+		})
+
+		Convey("Infinite bounds are preserved", func() {
+			var buf bytes.Buffer
+			So(WriteJSON(&buf, graph), ShouldBeNil)
+			So(buf.String(), ShouldContainSubstring, `"-Inf"`)
+			g2, _ := ReadJSON(&buf)
+			v, _ := g2.VariableByID(mu.ID())
+			So(math.IsInf(v.(*variable.ContinuousRV).Space().Inf(), -1), ShouldBeTrue)
+		})
+
+		Convey("Unnamed variables are keyed by position, not ID", func() {
+			var buf bytes.Buffer
+			So(WriteJSON(&buf, graph), ShouldBeNil)
+			So(buf.String(), ShouldContainSubstring, `"#0"`)
+			So(buf.String(), ShouldNotContainSubstring, `"#`+strconv.FormatInt(mu.ID(), 10)+`"`)
+		})
+
+		Convey("Only distributions which were shared are shared again", func() {
+			var (
+				buf bytes.Buffer
+				x   = variable.NewDiscreteRV(1, dist.BooleanSpace)
+			)
+			graph.AddFactor(factor.NewDistFactor([]variable.RandomVariable{x, bias},
+				dist.NewBernoulliDist(0.5)))
+			So(WriteJSON(&buf, graph), ShouldBeNil)
+			g2, err := ReadJSON(&buf)
+			So(err, ShouldBeNil)
+			last := g2.Factors[len(g2.Factors)-1].(*factor.DistFactor)
+			So(last.Dist, ShouldNotEqual, g2.Factors[0].(*factor.DistFactor).Dist)
+		})
+
+		Convey("Duplicate names are rejected", func() {
+			alpha.SetName("bias")
+			So(WriteJSON(&bytes.Buffer{}, graph), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Invalid JSON graphs are rejected", t, func() {
+		for _, doc := range []string{
+			`{"factors": [{"type": "const", "vars": ["x"]}]}`,
+			`{"variables": [{"name": "x", "type": "discrete", "space": {"type": "boolean"}, "value": 2}]}`,
+			`{"variables": [{"name": "x", "type": "discrete", "space": {"type": "boolean"}, "value": 1}],
+			  "factors": [{"type": "table", "vars": ["x"], "values": [1]}]}`,
+			`{"variables": [{"name": "x", "type": "discrete", "space": {"type": "boolean"}, "value": 1}],
+			  "factors": [{"type": "dist", "vars": ["x"], "dist": {"type": "bernoulli", "params": [0.5]}}]}`,
+			`{"variables": [{"name": "x", "type": "discrete", "space": {"type": "boolean"}, "value": 1},
+			               {"name": "p", "type": "continuous", "space": {"type": "positive"}, "value": 0.5}],
+			  "factors": [{"type": "dist", "vars": ["x", "p"], "dist": {"id": 1, "type": "bernoulli", "params": [0.5]}},
+			              {"type": "dist", "vars": ["x", "p"], "dist": {"id": 1, "type": "bernoulli", "params": [0.2]}}]}`,
+			`{"variables": [{"name": "#1", "type": "discrete", "space": {"type": "boolean"}, "value": 1},
+			               {"type": "discrete", "space": {"type": "boolean"}, "value": 0}]}`,
+		} {
+			_, err := ReadJSON(strings.NewReader(doc))
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Invalid distribution parameters are rejected", t, func() {
+		var boolean = &jsonSpace{Type: "boolean"}
+		for _, jd := range []jsonDist{
+			{Type: "normal", Params: []float64{0, 0}},
+			{Type: "normal", Params: []float64{math.NaN(), 1}},
+			{Type: "beta", Params: []float64{-1, 1}},
+			{Type: "gamma", Params: []float64{1, math.Inf(1)}},
+			{Type: "gamma", Params: []float64{1, 0}},
+			{Type: "bernoulli", Params: []float64{1.5}},
+			{Type: "discrete", Params: []float64{0, 0}, Space: boolean},
+			{Type: "discrete", Params: []float64{-1, 2}, Space: boolean},
+			{Type: "discrete", Params: []float64{math.MaxFloat64, math.MaxFloat64}, Space: boolean},
+		} {
+			_, err := decodeDist(jd)
+			So(err, ShouldNotBeNil)
+		}
+
+		d, err := decodeDist(jsonDist{Type: "discrete", Params: []float64{1, 3}, Space: boolean})
+		So(err, ShouldBeNil)
+		So(d.(dist.DiscreteDist).Prob(1), ShouldAlmostEqual, 0.75)
+	})
+}
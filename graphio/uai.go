@@ -0,0 +1,306 @@
+// Package graphio reads and writes factor graphs in the UAI competition
+// format and in a JSON format.
+package graphio
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"io"
+	"strconv"
+)
+
+// The network types of the UAI format
+const (
+	UAIMarkov = "MARKOV"
+	UAIBayes  = "BAYES"
+)
+
+// A network read from a UAI file. For a Bayesian network, the last variable
+// of each factor is the child, and the table holds its conditional
+// distribution given the other variables.
+type UAIModel struct {
+	Type    string
+	Vars    []*variable.DiscreteRV
+	Factors []*factor.TableFactor
+}
+
+// Build a factor graph from the model's factors
+func (model UAIModel) Graph() *factor.FactorGraph {
+	var graph = factor.NewFactorGraph()
+	for _, f := range model.Factors {
+		graph.AddFactor(f)
+	}
+	return graph
+}
+
+// Read a network in the UAI format. Variables with two outcomes are given
+// BooleanSpace, and others an IntegerIntervalSpace starting at zero.
+func ReadUAI(r io.Reader) (*UAIModel, error) {
+	var (
+		tokens = newTokenReader(r)
+		model  = &UAIModel{Type: tokens.word()}
+	)
+	if model.Type != UAIMarkov && model.Type != UAIBayes {
+		return nil, stats.Errorf("Unsupported UAI network type %q", model.Type)
+	}
+	var numVars = tokens.count()
+	for i := 0; i < numVars && tokens.err == nil; i++ {
+		model.Vars = append(model.Vars, variable.NewDiscreteRV(0, uaiSpace(tokens.count())))
+	}
+	// The counts are not trusted, so slices grow only as tokens are read
+	var (
+		numFactors = tokens.count()
+		scopes     [][]*variable.DiscreteRV
+	)
+	for i := 0; i < numFactors && tokens.err == nil; i++ {
+		var (
+			size  = tokens.count()
+			scope []*variable.DiscreteRV
+		)
+		for j := 0; j < size && tokens.err == nil; j++ {
+			idx := tokens.count()
+			if idx >= numVars {
+				return nil, stats.Errorf("UAI factor %d refers to variable %d of %d",
+					i, idx, numVars)
+			}
+			scope = append(scope, model.Vars[idx])
+		}
+		scopes = append(scopes, scope)
+	}
+	for i, scope := range scopes {
+		var size = tokens.count()
+		if tokens.err != nil {
+			break
+		} else if want, ok := uaiTableSize(scope, size); !ok {
+			return nil, stats.Errorf("UAI factor %d expected %d value(s), but has %d",
+				i, want, size)
+		}
+		var values []float64
+		for j := 0; j < size && tokens.err == nil; j++ {
+			values = append(values, tokens.float())
+		}
+		if tokens.err != nil {
+			break
+		}
+		model.Factors = append(model.Factors, factor.NewTableFactor(scope, values))
+	}
+	if tokens.err != nil {
+		return nil, tokens.err
+	}
+	return model, nil
+}
+
+// Read a UAI evidence file, observing the given variables. Both the current
+// format, a single line with the number of observed variables followed by
+// index and value pairs, and the older format, which is prefixed with a
+// number of samples, are accepted. Only the first sample is used.
+func ReadUAIEvidence(r io.Reader, vars []*variable.DiscreteRV) error {
+	var (
+		tokens []int
+		tr     = newTokenReader(r)
+	)
+	for tr.scanner.Scan() {
+		n, err := strconv.Atoi(tr.scanner.Text())
+		if err != nil {
+			return stats.Errorf("Invalid UAI evidence token %q", tr.scanner.Text())
+		}
+		tokens = append(tokens, n)
+	}
+	if len(tokens) == 0 {
+		return nil
+	} else if len(tokens) != 1+2*tokens[0] {
+		// Old format: skip the number of samples
+		tokens = tokens[1:]
+		if len(tokens) == 0 || len(tokens) < 1+2*tokens[0] {
+			return stats.Errorf("Truncated UAI evidence")
+		}
+	}
+	for i := 0; i < tokens[0]; i++ {
+		idx, val := tokens[1+2*i], tokens[2+2*i]
+		if idx < 0 || idx >= len(vars) {
+			return stats.Errorf("UAI evidence refers to variable %d of %d", idx, len(vars))
+		} else if val < 0 || val >= vars[idx].Space().Size() {
+			return stats.ErrfNotInDomain(val)
+		}
+		vars[idx].ObserveOutcome(dist.Outcome(val))
+	}
+	return nil
+}
+
+// Get the variables written by WriteUAI(), in file order: the graph's
+// discrete variables, in the order of graph.Variables
+func UAIVariables(graph *factor.FactorGraph) []*variable.DiscreteRV {
+	var vars []*variable.DiscreteRV
+	for _, v := range graph.Variables {
+		if dv, ok := v.Variable.(*variable.DiscreteRV); ok {
+			vars = append(vars, dv)
+		}
+	}
+	return vars
+}
+
+// Write a factor graph as a UAI Markov network. Each factor is converted to a
+// table over its discrete variables, which must have finite spaces;
+// continuous variables are held at their current values. Observed variables
+// are included in the tables, and can be written with WriteUAIEvidence().
+func WriteUAI(w io.Writer, graph *factor.FactorGraph) error {
+	var (
+		vars   = UAIVariables(graph)
+		varIdx = make(map[*variable.DiscreteRV]int)
+		tables []*factor.TableFactor
+	)
+	for i, v := range vars {
+		if v.Space().Size() < 0 {
+			return stats.ErrfInfiniteSpace(v)
+		}
+		varIdx[v] = i
+	}
+	for _, f := range graph.Factors {
+		tables = append(tables, uaiTable(f))
+	}
+
+	var bw = bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s\n%d\n", UAIMarkov, len(vars))
+	for i, v := range vars {
+		if i > 0 {
+			bw.WriteString(" ")
+		}
+		fmt.Fprint(bw, v.Space().Size())
+	}
+	fmt.Fprintf(bw, "\n%d\n", len(tables))
+	for _, t := range tables {
+		fmt.Fprint(bw, len(t.Vars))
+		for _, v := range t.Vars {
+			fmt.Fprintf(bw, " %d", varIdx[v])
+		}
+		bw.WriteString("\n")
+	}
+	for _, t := range tables {
+		fmt.Fprintf(bw, "\n%d\n", len(t.Values))
+		for i, val := range t.Values {
+			if i > 0 {
+				bw.WriteString(" ")
+			}
+			bw.WriteString(strconv.FormatFloat(val, 'g', -1, 64))
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// Write the observed discrete variables of a factor graph as a UAI evidence
+// file, indexing variables as WriteUAI() does
+func WriteUAIEvidence(w io.Writer, graph *factor.FactorGraph) error {
+	var evidence []string
+	for i, v := range UAIVariables(graph) {
+		if v.IsObserved() {
+			evidence = append(evidence, fmt.Sprintf("%d %d", i, v.Outcome()))
+		}
+	}
+	var bw = bufio.NewWriter(w)
+	fmt.Fprint(bw, len(evidence))
+	for _, e := range evidence {
+		fmt.Fprintf(bw, " %s", e)
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// Convert a factor to a table over all of its discrete variables. Observed
+// variables are released while the table is built, and then observed again.
+func uaiTable(f factor.Factor) *factor.TableFactor {
+	var observed []*variable.DiscreteRV
+	for _, v := range f.Adjacent() {
+		if dv, ok := v.(*variable.DiscreteRV); ok && dv.IsObserved() {
+			observed = append(observed, dv)
+			dv.Release()
+		}
+	}
+	var table = factor.NewTableFactorFrom(f)
+	for _, v := range observed {
+		v.ObserveOutcome(v.Outcome())
+	}
+	return table
+}
+
+// Get the number of values in a table over the given variables, and whether
+// it matches the size given in the file. The product stops growing once it
+// exceeds the given size, so it cannot overflow.
+func uaiTableSize(scope []*variable.DiscreteRV, size int) (int, bool) {
+	var want = 1
+	for _, v := range scope {
+		if want > size {
+			break
+		}
+		want *= v.Space().Size()
+	}
+	return want, want == size
+}
+
+// Get the space for a UAI variable with the given cardinality
+func uaiSpace(size int) dist.DiscreteRealSpace {
+	if size == 2 {
+		return dist.BooleanSpace
+	}
+	return dist.NewIntegerIntervalSpace(0, size-1)
+}
+
+// Reads whitespace-separated tokens, remembering the first error
+type tokenReader struct {
+	scanner *bufio.Scanner
+	err     error
+}
+
+// Create a new token reader
+func newTokenReader(r io.Reader) *tokenReader {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	scanner.Split(bufio.ScanWords)
+	return &tokenReader{scanner: scanner}
+}
+
+// Read the next token
+func (tr *tokenReader) word() string {
+	if tr.err != nil {
+		return ""
+	} else if !tr.scanner.Scan() {
+		tr.err = tr.scanner.Err()
+		if tr.err == nil {
+			tr.err = io.ErrUnexpectedEOF
+		}
+		return ""
+	}
+	return tr.scanner.Text()
+}
+
+// Read the next token as a non-negative integer
+func (tr *tokenReader) count() int {
+	var word = tr.word()
+	if tr.err != nil {
+		return 0
+	}
+	n, err := strconv.Atoi(word)
+	if err != nil || n < 0 {
+		tr.err = stats.Errorf("Expected a non-negative integer, but found %q", word)
+		return 0
+	}
+	return n
+}
+
+// Read the next token as a float
+func (tr *tokenReader) float() float64 {
+	var word = tr.word()
+	if tr.err != nil {
+		return 0
+	}
+	val, err := strconv.ParseFloat(word, 64)
+	if err != nil {
+		tr.err = stats.Errorf("Expected a number, but found %q", word)
+		return 0
+	}
+	return val
+}
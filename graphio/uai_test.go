@@ -0,0 +1,110 @@
+package graphio
+
+import (
+	"bytes"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+const sampleUAI = `MARKOV
+3
+2 2 3
+3
+1 0
+2 0 1
+2 1 2
+
+2
+ 0.436 0.564
+
+4
+ 0.128 0.872
+ 0.920 0.080
+
+6
+ 0.210 0.333 0.457
+ 0.811 0.000 0.189
+`
+
+func TestUAI(t *testing.T) {
+	Convey("Test reading a UAI network", t, func() {
+		model, err := ReadUAI(strings.NewReader(sampleUAI))
+		So(err, ShouldBeNil)
+		So(model.Type, ShouldEqual, UAIMarkov)
+		So(model.Vars, ShouldHaveLength, 3)
+		So(model.Vars[2].Space().Size(), ShouldEqual, 3)
+		So(model.Factors, ShouldHaveLength, 3)
+		So(model.Factors[1].Vars, ShouldResemble, []*variable.DiscreteRV{model.Vars[0], model.Vars[1]})
+		So(model.Factors[1].Value(1, 0), ShouldEqual, 0.920)
+		So(model.Factors[2].Value(1, 2), ShouldEqual, 0.189)
+		So(model.Graph().Factors, ShouldHaveLength, 3)
+	})
+
+	Convey("Test reading invalid UAI networks", t, func() {
+		_, err := ReadUAI(strings.NewReader("MARKOV\n2\n2 2\n1\n1 5\n"))
+		So(err, ShouldNotBeNil)
+		_, err = ReadUAI(strings.NewReader("MARKOV\n1\n2\n1\n1 0\n3\n0.1 0.2 0.3\n"))
+		So(err, ShouldNotBeNil)
+		_, err = ReadUAI(strings.NewReader("MARKOV\n1\n2\n1\n1 0\n2\n0.1\n"))
+		So(err, ShouldNotBeNil)
+		_, err = ReadUAI(strings.NewReader("GRID\n"))
+		So(err, ShouldNotBeNil)
+
+		// Huge counts fail on the missing tokens, without allocating for them
+		_, err = ReadUAI(strings.NewReader("MARKOV\n1\n2\n1000000000000\n1 0\n"))
+		So(err, ShouldNotBeNil)
+		_, err = ReadUAI(strings.NewReader("MARKOV\n1\n2\n1\n1 0\n1000000000000\n0.1 0.2\n"))
+		So(err, ShouldNotBeNil)
+		_, err = ReadUAI(strings.NewReader(
+			"MARKOV\n3\n4294967296 4294967296 4294967296\n1\n3 0 1 2\n0\n"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Test reading UAI evidence", t, func() {
+		model, _ := ReadUAI(strings.NewReader(sampleUAI))
+		So(ReadUAIEvidence(strings.NewReader("2 0 1 2 2\n"), model.Vars), ShouldBeNil)
+		So(model.Vars[0].IsObserved(), ShouldBeTrue)
+		So(model.Vars[0].Outcome(), ShouldEqual, 1)
+		So(model.Vars[1].IsObserved(), ShouldBeFalse)
+		So(model.Vars[2].Outcome(), ShouldEqual, 2)
+
+		model, _ = ReadUAI(strings.NewReader(sampleUAI))
+		So(ReadUAIEvidence(strings.NewReader("1\n1 1 0\n"), model.Vars), ShouldBeNil)
+		So(model.Vars[1].IsObserved(), ShouldBeTrue)
+		So(model.Vars[1].Outcome(), ShouldEqual, 0)
+
+		So(ReadUAIEvidence(strings.NewReader("1 0 5"), model.Vars), ShouldNotBeNil)
+		So(ReadUAIEvidence(strings.NewReader("1 7 0"), model.Vars), ShouldNotBeNil)
+	})
+
+	Convey("Test writing a factor graph as UAI", t, func() {
+		var (
+			x     = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			y     = variable.NewDiscreteRV(1, dist.BooleanSpace)
+			bias  = variable.NewContinuousRV(0.25, dist.UnitIntervalSpace)
+			graph = factor.NewFactorGraph()
+			buf   bytes.Buffer
+		)
+		graph.AddFactor(factor.NewDistFactor([]variable.RandomVariable{x, bias}, dist.NewBernoulliDist(0.5)))
+		graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{x, y}, []float64{1, 2, 3, 4}))
+		y.ObserveOutcome(1)
+
+		So(WriteUAI(&buf, graph), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "MARKOV\n2\n2 2\n2\n1 0\n2 0 1\n\n2\n0.75 0.25\n\n4\n1 2 3 4\n")
+		So(y.IsObserved(), ShouldBeTrue)
+
+		model, err := ReadUAI(&buf)
+		So(err, ShouldBeNil)
+		So(model.Factors[1].Values, ShouldResemble, []float64{1, 2, 3, 4})
+
+		buf.Reset()
+		So(WriteUAIEvidence(&buf, graph), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "1 1 1\n")
+		So(ReadUAIEvidence(&buf, model.Vars), ShouldBeNil)
+		So(model.Vars[1].IsObserved(), ShouldBeTrue)
+	})
+}
@@ -2,77 +2,167 @@ package bp
 
 import (
 	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
 	"github.com/jesand/stats/factor"
 	"github.com/jesand/stats/variable"
+	"math"
 	"sync"
 )
 
+// The marginal distribution of each discrete variable over its outcomes.
+// Observed variables have all their mass on the observed outcome.
+type Marginals map[*variable.DiscreteRV][]float64
+
+// Add an indicator of the observed outcome of each observed discrete variable
+func (m Marginals) addEvidence(graph factor.FactorGraph) {
+	for _, v := range graph.ObservedVariables() {
+		if dv, ok := v.(*variable.DiscreteRV); ok && dv.Space().Size() >= 0 {
+			var belief = make([]float64, dv.Space().Size())
+			belief[dv.Outcome()] = 1
+			m[dv] = belief
+		}
+	}
+}
+
+// The result of belief propagation
+type Result struct {
+
+	// The marginal distribution of each discrete variable
+	Marginals Marginals
+
+	// The natural log of the partition function: the sum of the product of
+	// all factors over every joint outcome of the latent variables
+	LogZ float64
+}
+
 // Perform exact belief propogation on a factor graph with a tree structure.
 // This works by iteratively passing messages from nodes with at most one
 // pending in-message to all adjacent nodes. If all possible messages are
 // passed, the exact marginals over the factor graph will have been calculated.
 // If any messages cannot be passed due to the graph structure, the method
-// will fail with an error.
-func InferForTree(graph factor.FactorGraph) error {
+// will fail with ErrGraphNotTree. Observed variables are treated as
+// constants, so cycles through them are allowed. All latent variables must
+// be discrete.
+func InferForTree(graph factor.FactorGraph) (*Result, error) {
+	tree, err := buildBPTree(graph, 1, true)
+	if err != nil {
+		return nil, err
+	} else if err = tree.passMessages(sumProduct); err != nil {
+		return nil, err
+	}
+	return tree.result(graph)
+}
 
-	// Create the BP tree structure
-	tree := buildBPTree(graph, 1)
+// Pass every message once, in an order where each message is sent once all
+// the messages it depends on have arrived, using a pool of workers. Returns
+// ErrGraphNotTree if some messages cannot be sent because of a cycle.
+func (tree bpGraph) passMessages(mode messageMode) error {
 
 	// Prepare the message processing pipeline
 	const mux = 10
 	var (
-		msgC  = make(chan *bpNode, mux)
-		doneC = make(chan *bpNode, mux)
+		msgC  = make(chan *bpTask, mux)
+		doneC = make(chan *bpTask, mux)
 		wait  sync.WaitGroup
 	)
 	for i := 0; i < mux; i++ {
 		wait.Add(1)
 		go func() {
 			defer wait.Done()
-			for node := range msgC {
-
-				doneC <- node
+			for task := range msgC {
+				for _, out := range task.Out {
+					task.Node.send(out, mode)
+				}
+				doneC <- task
 			}
 		}()
 	}
-	wait.Add(1)
-	go func() {
-		defer wait.Done()
-		// for node := range doneC {
 
-		// }
-	}()
-
-	// Build the queue of leaf nodes
-	var queue []*bpNode
+	// Build the queue of nodes with messages ready to send
+	var queue []*bpTask
 	for _, node := range tree {
-		if len(node.In) <= 1 {
-			queue = append(queue, node)
+		if task := node.ready(); task != nil {
+			queue = append(queue, task)
 		}
 	}
-	if len(queue) == 0 {
+	if len(queue) == 0 && tree.numMessages() > 0 {
+		close(msgC)
+		wait.Wait()
 		return stats.ErrGraphNotTree
 	}
 
 	// Move down the queue until all messages have been sent
-	// for len(queue) > 0 {
+	for inFlight := 0; len(queue) > 0 || inFlight > 0; {
+		var (
+			sendC chan *bpTask
+			next  *bpTask
+		)
+		if len(queue) > 0 {
+			sendC, next = msgC, queue[0]
+		}
+		select {
+		case sendC <- next:
+			queue = queue[1:]
+			inFlight++
+		case task := <-doneC:
+			inFlight--
+			for _, out := range task.Out {
+				msg := task.Node.Out[out]
+				msg.Iter++
+				if t := msg.To.ready(); t != nil {
+					queue = append(queue, t)
+				}
+			}
+		}
+	}
+	close(msgC)
+	wait.Wait()
 
-	// }
+	for _, node := range tree {
+		for _, msg := range node.Out {
+			if msg.Iter == 0 {
+				return stats.ErrGraphNotTree
+			}
+		}
+	}
 	return nil
 }
 
-func buildBPTree(graph factor.FactorGraph, initialMessage float64) (tree bpGraph) {
+// Build the message-passing structure for a factor graph. Observed variables
+// are fixed, so they need no nodes or messages. Each factor is converted to
+// a table over its latent variables, unless it is a constraint which can
+// compute its own sum-product messages and constraints is true.
+func buildBPTree(graph factor.FactorGraph, initialMessage float64,
+	constraints bool) (tree bpGraph, err error) {
+
 	var (
 		vNodes = make(map[*variable.DiscreteRV]*bpNode)
 	)
 	for _, f := range graph.Factors {
 		fNode := &bpNode{Factor: f}
 		tree = append(tree, fNode)
+
+		var vars []*variable.DiscreteRV
 		for _, v := range graph.AdjToFactor(f) {
+			if variable.IsObserved(v) {
+				continue
+			}
 			dv, ok := v.(*variable.DiscreteRV)
 			if !ok {
-				panic(stats.ErrDiscreteOnly)
+				return nil, stats.ErrDiscreteOnly
+			} else if dv.Space().Size() < 0 {
+				return nil, stats.ErrfInfiniteSpace(dv)
 			}
+			vars = append(vars, dv)
+		}
+		if c, ok := f.(factor.Constraint); ok && constraints && len(uniqueVars(vars)) == len(vars) {
+			fNode.Constraint = c
+		} else {
+			fNode.Table = factor.NewTableFactorFrom(f)
+			vars = fNode.Table.Vars
+		}
+
+		for _, dv := range vars {
 			vNode, ok := vNodes[dv]
 			if !ok {
 				vNode = &bpNode{Variable: dv}
@@ -97,24 +187,316 @@ func buildBPTree(graph factor.FactorGraph, initialMessage float64) (tree bpGraph
 	return
 }
 
+// Remove repeated variables from a list
+func uniqueVars(vars []*variable.DiscreteRV) []*variable.DiscreteRV {
+	var (
+		seen   = make(map[*variable.DiscreteRV]bool)
+		unique []*variable.DiscreteRV
+	)
+	for _, v := range vars {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
 type bpGraph []*bpNode
 
+// Get the total number of messages in the graph
+func (tree bpGraph) numMessages() int {
+	var count int
+	for _, node := range tree {
+		count += len(node.Out)
+	}
+	return count
+}
+
+// Compute the marginals and log partition function once all messages have
+// been passed
+func (tree bpGraph) result(graph factor.FactorGraph) (*Result, error) {
+	marginals, err := tree.marginals(graph)
+	if err != nil {
+		return nil, err
+	}
+	var result = &Result{Marginals: marginals}
+
+	// Each tree contributes the normalizer of the belief at any one of its
+	// variables. Factors without latent variables contribute their scores.
+	var visited = make(map[*bpNode]bool)
+	for _, node := range tree {
+		if visited[node] {
+			continue
+		}
+		if node.Factor != nil && len(node.Out) == 0 {
+			visited[node] = true
+			result.LogZ += math.Log(node.Factor.Score())
+			continue
+		} else if node.Variable == nil {
+			continue
+		}
+		_, logScale := node.belief()
+		result.LogZ += logScale
+		var stack = []*bpNode{node}
+		for len(stack) > 0 {
+			var n = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !visited[n] {
+				visited[n] = true
+				for _, msg := range n.Out {
+					stack = append(stack, msg.To)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
 type bpNode struct {
 	Variable *variable.DiscreteRV
 	Factor   factor.Factor
 	Out      []*bpMessage
 	In       []*bpMessage
+
+	// For factor nodes, either a table over the latent variables, in the
+	// order of In and Out, or a constraint which computes its own messages
+	Table      *factor.TableFactor
+	Constraint factor.Constraint
+}
+
+// A set of messages for a worker to send from a node, by index into Out
+type bpTask struct {
+	Node *bpNode
+	Out  []int
+}
+
+// Get the unsent messages which the node can send now, or nil. A message can
+// be sent once every other in-message has arrived. Must only be called by
+// the goroutine scheduling messages.
+func (node *bpNode) ready() *bpTask {
+	var missing = -1
+	for i, msg := range node.In {
+		if msg.Iter == 0 {
+			if missing >= 0 {
+				return nil
+			}
+			missing = i
+		}
+	}
+	var task = &bpTask{Node: node}
+	for i, msg := range node.Out {
+		if msg.Iter == 0 && !msg.queued && (missing < 0 || missing == i) {
+			msg.queued = true
+			task.Out = append(task.Out, i)
+		}
+	}
+	if len(task.Out) == 0 {
+		return nil
+	}
+	return task
+}
+
+// The kind of messages to pass
+type messageMode int
+
+const (
+	// Sum-product messages, for marginals
+	sumProduct messageMode = iota
+
+	// Max-product messages, for max-marginals
+	maxProduct
+
+	// Max-sum messages, which are max-product messages in log space
+	maxSum
+)
+
+// Compute and send the message at an index into Out
+func (node *bpNode) send(out int, mode messageMode) {
+	var msg = node.Out[out]
+	msg.LogScale = node.compute(out, msg.Value, mode)
+}
+
+// Compute the message at an index into Out, writing its normalized value.
+// Returns the log of its normalizer, including the scale of the in-messages.
+// Max-sum messages are normalized to have a maximum of zero. Constraints
+// only compute sum-product messages.
+func (node *bpNode) compute(out int, value []float64, mode messageMode) float64 {
+	var logScale float64
+	for i, in := range node.In {
+		if i != out {
+			logScale += in.LogScale
+		}
+	}
+	if node.Variable != nil {
+		for x := range value {
+			if mode == maxSum {
+				value[x] = 0
+			} else {
+				value[x] = 1
+			}
+			for i, in := range node.In {
+				if i == out {
+					continue
+				} else if mode == maxSum {
+					value[x] += in.Value[x]
+				} else {
+					value[x] *= in.Value[x]
+				}
+			}
+		}
+	} else if node.Constraint != nil {
+		copy(value, node.constraintMessage(out))
+	} else {
+		node.tableMessage(out, value, mode)
+	}
+	if mode == maxSum {
+		return logScale + logNormalize(value)
+	}
+	return logScale + normalize(value)
+}
+
+// Compute the message from a constraint to the variable at an index into Out
+func (node *bpNode) constraintMessage(out int) []float64 {
+	var (
+		adj = node.Constraint.Adjacent()
+		in  = make([][]float64, len(adj))
+	)
+	for i, v := range adj {
+		if dv, ok := v.(*variable.DiscreteRV); ok && dv.Space().Size() >= 0 {
+			in[i] = make([]float64, dv.Space().Size())
+		}
+	}
+	var target int
+	for i, v := range adj {
+		for k, msg := range node.Out {
+			if msg.To.Variable == v {
+				if k == out {
+					target = i
+				} else {
+					in[i] = node.In[k].Value
+				}
+			}
+		}
+	}
+	return node.Constraint.Messages(in)[target]
+}
+
+// Compute the message from a table to the variable at an index into Out, by
+// summing (or maximizing) over the other variables
+func (node *bpNode) tableMessage(out int, value []float64, mode messageMode) {
+	for x := range value {
+		if mode == maxSum {
+			value[x] = math.Inf(-1)
+		} else {
+			value[x] = 0
+		}
+	}
+	var outcomes = make([]dist.Outcome, len(node.Table.Vars))
+	for idx, score := range node.Table.Values {
+		if score == 0 {
+			continue
+		}
+		node.tableOutcomes(idx, outcomes)
+		var p = score
+		if mode == maxSum {
+			p = math.Log(score)
+		}
+		for i, in := range node.In {
+			if i == out {
+				continue
+			} else if mode == maxSum {
+				p += in.Value[outcomes[i]]
+			} else {
+				p *= in.Value[outcomes[i]]
+			}
+		}
+		switch mode {
+		case sumProduct:
+			value[outcomes[out]] += p
+		default:
+			value[outcomes[out]] = math.Max(value[outcomes[out]], p)
+		}
+	}
+}
+
+// Get the outcome of each table variable for an index into the table's
+// values, without allocating
+func (node *bpNode) tableOutcomes(idx int, outcomes []dist.Outcome) {
+	for i := len(node.Table.Vars) - 1; i >= 0; i-- {
+		size := node.Table.Vars[i].Space().Size()
+		outcomes[i] = dist.Outcome(idx % size)
+		idx /= size
+	}
+}
+
+// Get the normalized belief of a variable node, and the log of its
+// normalizer, from all its in-messages
+func (node *bpNode) belief() ([]float64, float64) {
+	var (
+		belief   = make([]float64, node.Variable.Space().Size())
+		logScale float64
+	)
+	for x := range belief {
+		belief[x] = 1
+	}
+	for _, in := range node.In {
+		logScale += in.LogScale
+		for x := range belief {
+			belief[x] *= in.Value[x]
+		}
+	}
+	return belief, logScale + normalize(belief)
+}
+
+// Scale values to sum to one, and return the log of their original sum. If
+// they sum to zero, they are left unchanged.
+func normalize(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	if sum > 0 {
+		for i := range values {
+			values[i] /= sum
+		}
+	}
+	return math.Log(sum)
+}
+
+// Shift log values to have a maximum of zero, and return their original
+// maximum. If they are all -Inf, they are left unchanged.
+func logNormalize(values []float64) float64 {
+	var max = math.Inf(-1)
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	if !math.IsInf(max, -1) {
+		for i := range values {
+			values[i] -= max
+		}
+	}
+	return max
 }
 
 type bpMessage struct {
 
 	// The current message value for each variable assignment in the To node.
-	// We assume only discrete random variables are used.
+	// We assume only discrete random variables are used. Values are
+	// normalized to sum to one.
 	Value []float64
 
+	// The log of the factor by which Value was scaled, including the scale of
+	// the messages it was computed from, so the unnormalized message is
+	// exp(LogScale) * Value
+	LogScale float64
+
 	// The number of times this message has been updated
 	Iter int
 
 	// The nodes involved in the message
 	From, To *bpNode
+
+	// Whether the message has been scheduled to be sent
+	queued bool
 }
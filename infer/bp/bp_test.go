@@ -0,0 +1,155 @@
+package bp
+
+import (
+	"fmt"
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"testing"
+)
+
+// Create a table factor with random values
+func randomTable(vars ...*variable.DiscreteRV) *factor.TableFactor {
+	var table = factor.NewTableFactor(vars, nil)
+	for i := range table.Values {
+		table.Values[i] = 0.1 + rand.Float64()
+	}
+	return table
+}
+
+// Assert that BP finds the same marginals and log partition function as
+// exact enumeration
+func shouldMatchEnumeration(actual interface{}, expected ...interface{}) string {
+	var (
+		result = actual.(*Result)
+		graph  = expected[0].(*factor.FactorGraph)
+	)
+	marginals, logZ, err := factor.EnumerateMarginals(graph)
+	if err != nil {
+		return err.Error()
+	}
+	if msg := ShouldAlmostEqual(result.LogZ, logZ, 1e-9); msg != "" {
+		return msg
+	}
+	for i, m := range marginals {
+		for _, v := range m.Vars {
+			var others []*variable.DiscreteRV
+			for _, u := range m.Vars {
+				if u != v {
+					others = append(others, u)
+				}
+			}
+			var want = m.SumOut(others...)
+			for o, p := range want.Values {
+				if msg := ShouldAlmostEqual(result.Marginals[v][o], p, 1e-9); msg != "" {
+					return fmt.Sprintf("Factor %d: %s", i, msg)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func TestInferForTree(t *testing.T) {
+	var boolVar = func() *variable.DiscreteRV {
+		return variable.NewDiscreteRV(0, dist.BooleanSpace)
+	}
+
+	Convey("Given a chain with evidence", t, func() {
+		var (
+			a, b, c, d = boolVar(), boolVar(), boolVar(), boolVar()
+			e          = variable.NewDiscreteRV(0, dist.NewIntegerIntervalSpace(0, 2))
+			graph      = factor.NewFactorGraph()
+		)
+		graph.AddFactor(randomTable(a))
+		graph.AddFactor(randomTable(a, b))
+		graph.AddFactor(randomTable(b, e))
+		graph.AddFactor(randomTable(e, c))
+		graph.AddFactor(randomTable(c, d))
+		d.Observe(1)
+
+		result, err := InferForTree(*graph)
+		So(err, ShouldBeNil)
+		So(result, shouldMatchEnumeration, graph)
+		So(result.Marginals[d], ShouldResemble, []float64{0, 1})
+		So(result.Marginals[e], ShouldHaveLength, 3)
+		So(d.Val(), ShouldEqual, 1)
+	})
+
+	Convey("Given a forest with a star and a constraint", t, func() {
+		var (
+			center = boolVar()
+			leaves = []*variable.DiscreteRV{boolVar(), boolVar(), boolVar()}
+			x, y   = boolVar(), boolVar()
+			xor    = boolVar()
+			graph  = factor.NewFactorGraph()
+		)
+		for _, leaf := range leaves {
+			graph.AddFactor(randomTable(center, leaf))
+		}
+		graph.AddFactor(randomTable(x))
+		graph.AddFactor(randomTable(y))
+		graph.AddFactor(factor.NewXORFactor(xor, x, y))
+		graph.AddFactor(factor.NewDistFactor([]variable.RandomVariable{xor,
+			variable.NewContinuousRV(0.3, dist.UnitIntervalSpace)}, dist.NewBernoulliDist(0.5)))
+		graph.Variables[len(graph.Variables)-1].Variable.(*variable.ContinuousRV).Observe(0.3)
+
+		result, err := InferForTree(*graph)
+		So(err, ShouldBeNil)
+		So(result, shouldMatchEnumeration, graph)
+	})
+
+	Convey("Cycles through observed variables are allowed", t, func() {
+		var (
+			a, b, c = boolVar(), boolVar(), boolVar()
+			graph   = factor.NewFactorGraph()
+		)
+		graph.AddFactor(randomTable(a, b))
+		graph.AddFactor(randomTable(b, c))
+		graph.AddFactor(randomTable(c, a))
+		So(graph.HasCycle(), ShouldBeTrue)
+		_, err := InferForTree(*graph)
+		So(err, ShouldEqual, stats.ErrGraphNotTree)
+
+		b.Observe(0)
+		result, err := InferForTree(*graph)
+		So(err, ShouldBeNil)
+		So(result, shouldMatchEnumeration, graph)
+	})
+
+	Convey("Cycles are reported when other components are trees", t, func() {
+		var (
+			a, b, c, d = boolVar(), boolVar(), boolVar(), boolVar()
+			graph      = factor.NewFactorGraph()
+		)
+		graph.AddFactor(randomTable(a, b))
+		graph.AddFactor(randomTable(b, c))
+		graph.AddFactor(randomTable(c, a))
+		graph.AddFactor(randomTable(d))
+		_, err := InferForTree(*graph)
+		So(err, ShouldEqual, stats.ErrGraphNotTree)
+	})
+
+	Convey("Latent continuous variables are rejected", t, func() {
+		var graph = factor.NewFactorGraph()
+		graph.AddFactor(factor.NewDistFactor([]variable.RandomVariable{boolVar(),
+			variable.NewContinuousRV(0.3, dist.UnitIntervalSpace)}, dist.NewBernoulliDist(0.5)))
+		_, err := InferForTree(*graph)
+		So(err, ShouldEqual, stats.ErrDiscreteOnly)
+	})
+
+	Convey("Impossible evidence is reported", t, func() {
+		var (
+			a, b  = boolVar(), boolVar()
+			graph = factor.NewFactorGraph()
+		)
+		graph.AddFactor(factor.NewEqualityFactor(a, b))
+		graph.AddFactor(factor.NewNotFactor(a, b))
+		b.Observe(1)
+		_, err := InferForTree(*graph)
+		So(err, ShouldEqual, stats.ErrZeroProb)
+	})
+}
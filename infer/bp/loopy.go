@@ -0,0 +1,420 @@
+package bp
+
+import (
+	"container/heap"
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/factor"
+	"math"
+)
+
+// The order in which loopy BP updates messages
+type Schedule int
+
+const (
+	// Update every message in each iteration, in two half-steps: all
+	// factor-to-variable messages from the variable-to-factor messages of the
+	// previous iteration, then all variable-to-factor messages from the new
+	// factor-to-variable messages
+	Flooding Schedule = iota
+
+	// Always update the message which would change the most next, which
+	// often converges faster and more reliably than flooding
+	Residual
+)
+
+// Options for loopy belief propagation
+type LoopyOptions struct {
+
+	// The weight given to the old value of a message when it is updated,
+	// from zero (no damping) to less than one. Damping helps loopy BP
+	// converge on graphs with tight loops.
+	Damping float64
+
+	// The order in which messages are updated
+	Schedule Schedule
+
+	// Stop when no message would change by more than Tolerance
+	Tolerance float64
+
+	// The maximum number of iterations. Under residual scheduling, an
+	// iteration is as many updates as there are messages.
+	MaxIter int
+
+	// If non-nil, called after each iteration with its diagnostics
+	Callback func(iter Iteration)
+}
+
+// Get the default options for loopy belief propagation
+func DefaultLoopyOptions() LoopyOptions {
+	return LoopyOptions{
+		Damping:   0,
+		Schedule:  Flooding,
+		Tolerance: 1e-6,
+		MaxIter:   100,
+	}
+}
+
+// Diagnostics for one iteration of loopy belief propagation
+type Iteration struct {
+
+	// The iteration number, starting at one
+	Iter int
+
+	// The number of messages updated in the iteration
+	Updates int
+
+	// The largest change to any message value in the iteration, or under
+	// residual scheduling, the largest pending change after the iteration
+	MaxDelta float64
+}
+
+// The result of loopy belief propagation
+type LoopyResult struct {
+
+	// The approximate marginals, and the approximate log partition function,
+	// which is the negated Bethe free energy
+	Result
+
+	// The Bethe free energy of the final beliefs
+	BetheFreeEnergy float64
+
+	// Whether the messages converged within the tolerance
+	Converged bool
+
+	// Diagnostics for each iteration
+	Iterations []Iteration
+}
+
+// Perform loopy belief propagation on a factor graph which may have cycles,
+// returning approximate marginals. On a tree, the marginals and log partition
+// function are exact once the messages converge. Observed variables are
+// treated as constants. All latent variables must be discrete. Failing to
+// converge is not an error; check Converged.
+func InferLoopy(graph factor.FactorGraph, opts LoopyOptions) (*LoopyResult, error) {
+	_, result, err := inferLoopy(graph, opts)
+	return result, err
+}
+
+// Get a MarginalInference method which runs loopy belief propagation, for
+// training a CRF with factor.CRFTrainer. The factor marginals are the
+// factors' beliefs, and the log partition function is the negated Bethe free
+// energy, so both are exact on trees. Failing to converge is not an error.
+func LoopyFactorMarginals(opts LoopyOptions) factor.MarginalInference {
+	return func(graph *factor.FactorGraph) ([]*factor.TableFactor, float64, error) {
+		tree, result, err := inferLoopy(*graph, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+		marginals, err := tree.factorMarginals()
+		if err != nil {
+			return nil, 0, err
+		}
+		return marginals, result.LogZ, nil
+	}
+}
+
+// Run loopy belief propagation, returning the message-passing structure along
+// with the result
+func inferLoopy(graph factor.FactorGraph, opts LoopyOptions) (bpGraph, *LoopyResult, error) {
+	if opts.Damping < 0 || opts.Damping >= 1 {
+		return nil, nil, stats.Errorf("Damping %f is not in [0, 1)", opts.Damping)
+	}
+	tree, err := buildBPTree(graph, 1, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, node := range tree {
+		for _, msg := range node.Out {
+			normalize(msg.Value)
+		}
+	}
+
+	var result = &LoopyResult{}
+	if result.Converged, result.Iterations, err = tree.loopy(opts, sumProduct); err != nil {
+		return nil, nil, err
+	}
+	if result.Marginals, err = tree.marginals(graph); err != nil {
+		return nil, nil, err
+	}
+	result.BetheFreeEnergy = tree.betheFreeEnergy()
+	result.LogZ = -result.BetheFreeEnergy
+	return tree, result, nil
+}
+
+// Pass messages until they converge, using the schedule in the options.
+// Returns whether they converged, and diagnostics for each iteration.
+func (tree bpGraph) loopy(opts LoopyOptions, mode messageMode) (bool, []Iteration, error) {
+	switch opts.Schedule {
+	case Flooding:
+		converged, iters := tree.flood(opts, mode)
+		return converged, iters, nil
+	case Residual:
+		converged, iters := tree.residual(opts, mode)
+		return converged, iters, nil
+	}
+	return false, nil, stats.Errorf("Unknown schedule %d", opts.Schedule)
+}
+
+// Update all messages until they converge. Each iteration updates every
+// factor-to-variable message at once, then every variable-to-factor message
+// at once, so the second half-step already sees the first half-step's
+// messages.
+func (tree bpGraph) flood(opts LoopyOptions, mode messageMode) (converged bool, iters []Iteration) {
+	var next = make(map[*bpMessage][]float64)
+	for _, node := range tree {
+		for _, msg := range node.Out {
+			next[msg] = make([]float64, len(msg.Value))
+		}
+	}
+	for iter := 1; iter <= opts.MaxIter; iter++ {
+		var it = Iteration{Iter: iter}
+		for _, fromFactors := range []bool{true, false} {
+			for _, node := range tree {
+				if (node.Variable == nil) != fromFactors {
+					continue
+				}
+				for out, msg := range node.Out {
+					node.compute(out, next[msg], mode)
+				}
+			}
+			for _, node := range tree {
+				if (node.Variable == nil) != fromFactors {
+					continue
+				}
+				for _, msg := range node.Out {
+					var delta = msg.update(next[msg], opts.Damping)
+					it.MaxDelta = math.Max(it.MaxDelta, delta)
+					it.Updates++
+				}
+			}
+		}
+		iters = append(iters, it)
+		if opts.Callback != nil {
+			opts.Callback(it)
+		}
+		if it.MaxDelta <= opts.Tolerance {
+			return true, iters
+		}
+	}
+	return false, iters
+}
+
+// Repeatedly update the message with the largest pending change, until no
+// pending change exceeds the tolerance
+func (tree bpGraph) residual(opts LoopyOptions, mode messageMode) (converged bool, iters []Iteration) {
+	var queue residualQueue
+	for _, node := range tree {
+		for out, msg := range node.Out {
+			var item = &residualItem{node: node, out: out, mode: mode,
+				next: make([]float64, len(msg.Value))}
+			item.refresh(opts.Damping)
+			queue.items = append(queue.items, item)
+		}
+	}
+	for i, item := range queue.items {
+		item.index = i
+	}
+	heap.Init(&queue)
+	if len(queue.items) == 0 {
+		return true, nil
+	}
+
+	// Index the queue items by message, to find the messages to refresh when
+	// a message changes
+	var items = make(map[*bpMessage]*residualItem)
+	for _, item := range queue.items {
+		items[item.node.Out[item.out]] = item
+	}
+
+	var perIter = len(queue.items)
+	for iter := 1; iter <= opts.MaxIter; iter++ {
+		var it = Iteration{Iter: iter}
+		for u := 0; u < perIter && queue.items[0].residual > opts.Tolerance; u++ {
+			var (
+				item = queue.items[0]
+				msg  = item.node.Out[item.out]
+			)
+			msg.update(item.next, 0)
+			it.Updates++
+
+			// With damping, the message only moves part of the way to its
+			// new value, so it may still be pending
+			item.refresh(opts.Damping)
+			heap.Fix(&queue, 0)
+
+			// Messages out of the receiving node now have new inputs
+			for _, dep := range msg.To.Out {
+				if dep.To != msg.From {
+					var depItem = items[dep]
+					depItem.refresh(opts.Damping)
+					heap.Fix(&queue, depItem.index)
+				}
+			}
+		}
+		it.MaxDelta = queue.items[0].residual
+		iters = append(iters, it)
+		if opts.Callback != nil {
+			opts.Callback(it)
+		}
+		if it.MaxDelta <= opts.Tolerance {
+			return true, iters
+		}
+	}
+	return false, iters
+}
+
+// Replace a message's value with a damped new value, and return the largest
+// change to any entry
+func (msg *bpMessage) update(value []float64, damping float64) float64 {
+	var delta float64
+	for x, v := range value {
+		v = (1-damping)*v + damping*msg.Value[x]
+		delta = math.Max(delta, math.Abs(v-msg.Value[x]))
+		msg.Value[x] = v
+	}
+	msg.Iter++
+	return delta
+}
+
+// A pending message update for residual scheduling
+type residualItem struct {
+	node     *bpNode
+	out      int
+	mode     messageMode
+	next     []float64
+	residual float64
+	index    int
+}
+
+// Recompute the pending value of the message, and its residual
+func (item *residualItem) refresh(damping float64) {
+	var msg = item.node.Out[item.out]
+	item.node.compute(item.out, item.next, item.mode)
+	item.residual = 0
+	for x, v := range item.next {
+		v = (1-damping)*v + damping*msg.Value[x]
+		item.next[x] = v
+		item.residual = math.Max(item.residual, math.Abs(v-msg.Value[x]))
+	}
+}
+
+// A max-heap of pending message updates by residual
+type residualQueue struct {
+	items []*residualItem
+}
+
+func (q residualQueue) Len() int {
+	return len(q.items)
+}
+
+func (q residualQueue) Less(i, j int) bool {
+	return q.items[i].residual > q.items[j].residual
+}
+
+func (q residualQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].index = i
+	q.items[j].index = j
+}
+
+func (q *residualQueue) Push(x interface{}) {
+	var item = x.(*residualItem)
+	item.index = len(q.items)
+	q.items = append(q.items, item)
+}
+
+func (q *residualQueue) Pop() interface{} {
+	var item = q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return item
+}
+
+// Get the belief of every variable node, and indicators for observed
+// variables. Returns ErrZeroProb if a belief is zero everywhere.
+func (tree bpGraph) marginals(graph factor.FactorGraph) (Marginals, error) {
+	var result = Marginals{}
+	for _, node := range tree {
+		if node.Variable == nil {
+			continue
+		}
+		belief, logScale := node.belief()
+		if logScale == math.Inf(-1) {
+			return nil, stats.ErrZeroProb
+		}
+		result[node.Variable] = belief
+	}
+	result.addEvidence(graph)
+	return result, nil
+}
+
+// Get the belief of every factor node over its latent variables, in the
+// order of the graph's factors. Returns ErrZeroProb if a belief is zero
+// everywhere.
+func (tree bpGraph) factorMarginals() ([]*factor.TableFactor, error) {
+	var marginals []*factor.TableFactor
+	for _, node := range tree {
+		if node.Variable != nil {
+			continue
+		}
+		belief, _ := node.factorBelief()
+		if belief.Sum() == 0 {
+			return nil, stats.ErrZeroProb
+		}
+		marginals = append(marginals, belief)
+	}
+	return marginals, nil
+}
+
+// Get the normalized belief of a factor node from its in-messages, as a
+// table over its latent variables, along with the factor's own table.
+// Constraints are enumerated to find their tables.
+func (node *bpNode) factorBelief() (belief, table *factor.TableFactor) {
+	table = node.Table
+	if table == nil {
+		table = factor.NewTableFactorFrom(node.Factor)
+	}
+	belief = factor.NewTableFactor(table.Vars, nil)
+	for idx, score := range table.Values {
+		belief.Values[idx] = score
+		for i, o := range table.Outcomes(idx) {
+			belief.Values[idx] *= node.In[i].Value[o]
+		}
+	}
+	normalize(belief.Values)
+	return belief, table
+}
+
+// Compute the Bethe free energy of the current beliefs:
+//
+//	F = sum_f sum_x b_f(x) ln(b_f(x) / f(x)) + sum_v (d_v - 1) H(b_v)
+//
+// where d_v is the number of factors adjacent to v, and H is entropy. On a
+// tree with converged messages, -F is the exact log partition function.
+func (tree bpGraph) betheFreeEnergy() float64 {
+	var energy float64
+	for _, node := range tree {
+		if node.Variable != nil {
+			belief, _ := node.belief()
+			energy += float64(len(node.In)-1) * entropy(belief)
+			continue
+		}
+		belief, table := node.factorBelief()
+		for idx, b := range belief.Values {
+			if b > 0 {
+				energy += b * math.Log(b/table.Values[idx])
+			}
+		}
+	}
+	return energy
+}
+
+// Get the entropy of a distribution, in nats
+func entropy(p []float64) float64 {
+	var h float64
+	for _, v := range p {
+		if v > 0 {
+			h -= v * math.Log(v)
+		}
+	}
+	return h
+}
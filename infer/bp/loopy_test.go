@@ -0,0 +1,117 @@
+package bp
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+func TestInferLoopy(t *testing.T) {
+	var boolVar = func() *variable.DiscreteRV {
+		return variable.NewDiscreteRV(0, dist.BooleanSpace)
+	}
+
+	Convey("Given a tree", t, func() {
+		var (
+			a, b, c, d, e = boolVar(), boolVar(), boolVar(), boolVar(), boolVar()
+			graph         = factor.NewFactorGraph()
+		)
+		graph.AddFactor(randomTable(a))
+		graph.AddFactor(randomTable(a, b))
+		graph.AddFactor(randomTable(b, c))
+		graph.AddFactor(randomTable(b, d))
+		graph.AddFactor(factor.NewAtMostKFactor(1, c, e))
+
+		for _, schedule := range []Schedule{Flooding, Residual} {
+			Convey("Loopy BP is exact with schedule "+[]string{"flooding", "residual"}[schedule], func() {
+				opts := DefaultLoopyOptions()
+				opts.Schedule = schedule
+				opts.Tolerance = 1e-12
+				result, err := InferLoopy(*graph, opts)
+				So(err, ShouldBeNil)
+				So(result.Converged, ShouldBeTrue)
+				So(&result.Result, shouldMatchEnumeration, graph)
+				So(result.BetheFreeEnergy, ShouldEqual, -result.LogZ)
+			})
+		}
+
+		Convey("Factor marginals match enumeration", func() {
+			opts := DefaultLoopyOptions()
+			opts.Tolerance = 1e-12
+			want, wantLogZ, err := factor.EnumerateMarginals(graph)
+			So(err, ShouldBeNil)
+			got, logZ, err := LoopyFactorMarginals(opts)(graph)
+			So(err, ShouldBeNil)
+			So(logZ, ShouldAlmostEqual, wantLogZ, 1e-9)
+			So(got, ShouldHaveLength, len(want))
+			for i, m := range want {
+				So(got[i].Vars, ShouldResemble, m.Vars)
+				for x, p := range m.Values {
+					So(got[i].Values[x], ShouldAlmostEqual, p, 1e-9)
+				}
+			}
+		})
+	})
+
+	Convey("Given a graph with a cycle", t, func() {
+		var (
+			vars  = []*variable.DiscreteRV{boolVar(), boolVar(), boolVar(), boolVar()}
+			graph = factor.NewFactorGraph()
+		)
+		for i, v := range vars {
+			graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{v}, []float64{1, 1 + float64(i)/4}))
+			graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{v, vars[(i+1)%len(vars)]},
+				[]float64{1.2, 1, 1, 1.2}))
+		}
+		marginals, logZ, _ := factor.EnumerateMarginals(graph)
+
+		for _, schedule := range []Schedule{Flooding, Residual} {
+			Convey("Loopy BP is approximately correct with schedule "+[]string{"flooding", "residual"}[schedule], func() {
+				var (
+					opts  = DefaultLoopyOptions()
+					iters []Iteration
+				)
+				opts.Schedule = schedule
+				opts.Damping = 0.3
+				opts.Callback = func(iter Iteration) {
+					iters = append(iters, iter)
+				}
+				result, err := InferLoopy(*graph, opts)
+				So(err, ShouldBeNil)
+				So(result.Converged, ShouldBeTrue)
+				So(iters, ShouldResemble, result.Iterations)
+				So(iters[len(iters)-1].MaxDelta, ShouldBeLessThanOrEqualTo, opts.Tolerance)
+				for i, v := range vars {
+					So(result.Marginals[v][1], ShouldAlmostEqual, marginals[2*i].Value(1), 0.001)
+				}
+				So(result.LogZ, ShouldAlmostEqual, logZ, 0.01)
+				So(math.IsNaN(result.BetheFreeEnergy), ShouldBeFalse)
+			})
+		}
+
+		Convey("Running out of iterations is reported", func() {
+			opts := DefaultLoopyOptions()
+			opts.MaxIter = 1
+			result, err := InferLoopy(*graph, opts)
+			So(err, ShouldBeNil)
+			So(result.Converged, ShouldBeFalse)
+			So(result.Iterations, ShouldHaveLength, 1)
+			So(result.Iterations[0].Updates, ShouldEqual, 24)
+		})
+
+		Convey("Invalid damping is rejected", func() {
+			opts := DefaultLoopyOptions()
+			opts.Damping = 1
+			_, err := InferLoopy(*graph, opts)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("InferForTree refuses to run", func() {
+			_, err := InferForTree(*graph)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,341 @@
+package bp
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"math"
+)
+
+// The result of MAP (maximum a posteriori) inference
+type MAPResult struct {
+
+	// The outcome of each latent discrete variable in the most probable
+	// joint assignment found
+	Assignment map[*variable.DiscreteRV]dist.Outcome
+
+	// The log of the graph's score at the assignment
+	LogScore float64
+
+	// Whether message passing converged, and diagnostics for each iteration.
+	// With decimation, these cover every round.
+	Converged  bool
+	Iterations []Iteration
+}
+
+// Set each variable to its assigned outcome
+func (result MAPResult) Apply() {
+	for v, outcome := range result.Assignment {
+		v.SetOutcome(outcome)
+	}
+}
+
+// Options for loopy MAP inference
+type MAPOptions struct {
+	LoopyOptions
+
+	// Whether to pass max-sum messages in log space, rather than max-product
+	// messages. This avoids underflow when factor scores are tiny.
+	LogSpace bool
+
+	// Whether to decimate: repeatedly clamp the variable with the most
+	// decisive max-marginal and rerun message passing. This resolves ties
+	// which would otherwise make the assignment inconsistent, at the cost of
+	// one run per variable.
+	Decimate bool
+}
+
+// Get the default options for loopy MAP inference
+func DefaultMAPOptions() MAPOptions {
+	return MAPOptions{
+		LoopyOptions: DefaultLoopyOptions(),
+		LogSpace:     true,
+	}
+}
+
+// Find the most probable joint assignment of the latent variables of a
+// tree-structured factor graph, using max-product (or, with logSpace,
+// max-sum) messages. The assignment is recovered by backtracking from one
+// variable in each tree, so it is consistent even if there are ties. Returns
+// ErrGraphNotTree if the graph has a cycle among its latent variables.
+func MAPForTree(graph factor.FactorGraph, logSpace bool) (*MAPResult, error) {
+	var (
+		mode    = maxProduct
+		initial = 1.0
+	)
+	if logSpace {
+		mode, initial = maxSum, 0
+	}
+	tree, err := buildBPTree(graph, initial, false)
+	if err != nil {
+		return nil, err
+	} else if err = tree.passMessages(mode); err != nil {
+		return nil, err
+	}
+	var result = &MAPResult{
+		Assignment: tree.decode(mode),
+		Converged:  true,
+	}
+	result.LogScore = logScoreAt(graph, result.Assignment)
+	return result, nil
+}
+
+// Find an approximate most probable joint assignment of the latent
+// variables of a factor graph which may have cycles, using loopy max-product
+// (or max-sum) messages. Each variable takes the outcome which maximizes its
+// max-marginal, with ties going to the lowest outcome, unless decimation is
+// used. Variables are unchanged afterwards.
+func MAPLoopy(graph factor.FactorGraph, opts MAPOptions) (*MAPResult, error) {
+	var (
+		mode    = maxProduct
+		initial = 1.0
+		result  = &MAPResult{
+			Assignment: make(map[*variable.DiscreteRV]dist.Outcome),
+			Converged:  true,
+		}
+		clamped []*variable.DiscreteRV
+		saved   []dist.Outcome
+	)
+	if opts.LogSpace {
+		mode, initial = maxSum, 0
+	}
+	defer func() {
+		for i, v := range clamped {
+			v.Release()
+			v.SetOutcome(saved[i])
+		}
+	}()
+
+	for {
+		tree, err := buildBPTree(graph, initial, false)
+		if err != nil {
+			return nil, err
+		}
+		if mode == maxProduct {
+			for _, node := range tree {
+				for _, msg := range node.Out {
+					normalize(msg.Value)
+				}
+			}
+		}
+		converged, iters, err := tree.loopy(opts.LoopyOptions, mode)
+		if err != nil {
+			return nil, err
+		}
+		result.Converged = result.Converged && converged
+		result.Iterations = append(result.Iterations, iters...)
+
+		// Assign every variable, or clamp the most decisive one and repeat
+		var (
+			best    *variable.DiscreteRV
+			bestOut dist.Outcome
+			bestGap = math.Inf(-1)
+		)
+		for _, node := range tree {
+			if node.Variable == nil {
+				continue
+			}
+			var (
+				belief    = node.maxBelief(mode)
+				out       = argmax(belief)
+				secondVal = math.Inf(-1)
+			)
+			for x, val := range belief {
+				if x != int(out) {
+					secondVal = math.Max(secondVal, val)
+				}
+			}
+			result.Assignment[node.Variable] = out
+			if gap := belief[out] - secondVal; best == nil || gap > bestGap {
+				best, bestOut, bestGap = node.Variable, out, gap
+			}
+		}
+		if !opts.Decimate || best == nil {
+			break
+		}
+		clamped = append(clamped, best)
+		saved = append(saved, best.Outcome())
+		best.ObserveOutcome(bestOut)
+	}
+	for _, v := range clamped {
+		result.Assignment[v] = v.Outcome()
+	}
+	for i, v := range clamped {
+		v.Release()
+		v.SetOutcome(saved[i])
+	}
+	clamped = nil
+	result.LogScore = logScoreAt(graph, result.Assignment)
+	return result, nil
+}
+
+// Find a locally most probable joint assignment by iterated conditional
+// modes: starting from the current values, repeatedly set each latent
+// discrete variable to the outcome which maximizes the score of its adjacent
+// factors, until no variable changes or maxIter sweeps have run. Other
+// latent variables are held fixed. The variables are left at the assignment.
+// Each iteration reports the number of variables changed and the largest
+// increase in a variable's local log score.
+func ICM(graph factor.FactorGraph, maxIter int) *MAPResult {
+	var (
+		vars   []*variable.DiscreteRV
+		result = &MAPResult{
+			Assignment: make(map[*variable.DiscreteRV]dist.Outcome),
+		}
+	)
+	for _, v := range graph.LatentVariables() {
+		if dv, ok := v.(*variable.DiscreteRV); ok && dv.Space().Size() >= 0 {
+			vars = append(vars, dv)
+		}
+	}
+	for iter := 1; iter <= maxIter; iter++ {
+		var it = Iteration{Iter: iter}
+		for _, v := range vars {
+			var (
+				current = v.Outcome()
+				base    = graph.ScoreVar(v)
+				best    = current
+				bestVal = base
+			)
+			for o := 0; o < v.Space().Size(); o++ {
+				if dist.Outcome(o) == current {
+					continue
+				}
+				v.SetOutcome(dist.Outcome(o))
+				if val := graph.ScoreVar(v); val > bestVal {
+					best, bestVal = dist.Outcome(o), val
+				}
+			}
+			v.SetOutcome(best)
+			if best != current {
+				it.Updates++
+				if delta := bestVal - base; delta > it.MaxDelta {
+					it.MaxDelta = delta
+				}
+			}
+		}
+		result.Iterations = append(result.Iterations, it)
+		if it.Updates == 0 {
+			result.Converged = true
+			break
+		}
+	}
+	for _, v := range vars {
+		result.Assignment[v] = v.Outcome()
+	}
+	result.LogScore = graph.Score()
+	return result
+}
+
+// Recover a consistent joint assignment after max-product message passing
+// on a tree, by choosing the best outcome for one variable in each tree and
+// then the best outcomes of each factor's other variables given the outcomes
+// already chosen
+func (tree bpGraph) decode(mode messageMode) map[*variable.DiscreteRV]dist.Outcome {
+	var (
+		assignment = make(map[*variable.DiscreteRV]dist.Outcome)
+		visited    = make(map[*bpNode]bool)
+	)
+	for _, root := range tree {
+		if root.Variable == nil || visited[root] {
+			continue
+		}
+		visited[root] = true
+		assignment[root.Variable] = argmax(root.maxBelief(mode))
+		var stack = []*bpNode{root}
+		for len(stack) > 0 {
+			var v = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, msg := range v.Out {
+				var f = msg.To
+				if visited[f] {
+					continue
+				}
+				visited[f] = true
+				for i, outcome := range f.decodeFactor(assignment, mode) {
+					var u = f.Out[i].To
+					if !visited[u] {
+						visited[u] = true
+						assignment[u.Variable] = outcome
+						stack = append(stack, u)
+					}
+				}
+			}
+		}
+	}
+	return assignment
+}
+
+// Find the table entry which maximizes the factor's score times the
+// messages from its unassigned variables, among the entries consistent with
+// its assigned variables. Returns the entry's outcome for each variable.
+func (node *bpNode) decodeFactor(assignment map[*variable.DiscreteRV]dist.Outcome,
+	mode messageMode) []dist.Outcome {
+
+	var (
+		outcomes = make([]dist.Outcome, len(node.Table.Vars))
+		best     []dist.Outcome
+		bestVal  = math.Inf(-1)
+	)
+	for idx, score := range node.Table.Values {
+		node.tableOutcomes(idx, outcomes)
+		var (
+			val        = math.Log(score)
+			consistent = true
+		)
+		for i, v := range node.Table.Vars {
+			if o, ok := assignment[v]; ok {
+				consistent = consistent && o == outcomes[i]
+			} else if mode == maxSum {
+				val += node.In[i].Value[outcomes[i]]
+			} else {
+				val += math.Log(node.In[i].Value[outcomes[i]])
+			}
+		}
+		if consistent && (best == nil || val > bestVal) {
+			best, bestVal = append([]dist.Outcome(nil), outcomes...), val
+		}
+	}
+	return best
+}
+
+// Get the log max-marginal of a variable node, from all its in-messages
+func (node *bpNode) maxBelief(mode messageMode) []float64 {
+	var belief = make([]float64, node.Variable.Space().Size())
+	for _, in := range node.In {
+		for x := range belief {
+			if mode == maxSum {
+				belief[x] += in.Value[x]
+			} else {
+				belief[x] += math.Log(in.Value[x])
+			}
+		}
+	}
+	return belief
+}
+
+// Get the index of the largest value, preferring the first if there are ties
+func argmax(values []float64) dist.Outcome {
+	var best int
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return dist.Outcome(best)
+}
+
+// Get the log score of a graph with its variables set to an assignment.
+// Variable values are restored afterwards.
+func logScoreAt(graph factor.FactorGraph, assignment map[*variable.DiscreteRV]dist.Outcome) float64 {
+	var saved = make(map[*variable.DiscreteRV]dist.Outcome)
+	for v, outcome := range assignment {
+		saved[v] = v.Outcome()
+		v.SetOutcome(outcome)
+	}
+	var score = graph.Score()
+	for v, outcome := range saved {
+		v.SetOutcome(outcome)
+	}
+	return score
+}
@@ -0,0 +1,172 @@
+package bp
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+// Find the most probable joint assignment of the latent variables by
+// enumeration. Variable values are restored afterwards.
+func bruteForceMAP(graph *factor.FactorGraph) (map[*variable.DiscreteRV]dist.Outcome, float64) {
+	var vars []*variable.DiscreteRV
+	for _, v := range graph.LatentVariables() {
+		vars = append(vars, v.(*variable.DiscreteRV))
+	}
+	var (
+		table      = factor.NewTableFactor(vars, nil)
+		best       map[*variable.DiscreteRV]dist.Outcome
+		bestScore  = math.Inf(-1)
+		assignment = make(map[*variable.DiscreteRV]dist.Outcome)
+	)
+	for idx := range table.Values {
+		for i, o := range table.Outcomes(idx) {
+			assignment[vars[i]] = o
+		}
+		if score := logScoreAt(*graph, assignment); score > bestScore {
+			best, bestScore = make(map[*variable.DiscreteRV]dist.Outcome), score
+			for v, o := range assignment {
+				best[v] = o
+			}
+		}
+	}
+	return best, bestScore
+}
+
+func TestMAPForTree(t *testing.T) {
+	var boolVar = func() *variable.DiscreteRV {
+		return variable.NewDiscreteRV(0, dist.BooleanSpace)
+	}
+
+	Convey("Given a tree with evidence", t, func() {
+		var (
+			a, b, c, d = boolVar(), boolVar(), boolVar(), boolVar()
+			e          = variable.NewDiscreteRV(0, dist.NewIntegerIntervalSpace(0, 2))
+			graph      = factor.NewFactorGraph()
+		)
+		graph.AddFactor(randomTable(a))
+		graph.AddFactor(randomTable(a, b))
+		graph.AddFactor(randomTable(b, e))
+		graph.AddFactor(randomTable(e, c))
+		graph.AddFactor(randomTable(e, d))
+		graph.AddFactor(factor.NewXORFactor(c, d))
+		d.Observe(1)
+		want, wantScore := bruteForceMAP(graph)
+
+		for _, logSpace := range []bool{false, true} {
+			result, err := MAPForTree(*graph, logSpace)
+			So(err, ShouldBeNil)
+			So(result.Assignment, ShouldResemble, want)
+			So(result.LogScore, ShouldAlmostEqual, wantScore)
+			So(d.Val(), ShouldEqual, 1)
+		}
+	})
+
+	Convey("Backtracking gives a consistent assignment when there are ties", t, func() {
+		var (
+			a, b, c = boolVar(), boolVar(), boolVar()
+			graph   = factor.NewFactorGraph()
+			differ  = []float64{1, 2, 2, 1}
+		)
+		graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{a, b}, differ))
+		graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{b, c}, differ))
+		result, err := MAPForTree(*graph, true)
+		So(err, ShouldBeNil)
+		So(result.LogScore, ShouldAlmostEqual, math.Log(4))
+		result.Apply()
+		So(a.Val(), ShouldNotEqual, b.Val())
+		So(b.Val(), ShouldNotEqual, c.Val())
+	})
+
+	Convey("Cycles are rejected", t, func() {
+		var (
+			a, b, c = boolVar(), boolVar(), boolVar()
+			graph   = factor.NewFactorGraph()
+		)
+		graph.AddFactor(randomTable(a, b))
+		graph.AddFactor(randomTable(b, c))
+		graph.AddFactor(randomTable(c, a))
+		_, err := MAPForTree(*graph, true)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestMAPLoopy(t *testing.T) {
+	var boolVar = func() *variable.DiscreteRV {
+		return variable.NewDiscreteRV(0, dist.BooleanSpace)
+	}
+
+	Convey("Given a single loop", t, func() {
+		var (
+			vars  = []*variable.DiscreteRV{boolVar(), boolVar(), boolVar(), boolVar(), boolVar()}
+			graph = factor.NewFactorGraph()
+		)
+		for i, v := range vars {
+			graph.AddFactor(randomTable(v))
+			graph.AddFactor(randomTable(v, vars[(i+1)%len(vars)]))
+		}
+		want, wantScore := bruteForceMAP(graph)
+
+		Convey("Max-product finds the MAP assignment", func() {
+			for _, logSpace := range []bool{false, true} {
+				opts := DefaultMAPOptions()
+				opts.LogSpace = logSpace
+				opts.Damping = 0.2
+				result, err := MAPLoopy(*graph, opts)
+				So(err, ShouldBeNil)
+				So(result.Converged, ShouldBeTrue)
+				So(result.Assignment, ShouldResemble, want)
+				So(result.LogScore, ShouldAlmostEqual, wantScore)
+			}
+		})
+
+		Convey("ICM finds a local optimum", func() {
+			result := ICM(*graph, 100)
+			So(result.Converged, ShouldBeTrue)
+			So(result.LogScore, ShouldBeLessThanOrEqualTo, wantScore+1e-9)
+			So(result.LogScore, ShouldAlmostEqual, graph.Score())
+			for _, v := range vars {
+				var (
+					score = graph.Score()
+					old   = v.Outcome()
+				)
+				v.SetOutcome(1 - old)
+				So(graph.Score(), ShouldBeLessThanOrEqualTo, score)
+				v.SetOutcome(old)
+			}
+		})
+	})
+
+	Convey("Given a frustrated loop with ties", t, func() {
+		var (
+			vars   = []*variable.DiscreteRV{boolVar(), boolVar(), boolVar()}
+			graph  = factor.NewFactorGraph()
+			differ = []float64{1, 2, 2, 1}
+		)
+		for i, v := range vars {
+			graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{v, vars[(i+1)%len(vars)]}, differ))
+		}
+
+		Convey("Without decimation, ties make the assignment inconsistent", func() {
+			result, err := MAPLoopy(*graph, DefaultMAPOptions())
+			So(err, ShouldBeNil)
+			So(result.LogScore, ShouldAlmostEqual, 0)
+		})
+
+		Convey("Decimation breaks the ties", func() {
+			opts := DefaultMAPOptions()
+			opts.Decimate = true
+			result, err := MAPLoopy(*graph, opts)
+			So(err, ShouldBeNil)
+			So(result.LogScore, ShouldAlmostEqual, math.Log(4))
+			So(result.Assignment, ShouldHaveLength, 3)
+			for _, v := range vars {
+				So(v.IsObserved(), ShouldBeFalse)
+				So(v.Outcome(), ShouldEqual, 0)
+			}
+		})
+	})
+}
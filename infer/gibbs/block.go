@@ -0,0 +1,181 @@
+package gibbs
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"math"
+	"math/rand"
+)
+
+// A ValueSampler which draws several discrete variables jointly from their
+// exact conditional distribution, by enumerating every combination of their
+// outcomes. This mixes much faster than sampling them one at a time when they
+// are strongly coupled, at a cost which grows with the product of their space
+// sizes. Observed variables in the block keep their values. The variable
+// passed to SampleValue() is ignored, and the factors must include every
+// factor adjacent to the block, apart from those of collapsed rates.
+type BlockSampler struct {
+
+	// The variables to sample jointly
+	Vars []*variable.DiscreteRV
+
+	// Rates integrated out of the factors adjacent to the block
+	Collapsed []*CollapsedBeta
+}
+
+// Create a sampler for a block of variables
+func NewBlockSampler(vars ...*variable.DiscreteRV) *BlockSampler {
+	return &BlockSampler{Vars: vars}
+}
+
+// Build the GibbsSample for a block sampler, with the factors of the graph
+// adjacent to any variable in the block. The block is listed under its first
+// variable, but is sampled while any of its variables is latent.
+func NewBlockSample(graph *factor.FactorGraph, sampler *BlockSampler) GibbsSample {
+	var (
+		factors []factor.Factor
+		seen    = make(map[factor.Factor]bool)
+	)
+	for _, v := range sampler.Vars {
+		for _, f := range graph.AdjToVariable(v) {
+			if !seen[f] {
+				seen[f] = true
+				factors = append(factors, f)
+			}
+		}
+	}
+	return GibbsSample{Variable: sampler.Vars[0], Factors: factors, Sampler: sampler}
+}
+
+// Ask whether a sample has a latent variable to update. A block sampler
+// updates all of its variables, so its sample is latent while any of them is.
+func isLatent(s GibbsSample) bool {
+	if block, ok := s.Sampler.(*BlockSampler); ok {
+		for _, v := range block.Vars {
+			if !v.IsObserved() {
+				return true
+			}
+		}
+		return false
+	}
+	return !variable.IsObserved(s.Variable)
+}
+
+func (sampler *BlockSampler) SampleValue(v variable.RandomVariable, factors []factor.Factor) {
+	sampleJoint(sampler.Vars, factors, sampler.Collapsed, rand.Float64)
+}
+
+func (sampler *BlockSampler) SampleValueRand(v variable.RandomVariable, factors []factor.Factor,
+	rng *rand.Rand) {
+	sampleJoint(sampler.Vars, factors, sampler.Collapsed, rng.Float64)
+}
+
+// Draw the latent variables among vars jointly, in proportion to the product
+// of the factors and the marginal likelihoods of the collapsed rates, given a
+// source of uniform random numbers in [0, 1). Panics if a latent variable
+// does not have a finite space.
+func sampleJoint(vars []*variable.DiscreteRV, factors []factor.Factor,
+	collapsed []*CollapsedBeta, uniform func() float64) {
+
+	var latent []*variable.DiscreteRV
+	for _, v := range vars {
+		if v.IsObserved() {
+			continue
+		} else if v.Space().Size() <= 0 {
+			panic(stats.ErrfInfiniteSpace(v))
+		}
+		latent = append(latent, v)
+	}
+	if len(latent) == 0 {
+		return
+	}
+
+	// Score the collapsed factors by their counts, not their rates
+	var (
+		isCollapsed = make(map[factor.Factor]bool)
+		plain       []factor.Factor
+		touched     = make([][]factor.Factor, len(collapsed))
+	)
+	for i, c := range collapsed {
+		for _, f := range c.Factors {
+			isCollapsed[f] = true
+		}
+		touched[i] = c.touching(latent)
+		for _, f := range touched[i] {
+			c.remove(f)
+		}
+	}
+	for _, f := range factors {
+		if !isCollapsed[f] {
+			plain = append(plain, f)
+		}
+	}
+
+	// Enumerate the joint outcomes, with the first variable changing fastest
+	var (
+		size = 1
+		logp []float64
+		best = math.Inf(-1)
+	)
+	for _, v := range latent {
+		size *= v.Space().Size()
+	}
+	logp = make([]float64, size)
+	for i := range logp {
+		setJoint(latent, i)
+		logp[i] = logScore(plain)
+		for j, c := range collapsed {
+			var successes, failures = c.Successes, c.Failures
+			for _, f := range touched[j] {
+				if c.Event(f) {
+					successes++
+				} else {
+					failures++
+				}
+			}
+			logp[i] += c.logMarginal(successes, failures)
+		}
+		best = math.Max(best, logp[i])
+	}
+
+	// Draw in proportion to the scores, relative to the best
+	var (
+		props = make([]float64, size)
+		total float64
+	)
+	for i, lp := range logp {
+		if !math.IsInf(best, -1) {
+			props[i] = math.Exp(lp - best)
+		}
+		total += props[i]
+	}
+	var (
+		remaining = uniform() * total
+		chosen    = size - 1
+	)
+	for i, prop := range props {
+		remaining -= prop
+		if remaining <= 0 && prop > 0 {
+			chosen = i
+			break
+		}
+	}
+	setJoint(latent, chosen)
+	for i, c := range collapsed {
+		for _, f := range touched[i] {
+			c.add(f)
+		}
+	}
+}
+
+// Set the variables to the joint outcome with the given index, with the first
+// variable changing fastest
+func setJoint(vars []*variable.DiscreteRV, index int) {
+	for _, v := range vars {
+		var size = v.Space().Size()
+		v.SetOutcome(dist.Outcome(index % size))
+		index /= size
+	}
+}
@@ -0,0 +1,108 @@
+package gibbs
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+// Get the frequency of each joint outcome of two Boolean variables in a
+// recorder's traces, indexed by a + 2*b
+func jointFrequencies(recorder *Recorder) []float64 {
+	var freqs = make([]float64, 4)
+	for t, a := range recorder.Traces[0] {
+		freqs[int(a)+2*int(recorder.Traces[1][t])] += 1 / float64(recorder.Draws)
+	}
+	return freqs
+}
+
+// Get the exact joint distribution of the variables, in the order used by
+// setJoint, given the log score of the current values
+func exactJoint(vars []*variable.DiscreteRV, logScore func() float64) []float64 {
+	var size = 1
+	for _, v := range vars {
+		size *= v.Space().Size()
+	}
+	var (
+		probs = make([]float64, size)
+		total float64
+	)
+	for i := range probs {
+		setJoint(vars, i)
+		probs[i] = math.Exp(logScore())
+		total += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= total
+	}
+	return probs
+}
+
+func TestBlockSampler(t *testing.T) {
+	Convey("Given two strongly coupled variables", t, func() {
+		var (
+			a     = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			b     = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			vars  = []*variable.DiscreteRV{a, b}
+			graph = factor.NewFactorGraph()
+		)
+		graph.AddFactor(factor.NewTableFactor(vars, []float64{50, 1, 1, 50}))
+		graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{a}, []float64{1, 2}))
+		var want = exactJoint(vars, func() float64 { return logScore(graph.AdjToVariable(a)) })
+
+		Convey("A block sampler draws from the exact joint and mixes well", func() {
+			var (
+				model    = []GibbsSample{NewBlockSample(graph, NewBlockSampler(a, b))}
+				recorder = NewRecorder(a, b)
+			)
+			So(model[0].Variable, ShouldEqual, a)
+			So(model[0].Factors, ShouldHaveLength, 2)
+			So(Sample(model, 100, 1, 20000, recorder), ShouldBeNil)
+			for i, p := range jointFrequencies(recorder) {
+				So(p, ShouldAlmostEqual, want[i], 0.02)
+			}
+			So(Autocorrelation(recorder.Traces[0], 1)[1], ShouldBeLessThan, 0.1)
+		})
+
+		Convey("Sampling one variable at a time mixes slowly", func() {
+			var (
+				model = []GibbsSample{
+					{Variable: a, Factors: graph.AdjToVariable(a), Sampler: ProdValueSampler{}},
+					{Variable: b, Factors: graph.AdjToVariable(b), Sampler: ProdValueSampler{}},
+				}
+				recorder = NewRecorder(a, b)
+			)
+			So(Sample(model, 100, 1, 20000, recorder), ShouldBeNil)
+			So(Autocorrelation(recorder.Traces[0], 1)[1], ShouldBeGreaterThan, 0.5)
+		})
+
+		Convey("A block is sampled while its first variable is observed", func() {
+			a.Observe(1)
+			var (
+				model    = []GibbsSample{NewBlockSample(graph, NewBlockSampler(a, b))}
+				recorder = NewRecorder(a, b)
+			)
+			So(Sample(model, 10, 1, 2000, recorder), ShouldBeNil)
+			var ones float64
+			for _, x := range recorder.Traces[1] {
+				ones += x
+			}
+			So(ones/2000, ShouldAlmostEqual, 50.0/51, 0.02)
+
+			b.Observe(1)
+			So(isLatent(model[0]), ShouldBeFalse)
+		})
+
+		Convey("Observed variables in the block keep their values", func() {
+			b.Observe(0)
+			var sampler = NewBlockSampler(a, b)
+			for i := 0; i < 100; i++ {
+				sampler.SampleValue(a, graph.AdjToVariable(a))
+				So(b.Outcome(), ShouldEqual, 0)
+			}
+		})
+	})
+}
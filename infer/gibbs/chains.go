@@ -0,0 +1,216 @@
+package gibbs
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"math/rand"
+	"reflect"
+	"sync"
+)
+
+// A ValueSampler which can draw from a given source of randomness, so that
+// chains can be seeded independently and reproducibly. Samplers which do not
+// implement it draw from the global source, which is safe to share between
+// chains but not reproducible.
+type RandValueSampler interface {
+	ValueSampler
+	SampleValueRand(v variable.RandomVariable, factors []factor.Factor, rng *rand.Rand)
+}
+
+// Builds the model for one chain. This is how each chain gets deep-copied
+// variable state: the builder is called once per chain, and must create new
+// latent variables, with new factors and samplers over them, so that chains
+// can run in parallel without sharing state. Observed variables may be
+// shared, since sampling does not change them. The model for every chain must
+// list the corresponding variables in the same order.
+type ModelBuilder func(chain int) []GibbsSample
+
+// Options for running several Gibbs chains
+type ChainOptions struct {
+
+	// The number of chains, which run in parallel
+	Chains int
+
+	// The number of rounds to discard at the start of each chain
+	Burnin int
+
+	// The number of rounds per recorded draw. Values below one count as one.
+	Thinning int
+
+	// The number of draws to record from each chain
+	Samples int
+
+	// The seed for the first chain. Chain i uses Seed+i. If zero, seeds are
+	// drawn from the global source.
+	Seed int64
+
+	// Whether to start each latent discrete variable at a uniformly random
+	// outcome, so chains start far apart. Otherwise variables start at the
+	// values the builder gives them.
+	RandomInit bool
+
+	// The number of autocorrelation lags to report
+	MaxLag int
+
+	// A variable is flagged as not converged if its split R-hat is above
+	// MaxRHat, or its effective sample size is below MinESS
+	MaxRHat float64
+	MinESS  float64
+}
+
+// Get the default options for running several Gibbs chains
+func DefaultChainOptions() ChainOptions {
+	return ChainOptions{
+		Chains:   4,
+		Burnin:   500,
+		Thinning: 1,
+		Samples:  1000,
+		MaxLag:   20,
+		MaxRHat:  1.01,
+		MinESS:   400,
+	}
+}
+
+// The result of running several Gibbs chains
+type ChainResult struct {
+
+	// The model of each chain, with variables at their final values
+	Models [][]GibbsSample
+
+	// The recorded values of each variable in each chain, indexed by chain,
+	// then by position in the model, then by draw. Observed variables are
+	// recorded too.
+	Traces [][][]float64
+
+	// Convergence diagnostics for each variable, by position in the model.
+	// Observed variables have none.
+	Diagnostics []*Diagnostics
+
+	// The positions in the model of the variables which are flagged as not
+	// converged
+	NotConverged []int
+}
+
+// Ask whether every latent variable is judged to have converged
+func (result ChainResult) Converged() bool {
+	return len(result.NotConverged) == 0
+}
+
+// Run several Gibbs chains in parallel goroutines, each with its own model
+// and source of randomness, and compute convergence diagnostics for each
+// latent variable from the recorded draws. Returns an error if the builder
+// gives two chains the same latent variable, a factor adjacent to the same
+// latent variable, or the same sampler pointer.
+func InferChains(build ModelBuilder, opts ChainOptions) (*ChainResult, error) {
+	if opts.Chains < 1 || opts.Samples < 1 {
+		return nil, stats.Errorf("Need at least one chain and one sample, not %d and %d",
+			opts.Chains, opts.Samples)
+	}
+	var result = &ChainResult{
+		Models: make([][]GibbsSample, opts.Chains),
+		Traces: make([][][]float64, opts.Chains),
+	}
+	var owners = make(map[interface{}]int)
+	for c := range result.Models {
+		result.Models[c] = build(c)
+		if len(result.Models[c]) != len(result.Models[0]) {
+			return nil, stats.Errorf("Chain %d has %d variable(s), but chain 0 has %d",
+				c, len(result.Models[c]), len(result.Models[0]))
+		}
+		for _, s := range result.Models[c] {
+			var shared = []interface{}{s.Variable}
+			for _, f := range s.Factors {
+				for _, v := range f.Adjacent() {
+					if !variable.IsObserved(v) {
+						shared = append(shared, v)
+					}
+				}
+			}
+			if s.Sampler != nil && reflect.TypeOf(s.Sampler).Kind() == reflect.Ptr {
+				shared = append(shared, s.Sampler)
+			}
+			for _, item := range shared {
+				if other, ok := owners[item]; ok && other != c {
+					return nil, stats.Errorf("Chains %d and %d share %v", other, c, item)
+				}
+				owners[item] = c
+			}
+		}
+	}
+
+	var wait sync.WaitGroup
+	for c := range result.Models {
+		var seed = opts.Seed + int64(c)
+		if opts.Seed == 0 {
+			seed = rand.Int63()
+		}
+		wait.Add(1)
+		go func(c int, rng *rand.Rand) {
+			defer wait.Done()
+			result.Traces[c] = runChain(result.Models[c], opts, rng)
+		}(c, rand.New(rand.NewSource(seed)))
+	}
+	wait.Wait()
+
+	result.Diagnostics = make([]*Diagnostics, len(result.Models[0]))
+	for i, s := range result.Models[0] {
+		if variable.IsObserved(s.Variable) {
+			continue
+		}
+		var chains = make([][]float64, opts.Chains)
+		for c := range chains {
+			chains[c] = result.Traces[c][i]
+		}
+		var diag = Diagnose(chains, opts.MaxLag)
+		diag.Converged = diag.RHat <= opts.MaxRHat && diag.ESS >= opts.MinESS
+		result.Diagnostics[i] = diag
+		if !diag.Converged {
+			result.NotConverged = append(result.NotConverged, i)
+		}
+	}
+	return result, nil
+}
+
+// Run one chain, returning the recorded values of each variable
+func runChain(model []GibbsSample, opts ChainOptions, rng *rand.Rand) [][]float64 {
+	if opts.RandomInit {
+		for _, s := range model {
+			if dv, ok := s.Variable.(*variable.DiscreteRV); ok && !dv.IsObserved() &&
+				dv.Space().Size() > 0 {
+				dv.SetOutcome(dist.Outcome(rng.Intn(dv.Space().Size())))
+			}
+		}
+	}
+	for r := 0; r < opts.Burnin; r++ {
+		sweep(model, rng)
+	}
+	var traces = make([][]float64, len(model))
+	for i := range traces {
+		traces[i] = make([]float64, opts.Samples)
+	}
+	for n := 0; n < opts.Samples; n++ {
+		for r := 0; r < opts.Thinning || r == 0; r++ {
+			sweep(model, rng)
+		}
+		for i, s := range model {
+			traces[i][n] = s.Variable.Val()
+		}
+	}
+	return traces
+}
+
+// Sample each latent variable once, in model order, drawing from rng if it is
+// not nil and the sampler supports it
+func sweep(model []GibbsSample, rng *rand.Rand) {
+	for _, s := range model {
+		if !isLatent(s) {
+			continue
+		} else if rs, ok := s.Sampler.(RandValueSampler); ok && rng != nil {
+			rs.SampleValueRand(s.Variable, s.Factors, rng)
+		} else {
+			s.Sampler.SampleValue(s.Variable, s.Factors)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package gibbs
+
+import (
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+// Build a model over two Boolean variables with a joint table, under which
+// P(a) = 0.7 and P(b) = 0.6
+func twoVarModel(int) []GibbsSample {
+	var (
+		a     = variable.NewDiscreteRV(0, dist.BooleanSpace)
+		b     = variable.NewDiscreteRV(0, dist.BooleanSpace)
+		table = factor.NewTableFactor([]*variable.DiscreteRV{a, b}, []float64{1, 2, 3, 4})
+	)
+	return []GibbsSample{
+		{Variable: a, Factors: []factor.Factor{table}, Sampler: ProdValueSampler{}},
+		{Variable: b, Factors: []factor.Factor{table}, Sampler: ProdValueSampler{}},
+	}
+}
+
+func TestInferChains(t *testing.T) {
+	Convey("Given several chains over a simple model", t, func() {
+		var opts = DefaultChainOptions()
+		opts.Seed = 11
+		opts.RandomInit = true
+		result, err := InferChains(twoVarModel, opts)
+		So(err, ShouldBeNil)
+
+		Convey("Each chain has its own variables and traces", func() {
+			So(result.Models, ShouldHaveLength, 4)
+			So(result.Models[0][0].Variable, ShouldNotEqual, result.Models[1][0].Variable)
+			So(result.Traces, ShouldHaveLength, 4)
+			So(result.Traces[0], ShouldHaveLength, 2)
+			So(result.Traces[0][0], ShouldHaveLength, opts.Samples)
+		})
+
+		Convey("The chains converge to the right marginals", func() {
+			So(result.Converged(), ShouldBeTrue)
+			for i, want := range []float64{0.7, 0.6} {
+				var mean float64
+				for c := range result.Traces {
+					for _, x := range result.Traces[c][i] {
+						mean += x
+					}
+				}
+				mean /= float64(opts.Chains * opts.Samples)
+				So(mean, ShouldAlmostEqual, want, 0.03)
+				So(result.Diagnostics[i].Converged, ShouldBeTrue)
+				So(result.Diagnostics[i].Geweke, ShouldHaveLength, 4)
+				So(result.Diagnostics[i].Autocorr, ShouldHaveLength, opts.MaxLag+1)
+			}
+		})
+
+		Convey("Seeded runs are reproducible", func() {
+			again, err := InferChains(twoVarModel, opts)
+			So(err, ShouldBeNil)
+			So(again.Traces, ShouldResemble, result.Traces)
+		})
+
+		Convey("Too few draws are flagged", func() {
+			opts.Samples = 20
+			result, err := InferChains(twoVarModel, opts)
+			So(err, ShouldBeNil)
+			So(result.Converged(), ShouldBeFalse)
+			So(result.NotConverged, ShouldResemble, []int{0, 1})
+		})
+	})
+
+	Convey("Chains may not share variables", t, func() {
+		var model = twoVarModel(0)
+		_, err := InferChains(func(int) []GibbsSample { return model }, DefaultChainOptions())
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Chains may not share stateful samplers", t, func() {
+		var sampler = NewSliceSampler(1)
+		_, err := InferChains(func(c int) []GibbsSample {
+			var model = twoVarModel(c)
+			model[0].Sampler = sampler
+			return model
+		}, DefaultChainOptions())
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Every built-in sampler draws from the chain's source", t, func() {
+		var opts = DefaultChainOptions()
+		opts.Chains, opts.Burnin, opts.Samples, opts.Seed = 2, 10, 50, 5
+		var build = func(int) []GibbsSample {
+			var (
+				x     = variable.NewContinuousRV(0, dist.AllRealSpace)
+				y     = variable.NewContinuousRV(0, dist.AllRealSpace)
+				z     = variable.NewDiscreteRV(0, dist.BooleanSpace)
+				mu    = variable.NewContinuousRV(1, dist.AllRealSpace)
+				sigma = variable.NewContinuousRV(2, dist.PositiveRealSpace)
+				prior = factor.NewDistFactor([]variable.RandomVariable{x, mu, sigma},
+					dist.NewStandardNormalDist())
+			)
+			mu.Observe(1)
+			sigma.Observe(2)
+			return []GibbsSample{
+				{Variable: x, Factors: []factor.Factor{prior},
+					Sampler: NewIndependenceSampler(dist.NewGammaDist(2, 1))},
+				{Variable: y, Sampler: DistSampler{Dist: dist.NewBetaDist(2, 3)}},
+				{Variable: z, Sampler: DistSampler{Dist: dist.NewBernoulliDist(0.3)}},
+			}
+		}
+		first, err := InferChains(build, opts)
+		So(err, ShouldBeNil)
+		again, err := InferChains(build, opts)
+		So(err, ShouldBeNil)
+		So(again.Traces, ShouldResemble, first.Traces)
+	})
+
+	Convey("Observed variables are recorded but not diagnosed", t, func() {
+		var opts = DefaultChainOptions()
+		opts.Chains, opts.Samples = 2, 100
+		result, err := InferChains(func(c int) []GibbsSample {
+			var model = twoVarModel(c)
+			model[1].Variable.(*variable.DiscreteRV).Observe(1)
+			return model
+		}, opts)
+		So(err, ShouldBeNil)
+		So(result.Diagnostics[1], ShouldBeNil)
+		So(result.Traces[1][1][0], ShouldEqual, 1)
+	})
+}
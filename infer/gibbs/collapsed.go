@@ -0,0 +1,170 @@
+package gibbs
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"math"
+	"math/rand"
+)
+
+// A probability with a Beta prior which is integrated out of the factors that
+// depend on it. Each factor must score Rate if its event happened, and
+// 1 - Rate otherwise, like a BSCFactor whose event is a flipped input. The
+// rate is then replaced by the numbers of factors whose event did and did not
+// happen, so that the variables the rate couples can be sampled without
+// waiting for the rate to move. Factors whose score mixes several rates, such
+// as a BSCPairFactor, cannot be collapsed this way.
+//
+// The counts are updated as the collapsed samplers change variables. If
+// variables adjacent to the factors are changed some other way, call
+// Refresh() before sampling again.
+type CollapsedBeta struct {
+
+	// The rate integrated out. It is not sampled while collapsed, and should
+	// not be in the model; call SampleRate() to draw it from its posterior.
+	Rate *variable.ContinuousRV
+
+	// The parameters of the Beta prior on the rate
+	Alpha, Beta float64
+
+	// The factors which depend on the rate
+	Factors []factor.Factor
+
+	// Whether a factor's event happened, given its variables' current values
+	Event func(f factor.Factor) bool
+
+	// The numbers of factors whose event did and did not happen
+	Successes, Failures int
+
+	events map[factor.Factor]bool
+	byVar  map[variable.RandomVariable][]factor.Factor
+}
+
+// Collapse a rate with a Beta(alpha, beta) prior out of the factors which
+// depend on it, counting their events at the variables' current values
+func NewCollapsedBeta(rate *variable.ContinuousRV, alpha, beta float64,
+	factors []factor.Factor, event func(f factor.Factor) bool) *CollapsedBeta {
+
+	var c = &CollapsedBeta{
+		Rate:    rate,
+		Alpha:   alpha,
+		Beta:    beta,
+		Factors: factors,
+		Event:   event,
+		byVar:   make(map[variable.RandomVariable][]factor.Factor),
+	}
+	for _, f := range factors {
+		for _, v := range f.Adjacent() {
+			if v != variable.RandomVariable(rate) {
+				c.byVar[v] = append(c.byVar[v], f)
+			}
+		}
+	}
+	c.Refresh()
+	return c
+}
+
+// Recount the events at the variables' current values
+func (c *CollapsedBeta) Refresh() {
+	c.events = make(map[factor.Factor]bool, len(c.Factors))
+	c.Successes, c.Failures = 0, 0
+	for _, f := range c.Factors {
+		c.add(f)
+	}
+}
+
+// Get the posterior distribution of the rate given the counts
+func (c CollapsedBeta) Posterior() *dist.Beta {
+	return dist.NewBetaDist(c.Alpha, c.Beta).Posterior(float64(c.Successes), float64(c.Failures))
+}
+
+// Set the rate to a draw from its posterior given the counts
+func (c *CollapsedBeta) SampleRate() {
+	c.Rate.Set(c.Posterior().Sample())
+}
+
+// Get the log of the product of the factors' scores, with the rate
+// integrated out under its prior
+func (c CollapsedBeta) LogMarginal() float64 {
+	return c.logMarginal(c.Successes, c.Failures) - c.logMarginal(0, 0)
+}
+
+// Get the log of the Beta function B(Alpha + successes, Beta + failures)
+func (c CollapsedBeta) logMarginal(successes, failures int) float64 {
+	var (
+		a, _ = math.Lgamma(c.Alpha + float64(successes))
+		b, _ = math.Lgamma(c.Beta + float64(failures))
+		n, _ = math.Lgamma(c.Alpha + c.Beta + float64(successes+failures))
+	)
+	return a + b - n
+}
+
+// Get the factors adjacent to any of the variables, without repeats
+func (c CollapsedBeta) touching(vars []*variable.DiscreteRV) []factor.Factor {
+	var (
+		touched []factor.Factor
+		seen    = make(map[factor.Factor]bool)
+	)
+	for _, v := range vars {
+		for _, f := range c.byVar[v] {
+			if !seen[f] {
+				seen[f] = true
+				touched = append(touched, f)
+			}
+		}
+	}
+	return touched
+}
+
+// Count a factor's event at the variables' current values
+func (c *CollapsedBeta) add(f factor.Factor) {
+	var event = c.Event(f)
+	c.events[f] = event
+	if event {
+		c.Successes++
+	} else {
+		c.Failures++
+	}
+}
+
+// Stop counting a factor's event, as it was last counted
+func (c *CollapsedBeta) remove(f factor.Factor) {
+	if c.events[f] {
+		c.Successes--
+	} else {
+		c.Failures--
+	}
+	delete(c.events, f)
+}
+
+// A ValueSampler for discrete variables which scores the factors of collapsed
+// rates by the counts of the other factors' events, and the remaining factors
+// normally. The collapsed factors may be left out of the factors passed in.
+type CollapsedSampler struct {
+	Collapsed []*CollapsedBeta
+}
+
+// Create a sampler which integrates out the given rates
+func NewCollapsedSampler(collapsed ...*CollapsedBeta) *CollapsedSampler {
+	return &CollapsedSampler{Collapsed: collapsed}
+}
+
+func (sampler *CollapsedSampler) SampleValue(v variable.RandomVariable, factors []factor.Factor) {
+	sampleJoint([]*variable.DiscreteRV{discreteVar(v)}, factors, sampler.Collapsed, rand.Float64)
+}
+
+func (sampler *CollapsedSampler) SampleValueRand(v variable.RandomVariable, factors []factor.Factor,
+	rng *rand.Rand) {
+	sampleJoint([]*variable.DiscreteRV{discreteVar(v)}, factors, sampler.Collapsed, rng.Float64)
+}
+
+// Get the discrete variable a sampler was given, or panic
+func discreteVar(v variable.RandomVariable) *variable.DiscreteRV {
+	dv, ok := v.(*variable.DiscreteRV)
+	if !ok {
+		panic(stats.ErrDiscreteOnly)
+	}
+	return dv
+}
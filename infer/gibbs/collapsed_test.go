@@ -0,0 +1,94 @@
+package gibbs
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/channel/bsc"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+// Whether a BSC flipped its input
+func flipped(f factor.Factor) bool {
+	return !f.(*bsc.BSCFactor).OutputMatchesInput()
+}
+
+func TestCollapsedSampler(t *testing.T) {
+	Convey("Given two inputs sent through a BSC with an unknown noise rate", t, func() {
+		var (
+			ch    = bsc.NewBSC(0.5)
+			x1    = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			x2    = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			vars  = []*variable.DiscreteRV{x1, x2}
+			graph = factor.NewFactorGraph()
+		)
+		for i, outputs := range [][]dist.Outcome{{1, 1, 0}, {0, 0, 0, 1}} {
+			for _, o := range outputs {
+				var y = variable.NewDiscreteRV(o, dist.BooleanSpace)
+				y.ObserveOutcome(o)
+				graph.AddFactor(ch.Factor(vars[i], y))
+			}
+		}
+		var (
+			factors = append(append([]factor.Factor(nil), graph.AdjToVariable(x1)...),
+				graph.AdjToVariable(x2)...)
+			noise = NewCollapsedBeta(ch.NoiseRate, 1, 4, factors, flipped)
+			want  = exactJoint(vars, func() float64 {
+				noise.Refresh()
+				return noise.LogMarginal()
+			})
+		)
+		setJoint(vars, 0)
+		noise.Refresh()
+		So(noise.Successes, ShouldEqual, 3)
+		So(noise.Failures, ShouldEqual, 4)
+
+		Convey("Collapsed updates draw from the exact joint of the inputs", func() {
+			var (
+				sampler = NewCollapsedSampler(noise)
+				model   = []GibbsSample{
+					{Variable: x1, Factors: graph.AdjToVariable(x1), Sampler: sampler},
+					{Variable: x2, Factors: graph.AdjToVariable(x2), Sampler: sampler},
+				}
+				recorder = NewRecorder(x1, x2)
+			)
+			So(Sample(model, 100, 1, 20000, recorder), ShouldBeNil)
+			for i, p := range jointFrequencies(recorder) {
+				So(p, ShouldAlmostEqual, want[i], 0.02)
+			}
+
+			// The counts track the sampled values
+			var successes, failures = noise.Successes, noise.Failures
+			noise.Refresh()
+			So(noise.Successes, ShouldEqual, successes)
+			So(noise.Failures, ShouldEqual, failures)
+		})
+
+		Convey("Collapsed block updates draw from the exact joint of the inputs", func() {
+			var (
+				sampler  = &BlockSampler{Vars: vars, Collapsed: []*CollapsedBeta{noise}}
+				model    = []GibbsSample{NewBlockSample(graph, sampler)}
+				recorder = NewRecorder(x1, x2)
+			)
+			So(Sample(model, 100, 1, 20000, recorder), ShouldBeNil)
+			for i, p := range jointFrequencies(recorder) {
+				So(p, ShouldAlmostEqual, want[i], 0.02)
+			}
+		})
+
+		Convey("The rate can be drawn from its posterior", func() {
+			var posterior = noise.Posterior()
+			So(posterior.Alpha, ShouldEqual, 4)
+			So(posterior.Beta, ShouldEqual, 8)
+			noise.SampleRate()
+			So(ch.NoiseRate.Val(), ShouldBeBetween, 0, 1)
+		})
+
+		Convey("Only discrete variables can be sampled", func() {
+			So(func() { NewCollapsedSampler(noise).SampleValue(ch.NoiseRate, nil) },
+				ShouldPanicWith, stats.ErrDiscreteOnly)
+		})
+	})
+}
@@ -0,0 +1,265 @@
+package gibbs
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"math"
+	"math/rand"
+)
+
+// A source of random numbers: either a *rand.Rand, or the global source
+type randSource interface {
+	Float64() float64
+	NormFloat64() float64
+	ExpFloat64() float64
+}
+
+// The global source of random numbers
+type globalRand struct{}
+
+func (globalRand) Float64() float64     { return rand.Float64() }
+func (globalRand) NormFloat64() float64 { return rand.NormFloat64() }
+func (globalRand) ExpFloat64() float64  { return rand.ExpFloat64() }
+
+// Get the continuous variable a sampler was given, or panic
+func continuousVar(v variable.RandomVariable) *variable.ContinuousRV {
+	cv, ok := v.(*variable.ContinuousRV)
+	if !ok {
+		panic(stats.ErrContinuousOnly)
+	}
+	return cv
+}
+
+// Get the log of the product of the factors with the variable set to a
+// value, or -Inf if the value is outside the variable's space. The variable
+// is left at the value if it is in the space.
+func logDensity(v *variable.ContinuousRV, factors []factor.Factor, val float64) float64 {
+	if !v.Space().Contains(val) {
+		return math.Inf(-1)
+	}
+	v.Set(val)
+	return logScore(factors)
+}
+
+// Get the log of the product of the factors' current scores
+func logScore(factors []factor.Factor) float64 {
+	var logp float64
+	for _, f := range factors {
+		if lf, ok := f.(interface {
+			LogScore() float64
+		}); ok {
+			logp += lf.LogScore()
+		} else {
+			logp += math.Log(f.Score())
+		}
+	}
+	return logp
+}
+
+// A ValueSampler for continuous variables which proposes a move from the
+// current value by a normally distributed step, and accepts it with the
+// Metropolis-Hastings probability under the product of the factors. Moves
+// outside the variable's space are rejected. The step size can adapt toward
+// a target acceptance rate during burn-in.
+type RandomWalkSampler struct {
+
+	// The standard deviation of the proposed steps
+	Step float64
+
+	// The number of calls during which to adapt the step size, usually the
+	// number of burn-in rounds
+	AdaptFor int
+
+	// The acceptance rate to adapt toward. If zero, 0.44 is used, which is
+	// near optimal for one-dimensional targets.
+	TargetRate float64
+
+	// The number of calls and accepted moves since adaptation ended
+	Calls, Accepted int
+
+	adapted int
+}
+
+// Create a random walk sampler with an initial step size, which adapts the
+// step size for the given number of calls
+func NewRandomWalkSampler(step float64, adaptFor int) *RandomWalkSampler {
+	return &RandomWalkSampler{Step: step, AdaptFor: adaptFor}
+}
+
+// Get the fraction of moves accepted since adaptation ended
+func (sampler RandomWalkSampler) AcceptanceRate() float64 {
+	return float64(sampler.Accepted) / float64(sampler.Calls)
+}
+
+func (sampler *RandomWalkSampler) SampleValue(v variable.RandomVariable, factors []factor.Factor) {
+	sampler.sample(continuousVar(v), factors, globalRand{})
+}
+
+func (sampler *RandomWalkSampler) SampleValueRand(v variable.RandomVariable, factors []factor.Factor,
+	rng *rand.Rand) {
+	sampler.sample(continuousVar(v), factors, rng)
+}
+
+// Propose and accept or reject one move
+func (sampler *RandomWalkSampler) sample(v *variable.ContinuousRV, factors []factor.Factor,
+	rng randSource) {
+
+	var (
+		current  = v.Val()
+		logp     = logDensity(v, factors, current)
+		proposal = current + sampler.Step*rng.NormFloat64()
+		accepted = math.Log(rng.Float64()) < logDensity(v, factors, proposal)-logp
+	)
+	if !accepted {
+		v.Set(current)
+	}
+
+	// Robbins-Monro adaptation of the log step size, with decreasing gain
+	if sampler.adapted < sampler.AdaptFor {
+		sampler.adapted++
+		var target = sampler.TargetRate
+		if target == 0 {
+			target = 0.44
+		}
+		var rate float64
+		if accepted {
+			rate = 1
+		}
+		sampler.Step *= math.Exp((rate - target) / math.Sqrt(float64(sampler.adapted)))
+		return
+	}
+	sampler.Calls++
+	if accepted {
+		sampler.Accepted++
+	}
+}
+
+// A ValueSampler for continuous variables which proposes values independently
+// of the current value, from a fixed distribution, and accepts them with the
+// Metropolis-Hastings probability. This mixes well when the proposal is close
+// to the conditional distribution and has heavier tails. Under
+// SampleValueRand(), proposals are drawn from the given source if the
+// proposal is a dist.ContinuousRandSampler, as the built-in distributions
+// are. Proposals outside the variable's space are rejected.
+type IndependenceSampler struct {
+	Proposal dist.ContinuousDist
+
+	// The number of calls and accepted proposals
+	Calls, Accepted int
+}
+
+// Create an independence sampler with a proposal distribution
+func NewIndependenceSampler(proposal dist.ContinuousDist) *IndependenceSampler {
+	return &IndependenceSampler{Proposal: proposal}
+}
+
+// Get the fraction of proposals accepted
+func (sampler IndependenceSampler) AcceptanceRate() float64 {
+	return float64(sampler.Accepted) / float64(sampler.Calls)
+}
+
+func (sampler *IndependenceSampler) SampleValue(v variable.RandomVariable, factors []factor.Factor) {
+	var cv = continuousVar(v)
+	sampler.sample(cv, factors, sampler.Proposal.Sample(), globalRand{})
+}
+
+func (sampler *IndependenceSampler) SampleValueRand(v variable.RandomVariable, factors []factor.Factor,
+	rng *rand.Rand) {
+	var cv = continuousVar(v)
+	sampler.sample(cv, factors, dist.SampleContinuousRand(sampler.Proposal, rng), rng)
+}
+
+// Accept or reject a proposed value
+func (sampler *IndependenceSampler) sample(v *variable.ContinuousRV, factors []factor.Factor,
+	proposal float64, rng randSource) {
+
+	var (
+		current = v.Val()
+		logp    = logDensity(v, factors, current)
+		ratio   = logDensity(v, factors, proposal) - logp +
+			math.Log(sampler.Proposal.PDF(current)) - math.Log(sampler.Proposal.PDF(proposal))
+	)
+	sampler.Calls++
+	if math.Log(rng.Float64()) < ratio {
+		sampler.Accepted++
+	} else {
+		v.Set(current)
+	}
+}
+
+// A ValueSampler for continuous variables which draws a new value uniformly
+// from the slice of values where the product of the factors is above a
+// random level below its current value. The slice is found by stepping out
+// from the current value, and shrinking toward it when a draw falls outside.
+// See Neal, "Slice Sampling," The Annals of Statistics, 2003. The slice is
+// limited to the variable's space.
+type SliceSampler struct {
+
+	// The size of each step when stepping out
+	Width float64
+
+	// The most steps to take when stepping out
+	MaxSteps int
+}
+
+// Create a slice sampler with a step width, taking at most 50 steps
+func NewSliceSampler(width float64) *SliceSampler {
+	return &SliceSampler{Width: width, MaxSteps: 50}
+}
+
+func (sampler *SliceSampler) SampleValue(v variable.RandomVariable, factors []factor.Factor) {
+	sampler.sample(continuousVar(v), factors, globalRand{})
+}
+
+func (sampler *SliceSampler) SampleValueRand(v variable.RandomVariable, factors []factor.Factor,
+	rng *rand.Rand) {
+	sampler.sample(continuousVar(v), factors, rng)
+}
+
+// Draw one value. If the current value has zero density, it is unchanged.
+func (sampler *SliceSampler) sample(v *variable.ContinuousRV, factors []factor.Factor,
+	rng randSource) {
+
+	var (
+		x0    = v.Val()
+		level = logDensity(v, factors, x0) - rng.ExpFloat64()
+		inf   = v.Space().Inf()
+		sup   = v.Space().Sup()
+	)
+	if math.IsInf(level, -1) || math.IsNaN(level) {
+		v.Set(x0)
+		return
+	}
+
+	// Step out until both ends are outside the slice
+	var (
+		left  = x0 - sampler.Width*rng.Float64()
+		right = left + sampler.Width
+		j     = int(float64(sampler.MaxSteps) * rng.Float64())
+		k     = sampler.MaxSteps - 1 - j
+	)
+	for ; j > 0 && left > inf && logDensity(v, factors, left) > level; j-- {
+		left -= sampler.Width
+	}
+	for ; k > 0 && right < sup && logDensity(v, factors, right) > level; k-- {
+		right += sampler.Width
+	}
+	left, right = math.Max(left, inf), math.Min(right, sup)
+
+	// Draw from the interval, shrinking it toward x0 on each miss
+	for {
+		var x1 = left + rng.Float64()*(right-left)
+		if logDensity(v, factors, x1) > level {
+			return
+		} else if x1 == x0 || right-left < 1e-12*math.Max(1, math.Abs(x0)) {
+			v.Set(x0)
+			return
+		} else if x1 < x0 {
+			left = x1
+		} else {
+			right = x1
+		}
+	}
+}
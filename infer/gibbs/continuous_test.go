@@ -0,0 +1,104 @@
+package gibbs
+
+import (
+	"fmt"
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"testing"
+)
+
+// Create a factor scoring v under a distribution with observed parameters
+func distFactor(v variable.RandomVariable, d dist.Dist, params ...float64) factor.Factor {
+	var vars = []variable.RandomVariable{v}
+	for _, p := range params {
+		var param = variable.NewContinuousRV(p, dist.AllRealSpace)
+		param.Observe(p)
+		vars = append(vars, param)
+	}
+	return factor.NewDistFactor(vars, d)
+}
+
+// Draw values with a sampler, returning their mean and variance, and
+// asserting that they stay in the variable's space
+func drawMoments(sampler ValueSampler, v *variable.ContinuousRV, factors []factor.Factor,
+	n int) (float64, float64) {
+
+	var (
+		draws = make([]float64, n)
+		rng   = rand.New(rand.NewSource(3))
+	)
+	for i := 0; i < 500; i++ {
+		sampler.(RandValueSampler).SampleValueRand(v, factors, rng)
+	}
+	var outside int
+	for i := range draws {
+		sampler.(RandValueSampler).SampleValueRand(v, factors, rng)
+		draws[i] = v.Val()
+		if !v.Space().Contains(draws[i]) {
+			outside++
+		}
+	}
+	So(outside, ShouldEqual, 0)
+	return meanVar(draws)
+}
+
+func TestContinuousSamplers(t *testing.T) {
+	var samplers = map[string]func() ValueSampler{
+		"random walk":  func() ValueSampler { return NewRandomWalkSampler(1, 500) },
+		"slice":        func() ValueSampler { return NewSliceSampler(0.5) },
+		"independence": func() ValueSampler { return NewIndependenceSampler(dist.NewBetaDist(2, 2)) },
+	}
+	for _, name := range []string{"random walk", "slice", "independence"} {
+		var newSampler = samplers[name]
+
+		Convey(fmt.Sprintf("The %s sampler draws from a bounded target", name), t, func() {
+			// Beta(3, 2) has mean 0.6 and variance 0.04
+			var (
+				v       = variable.NewContinuousRV(0.5, dist.UnitIntervalSpace)
+				factors = []factor.Factor{distFactor(v, dist.NewBetaDist(1, 1), 3, 2)}
+			)
+			mean, variance := drawMoments(newSampler(), v, factors, 20000)
+			So(mean, ShouldAlmostEqual, 0.6, 0.015)
+			So(variance, ShouldAlmostEqual, 0.04, 0.005)
+		})
+
+		if name == "independence" {
+			continue
+		}
+		Convey(fmt.Sprintf("The %s sampler respects the variable's space", name), t, func() {
+			// A standard normal truncated to positive values has mean
+			// sqrt(2/pi) and variance 1-2/pi
+			var (
+				v       = variable.NewContinuousRV(1, dist.PositiveRealSpace)
+				factors = []factor.Factor{distFactor(v, dist.NewNormalDist(0, 1), 0, 1)}
+			)
+			mean, variance := drawMoments(newSampler(), v, factors, 20000)
+			So(mean, ShouldAlmostEqual, 0.7979, 0.03)
+			So(variance, ShouldAlmostEqual, 0.3634, 0.03)
+		})
+	}
+
+	Convey("The random walk step size adapts during burn-in", t, func() {
+		var (
+			v       = variable.NewContinuousRV(0, dist.AllRealSpace)
+			factors = []factor.Factor{distFactor(v, dist.NewNormalDist(0, 1), 0, 1)}
+			sampler = NewRandomWalkSampler(100, 2000)
+			rng     = rand.New(rand.NewSource(5))
+		)
+		for i := 0; i < 4000; i++ {
+			sampler.SampleValueRand(v, factors, rng)
+		}
+		So(sampler.Step, ShouldBeBetween, 1, 5)
+		So(sampler.Calls, ShouldEqual, 2000)
+		So(sampler.AcceptanceRate(), ShouldBeBetween, 0.34, 0.54)
+	})
+
+	Convey("Continuous samplers reject discrete variables", t, func() {
+		var v = variable.NewDiscreteRV(0, dist.BooleanSpace)
+		So(func() { NewSliceSampler(1).SampleValue(v, nil) }, ShouldPanicWith, stats.ErrContinuousOnly)
+	})
+}
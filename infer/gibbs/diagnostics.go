@@ -0,0 +1,253 @@
+package gibbs
+
+import (
+	"math"
+)
+
+// Convergence diagnostics for one variable across several chains
+type Diagnostics struct {
+
+	// The split R-hat statistic: the square root of the ratio of the
+	// estimated posterior variance to the mean variance within each half
+	// chain. Values near one suggest the chains have mixed.
+	RHat float64
+
+	// The effective sample size: the number of independent draws with the
+	// same estimation error as the correlated draws
+	ESS float64
+
+	// The autocorrelation at lags zero to MaxLag, averaged over chains
+	Autocorr []float64
+
+	// The Geweke z-score of each chain, comparing the means of its first 10%
+	// and last 50%. Values far from zero suggest the chain has not reached
+	// its stationary distribution.
+	Geweke []float64
+
+	// Whether the variable passed the checks in the chain options
+	Converged bool
+}
+
+// Compute diagnostics for draws of one variable from several chains of equal
+// length. Converged is left false; the caller decides what passes.
+func Diagnose(chains [][]float64, maxLag int) *Diagnostics {
+	if maxLag < 0 {
+		maxLag = 0
+	}
+	var diag = &Diagnostics{
+		RHat:     SplitRHat(chains),
+		ESS:      EffectiveSampleSize(chains),
+		Autocorr: make([]float64, maxLag+1),
+		Geweke:   make([]float64, len(chains)),
+	}
+	for c, x := range chains {
+		for lag, rho := range Autocorrelation(x, maxLag) {
+			diag.Autocorr[lag] += rho / float64(len(chains))
+		}
+		diag.Geweke[c] = GewekeZ(x)
+	}
+	return diag
+}
+
+// Compute the split R-hat statistic for draws from several chains of equal
+// length. Each chain is split in half, so that a chain which drifts is
+// flagged even on its own. Returns 1 if every draw is the same, and NaN if
+// the chains are too short.
+func SplitRHat(chains [][]float64) float64 {
+	var (
+		halves     = splitChains(chains)
+		w, varPlus = chainVariances(halves)
+	)
+	if len(halves) == 0 || len(halves[0]) < 2 {
+		return math.NaN()
+	} else if varPlus == 0 {
+		return 1
+	}
+	return math.Sqrt(varPlus / w)
+}
+
+// Estimate the effective sample size of draws from several chains of equal
+// length, from the autocorrelations of the split chains, truncating the sum
+// of autocorrelations with Geyer's initial positive sequence. Returns the
+// number of draws if every draw is the same.
+func EffectiveSampleSize(chains [][]float64) float64 {
+	return ess(splitChains(chains))
+}
+
+// Estimate the effective sample size of draws from several chains of equal
+// length, without splitting them
+func ess(chains [][]float64) float64 {
+	if len(chains) == 0 || len(chains[0]) < 2 {
+		return math.NaN()
+	}
+	var (
+		m, n       = len(chains), len(chains[0])
+		total      = float64(m * n)
+		w, varPlus = chainVariances(chains)
+		means      = make([]float64, m)
+	)
+	if varPlus == 0 {
+		return total
+	}
+	for c, x := range chains {
+		means[c], _ = meanVar(x)
+	}
+
+	// The autocorrelation at a lag, from the mean autocovariance within the
+	// chains. Lags are computed only as needed, since the sum usually stops
+	// long before the end of the chains.
+	var rho = func(lag int) float64 {
+		var acov float64
+		for c, x := range chains {
+			for t := 0; t+lag < n; t++ {
+				acov += (x[t] - means[c]) * (x[t+lag] - means[c])
+			}
+		}
+		return 1 - (w-acov/total)/varPlus
+	}
+
+	// Sum pairs of autocorrelations while they are positive, keeping the
+	// pair sums non-increasing
+	var (
+		tau  = -1.0
+		prev = math.Inf(1)
+	)
+	for t := 0; t+1 < n; t += 2 {
+		var pair = rho(t) + rho(t+1)
+		if pair <= 0 {
+			break
+		}
+		pair = math.Min(pair, prev)
+		tau += 2 * pair
+		prev = pair
+	}
+	return total / math.Max(tau, 1/math.Log10(total))
+}
+
+// Compute the autocorrelation of a sequence at lags zero to maxLag, or fewer
+// if the sequence is shorter. Returns NaN at every lag if the sequence is
+// constant.
+func Autocorrelation(x []float64, maxLag int) []float64 {
+	var acov = autocovariance(x, maxLag)
+	if len(acov) == 0 {
+		return acov
+	}
+	var variance = acov[0]
+	for lag := range acov {
+		if variance == 0 {
+			acov[lag] = math.NaN()
+		} else {
+			acov[lag] /= variance
+		}
+	}
+	return acov
+}
+
+// Compute the Geweke z-score of a chain: the difference between the means of
+// its first 10% and last 50%, divided by the standard error of the
+// difference. The standard errors allow for autocorrelation. Returns NaN if
+// the chain is too short, and 0 if both parts are constant and equal.
+func GewekeZ(x []float64) float64 {
+	var n = len(x)
+	if n < 20 {
+		return math.NaN()
+	}
+	var (
+		first    = x[:n/10]
+		last     = x[n-n/2:]
+		m1, var1 = meanVarOfMean(first)
+		m2, var2 = meanVarOfMean(last)
+	)
+	if var1+var2 == 0 {
+		if m1 == m2 {
+			return 0
+		}
+		return math.Copysign(math.Inf(1), m1-m2)
+	}
+	return (m1 - m2) / math.Sqrt(var1+var2)
+}
+
+// Get the mean of a sequence, and the variance of that mean allowing for
+// autocorrelation
+func meanVarOfMean(x []float64) (float64, float64) {
+	var mean, v = meanVar(x)
+	if v == 0 {
+		return mean, 0
+	}
+	return mean, v / ess([][]float64{x})
+}
+
+// Split each chain into its first and second halves, dropping the middle
+// draw of chains with odd length. Chains too short to split are kept whole.
+func splitChains(chains [][]float64) [][]float64 {
+	var halves [][]float64
+	for _, x := range chains {
+		if len(x) < 4 {
+			halves = append(halves, x)
+			continue
+		}
+		var half = len(x) / 2
+		halves = append(halves, x[:half], x[len(x)-half:])
+	}
+	return halves
+}
+
+// Get the mean within-chain variance W of several chains of equal length,
+// and the pooled estimate of the posterior variance, which also counts the
+// variance between chain means
+func chainVariances(chains [][]float64) (w, varPlus float64) {
+	if len(chains) == 0 || len(chains[0]) < 2 {
+		return 0, 0
+	}
+	var (
+		m, n  = len(chains), float64(len(chains[0]))
+		means = make([]float64, m)
+	)
+	for c, x := range chains {
+		var v float64
+		means[c], v = meanVar(x)
+		w += v / float64(m)
+	}
+	var between float64
+	if m > 1 {
+		_, between = meanVar(means)
+	}
+	return w, (n-1)/n*w + between
+}
+
+// Get the mean and sample variance (with an n-1 denominator) of a sequence
+func meanVar(x []float64) (mean, variance float64) {
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float64(len(x))
+	if len(x) < 2 {
+		return mean, 0
+	}
+	for _, v := range x {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, variance / float64(len(x)-1)
+}
+
+// Compute the autocovariance of a sequence at lags zero to maxLag, or fewer
+// if the sequence is shorter, with an n denominator
+func autocovariance(x []float64, maxLag int) []float64 {
+	if maxLag >= len(x) {
+		maxLag = len(x) - 1
+	}
+	if maxLag < 0 {
+		return nil
+	}
+	var (
+		mean, _ = meanVar(x)
+		acov    = make([]float64, maxLag+1)
+	)
+	for lag := range acov {
+		for t := 0; t+lag < len(x); t++ {
+			acov[lag] += (x[t] - mean) * (x[t+lag] - mean)
+		}
+		acov[lag] /= float64(len(x))
+	}
+	return acov
+}
@@ -0,0 +1,71 @@
+package gibbs
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Draw chains from an AR(1) process with the given coefficient and
+// stationary variance one, shifting each chain's mean by the given offsets
+func ar1Chains(rng *rand.Rand, phi float64, n int, offsets ...float64) [][]float64 {
+	var chains [][]float64
+	for _, offset := range offsets {
+		var (
+			x     = make([]float64, n)
+			state = rng.NormFloat64()
+		)
+		for t := range x {
+			state = phi*state + math.Sqrt(1-phi*phi)*rng.NormFloat64()
+			x[t] = state + offset
+		}
+		chains = append(chains, x)
+	}
+	return chains
+}
+
+func TestDiagnostics(t *testing.T) {
+	var rng = rand.New(rand.NewSource(7))
+
+	Convey("Independent draws from one distribution pass", t, func() {
+		var diag = Diagnose(ar1Chains(rng, 0, 1000, 0, 0, 0, 0), 5)
+		So(diag.RHat, ShouldBeBetween, 0.99, 1.01)
+		So(diag.ESS, ShouldBeBetween, 3000, 5000)
+		So(diag.Autocorr[0], ShouldAlmostEqual, 1)
+		So(diag.Autocorr[1], ShouldBeBetween, -0.1, 0.1)
+		for _, z := range diag.Geweke {
+			So(math.Abs(z), ShouldBeLessThan, 4)
+		}
+	})
+
+	Convey("Correlated draws have a smaller effective sample size", t, func() {
+		// The autocorrelation time of an AR(1) process is (1+phi)/(1-phi)
+		var diag = Diagnose(ar1Chains(rng, 0.9, 5000, 0, 0, 0, 0), 5)
+		So(diag.RHat, ShouldBeLessThan, 1.05)
+		So(diag.ESS, ShouldBeBetween, 20000/19.0/1.5, 20000/19.0*1.5)
+		So(diag.Autocorr[1], ShouldBeBetween, 0.85, 0.95)
+	})
+
+	Convey("Chains which disagree fail", t, func() {
+		var diag = Diagnose(ar1Chains(rng, 0, 1000, 0, 0, 3), 5)
+		So(diag.RHat, ShouldBeGreaterThan, 1.5)
+	})
+
+	Convey("A drifting chain fails on its own", t, func() {
+		var x = ar1Chains(rng, 0, 1000, 0)[0]
+		for t := range x {
+			x[t] += float64(t) / 100
+		}
+		So(SplitRHat([][]float64{x}), ShouldBeGreaterThan, 1.5)
+		So(GewekeZ(x), ShouldBeLessThan, -4)
+	})
+
+	Convey("Constant chains are handled", t, func() {
+		var chains = [][]float64{{1, 1, 1, 1, 1}, {1, 1, 1, 1, 1}}
+		So(SplitRHat(chains), ShouldEqual, 1)
+		So(EffectiveSampleSize(chains), ShouldEqual, 8)
+		So(math.IsNaN(Autocorrelation(chains[0], 2)[1]), ShouldBeTrue)
+		So(math.IsNaN(GewekeZ(chains[0])), ShouldBeTrue)
+	})
+}
@@ -14,9 +14,10 @@ type GibbsSample struct {
 }
 
 // Select values for all latent variables using Gibbs sampling. We iterate
-// over the model in the provided order. We run `burnin` iterations to allow
-// the model to become calibrated, and then sample each variable in turn with
-// `thinning` full rounds of sampling in between each variable's draw.
+// over the model in the provided order, skipping observed variables, and
+// blocks whose variables are all observed. We run `burnin` iterations to
+// allow the model to become calibrated, and then sample each variable in turn
+// with `thinning` full rounds of sampling in between each variable's draw.
 // Returns the sampled values for all variables, in the same order as specified
 // in `model.`
 func Infer(model []GibbsSample, burnin, thinning int) []variable.RandomVariable {
@@ -42,7 +43,9 @@ func Infer(model []GibbsSample, burnin, thinning int) []variable.RandomVariable
 func gibbsRound(model []GibbsSample, vIdx int) variable.RandomVariable {
 	var output variable.RandomVariable
 	for i, v := range model {
-		v.Sampler.SampleValue(v.Variable, v.Factors)
+		if isLatent(v) {
+			v.Sampler.SampleValue(v.Variable, v.Factors)
+		}
 		if i == vIdx {
 			if cv, ok := v.Variable.(*variable.ContinuousRV); ok {
 				output = variable.NewContinuousRV(cv.Val(), cv.Space())
@@ -29,10 +29,32 @@ func (sampler DistSampler) SampleValue(v variable.RandomVariable, factors []fact
 	}
 }
 
+func (sampler DistSampler) SampleValueRand(v variable.RandomVariable, factors []factor.Factor,
+	rng *rand.Rand) {
+	if dd, ok := sampler.Dist.(dist.DiscreteDist); ok {
+		v.(*variable.DiscreteRV).SetOutcome(dist.SampleDiscreteRand(dd, rng))
+	} else if cd, ok := sampler.Dist.(dist.ContinuousDist); ok {
+		v.(*variable.ContinuousRV).Set(dist.SampleContinuousRand(cd, rng))
+	} else {
+		panic(stats.ErrfUnsupportedDist(sampler.Dist))
+	}
+}
+
 // A ValueSampler which samples discrete values in proportion to the product of all factors.
 type ProdValueSampler struct{}
 
 func (sampler ProdValueSampler) SampleValue(v variable.RandomVariable, factors []factor.Factor) {
+	sampler.sample(v, factors, rand.Float64)
+}
+
+func (sampler ProdValueSampler) SampleValueRand(v variable.RandomVariable, factors []factor.Factor,
+	rng *rand.Rand) {
+	sampler.sample(v, factors, rng.Float64)
+}
+
+// Sample a value, given a source of uniform random numbers in [0, 1)
+func (sampler ProdValueSampler) sample(v variable.RandomVariable, factors []factor.Factor,
+	uniform func() float64) {
 	dv, ok := v.(*variable.DiscreteRV)
 	if !ok {
 		panic(stats.ErrDiscreteOnly)
@@ -50,7 +72,7 @@ func (sampler ProdValueSampler) SampleValue(v variable.RandomVariable, factors [
 		total += props[i]
 	}
 
-	var remaining = rand.Float64() * total
+	var remaining = uniform() * total
 	for i, prop := range props {
 		remaining -= prop
 		if remaining <= 0 {
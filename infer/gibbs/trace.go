@@ -0,0 +1,257 @@
+package gibbs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/variable"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Quantiles are exact up to this many draws, and estimated from a uniform
+// sample of this many draws after that
+const reservoirSize = 10000
+
+// Records draws of selected variables during sampling. It keeps a running
+// summary of each variable, and optionally the full trace in memory and a
+// stream of draws in CSV format, for runs too long to keep in memory.
+type Recorder struct {
+
+	// The recorded variables
+	Vars []variable.RandomVariable
+
+	// The draws of each variable, by position in Vars, if traces are kept
+	Traces [][]float64
+
+	// The running summary of each variable, by position in Vars
+	Summaries []*Summary
+
+	// The number of draws recorded
+	Draws int
+
+	keep bool
+	out  *csv.Writer
+}
+
+// Create a recorder for the given variables which keeps their traces in
+// memory
+func NewRecorder(vars ...variable.RandomVariable) *Recorder {
+	var r = &Recorder{
+		Vars:      vars,
+		Traces:    make([][]float64, len(vars)),
+		Summaries: make([]*Summary, len(vars)),
+		keep:      true,
+	}
+	for i, v := range vars {
+		r.Summaries[i] = newSummary(v)
+	}
+	return r
+}
+
+// Write each draw to w as a CSV row, after a header row of variable names.
+// If keepTraces is false, traces are no longer kept in memory, and only the
+// summaries are available. Must be called before any draws are recorded.
+// Call Flush() when done.
+func (r *Recorder) Stream(w io.Writer, keepTraces bool) error {
+	if r.Draws > 0 {
+		return stats.Errorf("Cannot start streaming after %d draw(s)", r.Draws)
+	}
+	r.out = csv.NewWriter(w)
+	r.keep = keepTraces
+	if !keepTraces {
+		r.Traces = nil
+	}
+	var header = []string{"draw"}
+	for i, v := range r.Vars {
+		if iv, ok := v.(variable.IdentifiedVariable); ok {
+			header = append(header, iv.String())
+		} else {
+			header = append(header, fmt.Sprintf("v%d", i))
+		}
+	}
+	return r.out.Write(header)
+}
+
+// Record the current value of each variable
+func (r *Recorder) Record() error {
+	r.Draws++
+	var row []string
+	if r.out != nil {
+		row = append(row, strconv.Itoa(r.Draws))
+	}
+	for i, v := range r.Vars {
+		var val = v.Val()
+		r.Summaries[i].add(val)
+		if dv, ok := v.(*variable.DiscreteRV); ok {
+			r.Summaries[i].count(dv.Outcome())
+		}
+		if r.keep {
+			r.Traces[i] = append(r.Traces[i], val)
+		}
+		if r.out != nil {
+			row = append(row, strconv.FormatFloat(val, 'g', -1, 64))
+		}
+	}
+	if r.out != nil {
+		return r.out.Write(row)
+	}
+	return nil
+}
+
+// Flush any buffered CSV output
+func (r *Recorder) Flush() error {
+	if r.out == nil {
+		return nil
+	}
+	r.out.Flush()
+	return r.out.Error()
+}
+
+// Get the correlation between the draws of two variables, by position in
+// Vars. Returns NaN if traces are not kept, or either variable is constant.
+func (r *Recorder) Correlation(i, j int) float64 {
+	if !r.keep || r.Draws < 2 {
+		return math.NaN()
+	}
+	var (
+		x, y       = r.Traces[i], r.Traces[j]
+		mx, vx     = meanVar(x)
+		my, vy     = meanVar(y)
+		covariance float64
+	)
+	for t := range x {
+		covariance += (x[t] - mx) * (y[t] - my)
+	}
+	covariance /= float64(len(x) - 1)
+	if vx == 0 || vy == 0 {
+		return math.NaN()
+	}
+	return covariance / math.Sqrt(vx*vy)
+}
+
+// Run Gibbs sampling on a model: burnin rounds, then samples draws recorded
+// with the recorder, each after thinning rounds. Returns an error if the
+// recorder cannot write a draw.
+func Sample(model []GibbsSample, burnin, thinning, samples int, recorder *Recorder) error {
+	for r := 0; r < burnin; r++ {
+		sweep(model, nil)
+	}
+	for n := 0; n < samples; n++ {
+		for r := 0; r < thinning || r == 0; r++ {
+			sweep(model, nil)
+		}
+		if err := recorder.Record(); err != nil {
+			return err
+		}
+	}
+	return recorder.Flush()
+}
+
+// A running summary of the draws of one variable
+type Summary struct {
+
+	// The number of draws
+	N int
+
+	// For a discrete variable with a finite space, the number of draws of
+	// each outcome
+	Counts []int
+
+	// Welford's running mean and sum of squared deviations
+	mean, m2 float64
+
+	// A uniform sample of the draws, for quantiles
+	reservoir []float64
+	rng       *rand.Rand
+}
+
+// Create an empty summary for a variable
+func newSummary(v variable.RandomVariable) *Summary {
+	var seed int64 = 1
+	if iv, ok := v.(variable.IdentifiedVariable); ok {
+		seed = iv.ID()
+	}
+	var s = &Summary{rng: rand.New(rand.NewSource(seed))}
+	if dv, ok := v.(*variable.DiscreteRV); ok && dv.Space().Size() > 0 {
+		s.Counts = make([]int, dv.Space().Size())
+	}
+	return s
+}
+
+// Add a draw to the summary
+func (s *Summary) add(val float64) {
+	s.N++
+	var delta = val - s.mean
+	s.mean += delta / float64(s.N)
+	s.m2 += delta * (val - s.mean)
+
+	if len(s.reservoir) < reservoirSize {
+		s.reservoir = append(s.reservoir, val)
+	} else if j := s.rng.Intn(s.N); j < reservoirSize {
+		s.reservoir[j] = val
+	}
+}
+
+// Count a draw of a discrete outcome
+func (s *Summary) count(outcome dist.Outcome) {
+	if outcome >= 0 && int(outcome) < len(s.Counts) {
+		s.Counts[outcome]++
+	}
+}
+
+// Get the fraction of draws of each outcome of a discrete variable, or nil
+// if the variable is not discrete with a finite space
+func (s Summary) Marginal() []float64 {
+	if s.Counts == nil {
+		return nil
+	}
+	var marginal = make([]float64, len(s.Counts))
+	for o, count := range s.Counts {
+		if s.N > 0 {
+			marginal[o] = float64(count) / float64(s.N)
+		}
+	}
+	return marginal
+}
+
+// Get the mean of the draws
+func (s Summary) Mean() float64 {
+	return s.mean
+}
+
+// Get the sample variance of the draws, with an n-1 denominator
+func (s Summary) Variance() float64 {
+	if s.N < 2 {
+		return 0
+	}
+	return s.m2 / float64(s.N-1)
+}
+
+// Get quantiles of the draws, for probabilities in [0, 1], interpolating
+// linearly between draws. Probabilities outside [0, 1] are clamped. Returns
+// NaN for each quantile if there are no draws.
+func (s Summary) Quantiles(probs ...float64) []float64 {
+	var (
+		sorted    = append([]float64(nil), s.reservoir...)
+		quantiles = make([]float64, len(probs))
+	)
+	sort.Float64s(sorted)
+	for i, p := range probs {
+		if len(sorted) == 0 {
+			quantiles[i] = math.NaN()
+			continue
+		}
+		var (
+			pos  = math.Min(math.Max(p, 0), 1) * float64(len(sorted)-1)
+			low  = int(math.Floor(pos))
+			high = int(math.Ceil(pos))
+		)
+		quantiles[i] = sorted[low] + (pos-float64(low))*(sorted[high]-sorted[low])
+	}
+	return quantiles
+}
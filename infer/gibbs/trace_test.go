@@ -0,0 +1,66 @@
+package gibbs
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	Convey("Given a recorder for a simple model", t, func() {
+		var (
+			model    = twoVarModel(0)
+			recorder = NewRecorder(model[0].Variable, model[1].Variable)
+		)
+		model[0].Variable.(interface{ SetName(string) }).SetName("a")
+		model[1].Variable.(interface{ SetName(string) }).SetName("b")
+
+		Convey("It keeps traces and running marginals", func() {
+			So(Sample(model, 100, 1, 5000, recorder), ShouldBeNil)
+			So(recorder.Draws, ShouldEqual, 5000)
+			So(recorder.Traces[0], ShouldHaveLength, 5000)
+			So(recorder.Summaries[0].Marginal()[1], ShouldAlmostEqual, 0.7, 0.03)
+			So(recorder.Summaries[1].Marginal()[1], ShouldAlmostEqual, 0.6, 0.03)
+			So(recorder.Summaries[0].Mean(), ShouldAlmostEqual, recorder.Summaries[0].Marginal()[1])
+
+			// Cov(a, b) = 0.4 - 0.7*0.6 = -0.02
+			So(recorder.Correlation(0, 1), ShouldAlmostEqual, -0.02/0.2245, 0.06)
+			So(recorder.Correlation(0, 0), ShouldAlmostEqual, 1)
+		})
+
+		Convey("It can stream draws instead", func() {
+			var buf bytes.Buffer
+			So(recorder.Stream(&buf, false), ShouldBeNil)
+			So(Sample(model, 0, 2, 10, recorder), ShouldBeNil)
+			var lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+			So(lines, ShouldHaveLength, 11)
+			So(lines[0], ShouldEqual, "draw,a,b")
+			So(lines[10], ShouldStartWith, "10,")
+			So(recorder.Traces, ShouldBeNil)
+			So(recorder.Summaries[0].N, ShouldEqual, 10)
+			So(recorder.Stream(&buf, false), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Summaries track moments and quantiles", t, func() {
+		var s = &Summary{rng: rand.New(rand.NewSource(1))}
+		for i := 1; i <= 100; i++ {
+			s.add(float64(i))
+		}
+		So(s.Mean(), ShouldAlmostEqual, 50.5)
+		So(s.Variance(), ShouldAlmostEqual, 841.6666666, 1e-6)
+		So(s.Quantiles(0, 0.5, 1, 2), ShouldResemble, []float64{1, 50.5, 100, 100})
+		So(s.Marginal(), ShouldBeNil)
+
+		Convey("Quantiles are estimated for long runs", func() {
+			var s = &Summary{rng: rand.New(rand.NewSource(1))}
+			for i := 0; i < 5*reservoirSize; i++ {
+				s.add(rand.Float64())
+			}
+			So(s.Quantiles(0.5)[0], ShouldAlmostEqual, 0.5, 0.02)
+			So(s.Quantiles(0.9)[0], ShouldAlmostEqual, 0.9, 0.02)
+		})
+	})
+}
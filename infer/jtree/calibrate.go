@@ -0,0 +1,183 @@
+package jtree
+
+import (
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+)
+
+// Get the index of a clique in a separator's Cliques
+func (s *Separator) side(c *Clique) int {
+	if s.Cliques[0] == c {
+		return 0
+	}
+	return 1
+}
+
+// Get the belief of a clique, and the log of its scale. Under Hugin, the
+// scale is not tracked per clique and is zero.
+func (jt *JunctionTree) belief(c *Clique) (*factor.TableFactor, float64) {
+	if jt.Method == Hugin {
+		return c.potential, 0
+	} else if c.belief != nil {
+		return c.belief, c.beliefScale
+	}
+	var (
+		belief = c.potential
+		scale  = c.scale
+	)
+	for _, s := range c.Separators {
+		var in = s.msgs[1-s.side(c)]
+		belief = belief.Product(in.table)
+		scale += in.scale
+	}
+	c.belief, c.beliefScale = belief, scale
+	return belief, scale
+}
+
+// Send every Shafer-Shenoy message which is not valid
+func (jt *JunctionTree) sendAll() {
+	for _, s := range jt.Separators {
+		for i := range s.Cliques {
+			jt.send(s, i)
+		}
+	}
+}
+
+// Compute the Shafer-Shenoy message from s.Cliques[i] across a separator,
+// and any messages it depends on, unless it is already valid
+func (jt *JunctionTree) send(s *Separator, i int) {
+	var msg = &s.msgs[i]
+	if msg.valid {
+		return
+	}
+	var (
+		c     = s.Cliques[i]
+		table = c.potential
+		scale = c.scale
+	)
+	for _, in := range c.Separators {
+		if in == s {
+			continue
+		}
+		var j = 1 - in.side(c)
+		jt.send(in, j)
+		table = table.Product(in.msgs[j].table)
+		scale += in.msgs[j].scale
+	}
+	table = ones(s.Vars).Product(table.SumOut(difference(c.Vars, s.Vars)...))
+	scale += rescale(table)
+	*msg = message{table: table, scale: scale, valid: true}
+	jt.Messages++
+}
+
+// Invalidate the Shafer-Shenoy message from s.Cliques[i] across a
+// separator, and every message which depends on it
+func (jt *JunctionTree) invalidate(s *Separator, i int) {
+	var msg = &s.msgs[i]
+	if !msg.valid {
+		return
+	}
+	msg.valid = false
+	var to = s.Cliques[1-i]
+	to.belief = nil
+	for _, out := range to.Separators {
+		if out != s {
+			jt.invalidate(out, out.side(to))
+		}
+	}
+}
+
+// Enter changed evidence under Shafer-Shenoy: recompute the potentials of the
+// cliques holding the evidence, and then only the messages leading away from
+// them
+func (jt *JunctionTree) updateShaferShenoy(changed []*variable.DiscreteRV) {
+	jt.Messages = 0
+	var dirty = make(map[*Clique]bool)
+	for _, v := range changed {
+		jt.evidence[v] = currentEvidence(v)
+		dirty[jt.cliqueOf[v]] = true
+	}
+	for _, c := range jt.Cliques {
+		if !dirty[c] {
+			continue
+		}
+		c.potential, c.scale = c.withEvidence()
+		c.belief = nil
+		for _, s := range c.Separators {
+			jt.invalidate(s, s.side(c))
+		}
+	}
+	jt.sendAll()
+}
+
+// Enter changed evidence under Hugin. New evidence is multiplied into the
+// clique potentials and propagated. If any evidence was retracted or
+// changed, the tree is reinitialized.
+func (jt *JunctionTree) updateHugin(changed []*variable.DiscreteRV) {
+	for _, v := range changed {
+		if jt.evidence[v] >= 0 {
+			jt.reset()
+			return
+		}
+	}
+	jt.Messages = 0
+	for _, v := range changed {
+		var c = jt.cliqueOf[v]
+		jt.evidence[v] = v.Outcome()
+		c.potential = c.potential.Product(indicator(v))
+		jt.logScale += rescale(c.potential)
+	}
+	jt.propagate()
+}
+
+// Calibrate a Hugin tree by collecting messages to the first clique and then
+// distributing them back out
+func (jt *JunctionTree) propagate() {
+	if len(jt.Cliques) > 0 {
+		jt.collect(jt.Cliques[0], nil)
+		jt.distribute(jt.Cliques[0], nil)
+	}
+}
+
+// Absorb messages into a clique from all its neighbors but one, after they
+// have collected from their own subtrees
+func (jt *JunctionTree) collect(c *Clique, from *Separator) {
+	for _, s := range c.Separators {
+		if s != from {
+			var child = s.Cliques[1-s.side(c)]
+			jt.collect(child, s)
+			jt.absorb(child, c, s)
+		}
+	}
+}
+
+// Pass messages from a clique to all its neighbors but one, and on through
+// their subtrees
+func (jt *JunctionTree) distribute(c *Clique, from *Separator) {
+	for _, s := range c.Separators {
+		if s != from {
+			var child = s.Cliques[1-s.side(c)]
+			jt.absorb(c, child, s)
+			jt.distribute(child, s)
+		}
+	}
+}
+
+// Pass a Hugin message across a separator: the separator's new potential is
+// the sender's potential summed onto it, and the receiver's potential is
+// multiplied by the ratio of the new separator potential to the old one.
+// Zero divided by zero is zero.
+func (jt *JunctionTree) absorb(from, to *Clique, s *Separator) {
+	var sep = ones(s.Vars).Product(from.potential.SumOut(difference(from.Vars, s.Vars)...))
+	rescale(sep)
+	var ratio = factor.NewTableFactor(s.Vars, nil)
+	for i, v := range sep.Values {
+		if old := s.potential.Values[i]; old != 0 {
+			ratio.Values[i] = v / old
+		}
+	}
+	to.potential = to.potential.Product(ratio)
+	jt.logScale += rescale(to.potential)
+	s.potential = sep
+	jt.Messages++
+}
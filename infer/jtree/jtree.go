@@ -0,0 +1,535 @@
+package jtree
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/infer/ve"
+	"github.com/jesand/stats/variable"
+	"math"
+)
+
+// The message passing scheme used to calibrate a junction tree
+type Method int
+
+const (
+	// Keep clique potentials fixed and store a message in each direction of
+	// each separator. Evidence changes only recompute the messages leading
+	// away from the cliques they affect.
+	ShaferShenoy Method = iota
+
+	// Absorb messages into clique and separator potentials. New evidence is
+	// absorbed in place, but retracting evidence reinitializes the tree.
+	Hugin
+)
+
+// Options for building a junction tree
+type Options struct {
+
+	// The heuristic for the elimination order used to triangulate the graph
+	Heuristic ve.Heuristic
+
+	// The message passing scheme
+	Method Method
+
+	// If positive, refuse to build a tree with a clique table of more than
+	// this many entries
+	MaxCliqueSize float64
+}
+
+// Get the default options for building a junction tree
+func DefaultOptions() Options {
+	return Options{
+		Heuristic:     ve.MinFill,
+		Method:        ShaferShenoy,
+		MaxCliqueSize: 1e8,
+	}
+}
+
+// A junction tree (clique tree) for exact inference on a discrete factor
+// graph. Each factor is assigned to a clique containing its variables, and
+// the cliques sharing a variable form a connected subtree. Observed discrete
+// variables are included in the tree, so evidence can change between queries
+// without rebuilding it; the tree is recalibrated when a query finds that the
+// evidence has changed. Observed continuous variables are treated as
+// constants at their values when the tree was built.
+type JunctionTree struct {
+	Cliques    []*Clique
+	Separators []*Separator
+	Method     Method
+
+	// The number of messages passed in the last calibration
+	Messages int
+
+	// The smallest clique containing each variable
+	cliqueOf map[*variable.DiscreteRV]*Clique
+
+	// The variables, and the observed outcome of each one as of the last
+	// calibration, or -1 if it was latent
+	vars     []*variable.DiscreteRV
+	evidence map[*variable.DiscreteRV]dist.Outcome
+
+	// Hugin only: the log of the constant which the product of the clique
+	// potentials divided by the separator potentials must be multiplied by
+	logScale float64
+}
+
+// A clique of the junction tree
+type Clique struct {
+	Vars       []*variable.DiscreteRV
+	Separators []*Separator
+
+	// The product of the factors assigned to the clique, scaled by
+	// exp(-baseScale)
+	base      *factor.TableFactor
+	baseScale float64
+
+	// The variables whose evidence is entered in this clique
+	evidence []*variable.DiscreteRV
+
+	// Under Shafer-Shenoy, the base potential times the evidence, scaled by
+	// exp(-scale), and the cached belief. Under Hugin, the current clique
+	// potential.
+	potential   *factor.TableFactor
+	scale       float64
+	belief      *factor.TableFactor
+	beliefScale float64
+}
+
+// A separator between two adjacent cliques: the variables they share
+type Separator struct {
+	Vars    []*variable.DiscreteRV
+	Cliques [2]*Clique
+
+	// Under Shafer-Shenoy, the message from each clique to the other. Under
+	// Hugin, the separator potential.
+	msgs      [2]message
+	potential *factor.TableFactor
+}
+
+// A Shafer-Shenoy message, scaled by exp(-scale)
+type message struct {
+	table *factor.TableFactor
+	scale float64
+	valid bool
+}
+
+// Build and calibrate a junction tree for a factor graph. All latent
+// variables must be discrete with finite spaces. Disconnected parts of the
+// graph are joined by empty separators.
+func New(graph factor.FactorGraph, opts Options) (*JunctionTree, error) {
+	tables, err := fullTables(graph)
+	if err != nil {
+		return nil, err
+	}
+	var jt = &JunctionTree{
+		Method:   opts.Method,
+		cliqueOf: make(map[*variable.DiscreteRV]*Clique),
+		evidence: make(map[*variable.DiscreteRV]dist.Outcome),
+	}
+	for _, t := range tables {
+		for _, v := range t.Vars {
+			if _, ok := jt.evidence[v]; !ok {
+				jt.vars = append(jt.vars, v)
+				jt.evidence[v] = -1
+			}
+		}
+	}
+
+	// Triangulate, and keep the maximal elimination cliques
+	var (
+		order, _ = ve.Order(tables, jt.vars, opts.Heuristic)
+		adj      = make(map[*variable.DiscreteRV]map[*variable.DiscreteRV]bool)
+		done     = make(map[*variable.DiscreteRV]bool)
+	)
+	for _, v := range jt.vars {
+		adj[v] = make(map[*variable.DiscreteRV]bool)
+	}
+	for _, t := range tables {
+		for _, v1 := range t.Vars {
+			for _, v2 := range t.Vars {
+				if v1 != v2 {
+					adj[v1][v2] = true
+				}
+			}
+		}
+	}
+	for _, v := range order {
+		var vars = []*variable.DiscreteRV{v}
+		for _, n := range jt.vars {
+			if adj[v][n] && !done[n] {
+				vars = append(vars, n)
+			}
+		}
+		for _, n1 := range vars[1:] {
+			for _, n2 := range vars[1:] {
+				if n1 != n2 {
+					adj[n1][n2] = true
+				}
+			}
+		}
+		done[v] = true
+
+		var maximal = true
+		for _, c := range jt.Cliques {
+			maximal = maximal && !isSubset(vars, c.Vars)
+		}
+		if !maximal {
+			continue
+		}
+		var size = 1.0
+		for _, n := range vars {
+			size *= float64(n.Space().Size())
+		}
+		if opts.MaxCliqueSize > 0 && size > opts.MaxCliqueSize {
+			return nil, stats.Errorf("The junction tree needs a clique of %g entries, over the limit of %g",
+				size, opts.MaxCliqueSize)
+		}
+		jt.Cliques = append(jt.Cliques, &Clique{Vars: vars})
+	}
+	jt.connect()
+
+	// Assign each factor to the smallest clique containing its variables,
+	// and each variable's evidence to the smallest clique containing it
+	for _, c := range jt.Cliques {
+		c.base = ones(c.Vars)
+	}
+	for _, t := range tables {
+		var c = jt.smallestClique(t.Vars)
+		c.base = c.base.Product(t)
+	}
+	for _, c := range jt.Cliques {
+		c.baseScale = rescale(c.base)
+	}
+	for _, v := range jt.vars {
+		var c = jt.smallestClique([]*variable.DiscreteRV{v})
+		jt.cliqueOf[v] = c
+		c.evidence = append(c.evidence, v)
+	}
+
+	jt.reset()
+	return jt, nil
+}
+
+// Join the cliques into a maximum spanning tree, weighting each pair of
+// cliques by the number of variables they share
+func (jt *JunctionTree) connect() {
+	if len(jt.Cliques) == 0 {
+		return
+	}
+	var (
+		inTree = make([]bool, len(jt.Cliques))
+		best   = make([]int, len(jt.Cliques))
+		weight = make([]int, len(jt.Cliques))
+	)
+	for i := range jt.Cliques {
+		best[i], weight[i] = 0, -1
+	}
+	inTree[0] = true
+	for added, last := 1, 0; added < len(jt.Cliques); added++ {
+		var next = -1
+		for i, c := range jt.Cliques {
+			if inTree[i] {
+				continue
+			}
+			if w := len(intersect(c.Vars, jt.Cliques[last].Vars)); w > weight[i] {
+				best[i], weight[i] = last, w
+			}
+			if next < 0 || weight[i] > weight[next] {
+				next = i
+			}
+		}
+		var (
+			c1, c2 = jt.Cliques[best[next]], jt.Cliques[next]
+			sep    = &Separator{
+				Vars:    intersect(c2.Vars, c1.Vars),
+				Cliques: [2]*Clique{c1, c2},
+			}
+		)
+		c1.Separators = append(c1.Separators, sep)
+		c2.Separators = append(c2.Separators, sep)
+		jt.Separators = append(jt.Separators, sep)
+		inTree[next] = true
+		last = next
+	}
+}
+
+// Get the smallest clique containing all of the given variables, or nil
+func (jt *JunctionTree) smallestClique(vars []*variable.DiscreteRV) *Clique {
+	var best *Clique
+	for _, c := range jt.Cliques {
+		if isSubset(vars, c.Vars) && (best == nil || len(c.Vars) < len(best.Vars)) {
+			best = c
+		}
+	}
+	return best
+}
+
+// Get the marginal distribution of a variable given the current evidence,
+// recalibrating the tree first if the evidence has changed. Returns
+// ErrZeroProb if the evidence has zero probability.
+func (jt *JunctionTree) Marginal(v *variable.DiscreteRV) ([]float64, error) {
+	table, err := jt.Joint(v)
+	if err != nil {
+		return nil, err
+	}
+	return table.Values, nil
+}
+
+// Get the marginal distribution of every variable in the tree given the
+// current evidence, recalibrating first if the evidence has changed
+func (jt *JunctionTree) Marginals() (map[*variable.DiscreteRV][]float64, error) {
+	var marginals = make(map[*variable.DiscreteRV][]float64)
+	for _, v := range jt.vars {
+		belief, err := jt.Marginal(v)
+		if err != nil {
+			return nil, err
+		}
+		marginals[v] = belief
+	}
+	return marginals, nil
+}
+
+// Get the joint distribution of a set of variables given the current
+// evidence, with its variables in the order given. The variables must all be
+// in one clique. Recalibrates the tree first if the evidence has changed.
+func (jt *JunctionTree) Joint(vars ...*variable.DiscreteRV) (*factor.TableFactor, error) {
+	for _, v := range vars {
+		if _, ok := jt.evidence[v]; !ok {
+			return nil, stats.ErrfVarNotInGraph(v)
+		}
+	}
+	var c = jt.smallestClique(vars)
+	if c == nil {
+		return nil, stats.Errorf("The variables %v are not in one clique", vars)
+	}
+	jt.Update()
+	belief, _ := jt.belief(c)
+	var table = ones(vars).Product(belief.SumOut(difference(c.Vars, vars)...))
+	if table.Sum() == 0 {
+		return nil, stats.ErrZeroProb
+	}
+	table.Normalize()
+	return table, nil
+}
+
+// Compute exact factor marginals and the log partition function with a
+// junction tree built with the default options. This is a MarginalInference
+// method, for training a CRF with factor.CRFTrainer on graphs too large to
+// enumerate.
+func FactorMarginals(graph *factor.FactorGraph) ([]*factor.TableFactor, float64, error) {
+	jt, err := New(*graph, DefaultOptions())
+	if err != nil {
+		return nil, 0, err
+	}
+	var marginals = make([]*factor.TableFactor, len(graph.Factors))
+	for i, f := range graph.Factors {
+		var vars []*variable.DiscreteRV
+		for _, v := range f.Adjacent() {
+			if dv, ok := v.(*variable.DiscreteRV); ok && !dv.IsObserved() && !contains(vars, dv) {
+				vars = append(vars, dv)
+			}
+		}
+		if marginals[i], err = jt.Joint(vars...); err != nil {
+			return nil, 0, err
+		}
+	}
+	return marginals, jt.LogZ(), nil
+}
+
+// Get the natural log of the partition function given the current evidence:
+// the sum of the product of all factors over every joint outcome of the
+// latent variables. Recalibrates the tree first if the evidence has changed.
+// Returns -Inf if the evidence has zero probability.
+func (jt *JunctionTree) LogZ() float64 {
+	jt.Update()
+	if jt.Method == Hugin {
+		var logZ = jt.logScale
+		for _, c := range jt.Cliques {
+			logZ += math.Log(c.potential.Sum())
+		}
+		for _, s := range jt.Separators {
+			logZ -= math.Log(s.potential.Sum())
+		}
+		if math.IsNaN(logZ) {
+			return math.Inf(-1)
+		}
+		return logZ
+	} else if len(jt.Cliques) == 0 {
+		return 0
+	}
+	belief, scale := jt.belief(jt.Cliques[0])
+	return math.Log(belief.Sum()) + scale
+}
+
+// Recalibrate the tree if the evidence on any of its variables has changed
+// since it was last calibrated
+func (jt *JunctionTree) Update() {
+	var changed []*variable.DiscreteRV
+	for _, v := range jt.vars {
+		var outcome = dist.Outcome(-1)
+		if v.IsObserved() {
+			outcome = v.Outcome()
+		}
+		if outcome != jt.evidence[v] {
+			changed = append(changed, v)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	if jt.Method == Hugin {
+		jt.updateHugin(changed)
+	} else {
+		jt.updateShaferShenoy(changed)
+	}
+}
+
+// Reinitialize the potentials from the factors and the current evidence, and
+// calibrate the tree
+func (jt *JunctionTree) reset() {
+	jt.Messages = 0
+	jt.logScale = 0
+	for _, v := range jt.vars {
+		jt.evidence[v] = currentEvidence(v)
+	}
+	for _, c := range jt.Cliques {
+		c.potential, c.scale = c.withEvidence()
+		c.belief = nil
+		jt.logScale += c.scale
+	}
+	for _, s := range jt.Separators {
+		s.msgs = [2]message{}
+		s.potential = ones(s.Vars)
+	}
+	if jt.Method == Hugin {
+		jt.propagate()
+	} else {
+		jt.sendAll()
+	}
+}
+
+// Get the clique's base potential times indicators for its observed
+// variables, rescaled, and the log of its scale
+func (c *Clique) withEvidence() (*factor.TableFactor, float64) {
+	var potential = factor.NewTableFactor(c.Vars, append([]float64(nil), c.base.Values...))
+	for _, v := range c.evidence {
+		if v.IsObserved() {
+			potential = potential.Product(indicator(v))
+		}
+	}
+	return potential, c.baseScale + rescale(potential)
+}
+
+// Get a variable's observed outcome, or -1 if it is latent
+func currentEvidence(v *variable.DiscreteRV) dist.Outcome {
+	if v.IsObserved() {
+		return v.Outcome()
+	}
+	return -1
+}
+
+// Convert each factor of a graph to a table over all of its discrete
+// variables with finite spaces, including observed ones. Other variables must
+// be observed, and are held at their values. Variable values are restored
+// afterwards.
+func fullTables(graph factor.FactorGraph) ([]*factor.TableFactor, error) {
+	var tables []*factor.TableFactor
+	for _, f := range graph.Factors {
+		var released []*variable.DiscreteRV
+		for _, v := range f.Adjacent() {
+			if dv, ok := v.(*variable.DiscreteRV); variable.IsObserved(v) {
+				continue
+			} else if !ok {
+				return nil, stats.ErrDiscreteOnly
+			} else if dv.Space().Size() < 0 {
+				return nil, stats.ErrfInfiniteSpace(dv)
+			}
+		}
+		for _, v := range f.Adjacent() {
+			dv, ok := v.(*variable.DiscreteRV)
+			if ok && dv.IsObserved() && dv.Space().Size() >= 0 {
+				dv.Release()
+				released = append(released, dv)
+			}
+		}
+		if t, ok := f.(*factor.TableFactor); ok {
+			tables = append(tables, t)
+		} else {
+			tables = append(tables, factor.NewTableFactorFrom(f))
+		}
+		for _, v := range released {
+			v.ObserveOutcome(v.Outcome())
+		}
+	}
+	return tables, nil
+}
+
+// Create a table of ones over a list of variables
+func ones(vars []*variable.DiscreteRV) *factor.TableFactor {
+	var table = factor.NewTableFactor(vars, nil)
+	for i := range table.Values {
+		table.Values[i] = 1
+	}
+	return table
+}
+
+// Create an indicator table for a variable's observed outcome
+func indicator(v *variable.DiscreteRV) *factor.TableFactor {
+	var table = factor.NewTableFactor([]*variable.DiscreteRV{v}, nil)
+	table.Values[v.Outcome()] = 1
+	return table
+}
+
+// Divide a table by its largest value, to avoid underflow, and return the
+// log of that value. Tables of zeros are left unchanged.
+func rescale(table *factor.TableFactor) float64 {
+	var max float64
+	for _, v := range table.Values {
+		max = math.Max(max, v)
+	}
+	if max == 0 {
+		return 0
+	}
+	for i, v := range table.Values {
+		table.Values[i] = v / max
+	}
+	return math.Log(max)
+}
+
+// Get the variables of a which are also in b, in the order of a
+func intersect(a, b []*variable.DiscreteRV) []*variable.DiscreteRV {
+	var result []*variable.DiscreteRV
+	for _, v := range a {
+		if contains(b, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Get the variables of a which are not in b, in the order of a
+func difference(a, b []*variable.DiscreteRV) []*variable.DiscreteRV {
+	var result []*variable.DiscreteRV
+	for _, v := range a {
+		if !contains(b, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Ask whether every variable of a is in b
+func isSubset(a, b []*variable.DiscreteRV) bool {
+	return len(difference(a, b)) == 0
+}
+
+// Ask whether a variable is in a list
+func contains(vars []*variable.DiscreteRV, v *variable.DiscreteRV) bool {
+	for _, other := range vars {
+		if other == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package jtree
+
+import (
+	"fmt"
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/infer/ve"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Create a table factor over the given variables with random positive values
+func randomTable(vars ...*variable.DiscreteRV) *factor.TableFactor {
+	var table = factor.NewTableFactor(vars, nil)
+	for i := range table.Values {
+		table.Values[i] = 0.1 + rand.Float64()
+	}
+	return table
+}
+
+// Create a 3x4 grid of variables with random unary and pairwise tables, plus
+// a separate pair of variables. Returns the graph and the variables.
+func randomGraph() (*factor.FactorGraph, []*variable.DiscreteRV) {
+	var (
+		graph = factor.NewFactorGraph()
+		vars  []*variable.DiscreteRV
+	)
+	for i := 0; i < 14; i++ {
+		vars = append(vars, variable.NewDiscreteRV(0, dist.NewIntegerIntervalSpace(0, i%2+1)))
+		graph.AddFactor(randomTable(vars[i]))
+	}
+	for i := 0; i < 12; i++ {
+		if i%4 < 3 {
+			graph.AddFactor(randomTable(vars[i], vars[i+1]))
+		}
+		if i < 8 {
+			graph.AddFactor(randomTable(vars[i], vars[i+4]))
+		}
+	}
+	graph.AddFactor(randomTable(vars[12], vars[13]))
+	return graph, vars
+}
+
+// Assert that a junction tree matches variable elimination on its graph
+func shouldMatchVE(actual interface{}, expected ...interface{}) string {
+	var (
+		jt    = actual.(*JunctionTree)
+		graph = expected[0].(*factor.FactorGraph)
+	)
+	want, err := ve.Marginals(*graph, ve.DefaultOptions())
+	if err != nil {
+		return err.Error()
+	}
+	got, err := jt.Marginals()
+	if err != nil {
+		return err.Error()
+	}
+	for v, belief := range want {
+		for x, p := range belief {
+			if msg := ShouldAlmostEqual(got[v][x], p, 1e-9); msg != "" {
+				return fmt.Sprintf("Marginal of %v: %s", v, msg)
+			}
+		}
+	}
+	logZ, err := ve.LogPartition(*graph, ve.DefaultOptions())
+	if err != nil {
+		return err.Error()
+	}
+	return ShouldAlmostEqual(jt.LogZ(), logZ, 1e-9)
+}
+
+func TestJunctionTree(t *testing.T) {
+	for _, method := range []Method{ShaferShenoy, Hugin} {
+		Convey(fmt.Sprintf("Given a junction tree with method %d", method), t, func() {
+			var (
+				graph, vars = randomGraph()
+				opts        = DefaultOptions()
+			)
+			opts.Method = method
+			vars[5].Observe(1)
+			jt, err := New(*graph, opts)
+			So(err, ShouldBeNil)
+
+			Convey("It has the running intersection property", func() {
+				So(jt.Separators, ShouldHaveLength, len(jt.Cliques)-1)
+				for _, v := range vars {
+					// The cliques containing v and the separators between
+					// them form a tree
+					var cliques, seps int
+					for _, c := range jt.Cliques {
+						if contains(c.Vars, v) {
+							cliques++
+						}
+					}
+					for _, s := range jt.Separators {
+						if contains(s.Vars, v) {
+							seps++
+						}
+					}
+					So(seps, ShouldEqual, cliques-1)
+				}
+			})
+
+			Convey("It matches variable elimination", func() {
+				So(jt, shouldMatchVE, graph)
+				marginal, err := jt.Marginal(vars[5])
+				So(err, ShouldBeNil)
+				So(marginal, ShouldResemble, []float64{0, 1, 0})
+			})
+
+			Convey("Joint queries within a clique work", func() {
+				joint, err := jt.Joint(vars[1], vars[0])
+				So(err, ShouldBeNil)
+				So(joint.Vars, ShouldResemble, []*variable.DiscreteRV{vars[1], vars[0]})
+				want, err := ve.Query(*graph, joint.Vars, ve.DefaultOptions())
+				So(err, ShouldBeNil)
+				for i, p := range want.Values {
+					So(joint.Values[i], ShouldAlmostEqual, p, 1e-9)
+				}
+
+				_, err = jt.Joint(vars[0], vars[12])
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("Evidence changes are picked up", func() {
+				vars[0].Observe(1)
+				So(jt, shouldMatchVE, graph)
+				if method == ShaferShenoy {
+					So(jt.Messages, ShouldBeLessThan, 2*len(jt.Separators))
+				}
+
+				vars[13].Observe(0)
+				So(jt, shouldMatchVE, graph)
+				if method == ShaferShenoy {
+					So(jt.Messages, ShouldBeLessThan, 2*len(jt.Separators))
+				}
+
+				vars[5].Release()
+				vars[0].Release()
+				vars[0].Observe(0)
+				So(jt, shouldMatchVE, graph)
+
+				vars[0].Release()
+				vars[13].Release()
+				So(jt, shouldMatchVE, graph)
+			})
+
+			Convey("Impossible evidence is reported", func() {
+				graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{vars[12]}, []float64{1, 0}))
+				jt, err := New(*graph, opts)
+				So(err, ShouldBeNil)
+				vars[12].Observe(1)
+				_, err = jt.Marginal(vars[0])
+				So(err, ShouldEqual, stats.ErrZeroProb)
+				So(math.IsInf(jt.LogZ(), -1), ShouldBeTrue)
+				vars[12].Release()
+				So(jt, shouldMatchVE, graph)
+			})
+		})
+	}
+
+	Convey("Factor marginals match enumeration", t, func() {
+		var graph, vars = randomGraph()
+		vars[5].Observe(1)
+		vars[9].Observe(0)
+		want, wantLogZ, err := factor.EnumerateMarginals(graph)
+		So(err, ShouldBeNil)
+		got, logZ, err := FactorMarginals(graph)
+		So(err, ShouldBeNil)
+		So(logZ, ShouldAlmostEqual, wantLogZ, 1e-9)
+		So(got, ShouldHaveLength, len(want))
+		for i, m := range want {
+			So(got[i].Vars, ShouldResemble, m.Vars)
+			for x, p := range m.Values {
+				So(got[i].Values[x], ShouldAlmostEqual, p, 1e-9)
+			}
+		}
+	})
+
+	Convey("Clique size limits are enforced", t, func() {
+		var (
+			graph, _ = randomGraph()
+			opts     = DefaultOptions()
+		)
+		opts.MaxCliqueSize = 4
+		_, err := New(*graph, opts)
+		So(err, ShouldNotBeNil)
+	})
+}
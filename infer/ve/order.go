@@ -0,0 +1,117 @@
+package ve
+
+import (
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+)
+
+// A greedy heuristic for choosing the next variable to eliminate
+type Heuristic int
+
+const (
+	// Eliminate the variable which adds the fewest edges between its
+	// neighbors
+	MinFill Heuristic = iota
+
+	// Eliminate the variable with the fewest neighbors
+	MinDegree
+
+	// Eliminate the variable whose added edges have the smallest total
+	// weight, where an edge weighs the product of its variables' space sizes.
+	// This prefers small tables when spaces differ in size.
+	WeightedMinFill
+)
+
+// Choose an order in which to eliminate the given variables, greedily by a
+// heuristic, from the interaction graph of a set of tables: variables are
+// adjacent if they share a table. Variables which are not eliminated stay in
+// the graph. Ties are broken by position in vars. Returns the order and its
+// induced width: the most neighbors a variable has when it is eliminated.
+func Order(tables []*factor.TableFactor, vars []*variable.DiscreteRV,
+	heuristic Heuristic) ([]*variable.DiscreteRV, int) {
+
+	var (
+		g     = newInteractionGraph(tables)
+		done  = make(map[*variable.DiscreteRV]bool)
+		order []*variable.DiscreteRV
+		width int
+	)
+	for range vars {
+		var (
+			best     *variable.DiscreteRV
+			bestCost float64
+		)
+		for _, v := range vars {
+			if done[v] {
+				continue
+			}
+			if c := g.cost(v, heuristic); best == nil || c < bestCost {
+				best, bestCost = v, c
+			}
+		}
+		if len(g.adj[best]) > width {
+			width = len(g.adj[best])
+		}
+		order = append(order, best)
+		done[best] = true
+		g.eliminate(best)
+	}
+	return order, width
+}
+
+// The interaction graph of a set of tables
+type interactionGraph struct {
+	adj map[*variable.DiscreteRV]map[*variable.DiscreteRV]bool
+}
+
+// Build the interaction graph of a set of tables
+func newInteractionGraph(tables []*factor.TableFactor) *interactionGraph {
+	var g = &interactionGraph{adj: make(map[*variable.DiscreteRV]map[*variable.DiscreteRV]bool)}
+	for _, t := range tables {
+		for _, v1 := range t.Vars {
+			if g.adj[v1] == nil {
+				g.adj[v1] = make(map[*variable.DiscreteRV]bool)
+			}
+			for _, v2 := range t.Vars {
+				if v1 != v2 {
+					g.adj[v1][v2] = true
+				}
+			}
+		}
+	}
+	return g
+}
+
+// Get the cost of eliminating a variable next under a heuristic
+func (g *interactionGraph) cost(v *variable.DiscreteRV, heuristic Heuristic) float64 {
+	if heuristic == MinDegree {
+		return float64(len(g.adj[v]))
+	}
+	// Each missing edge is counted from both ends
+	var cost float64
+	for n1 := range g.adj[v] {
+		for n2 := range g.adj[v] {
+			if n1 != n2 && !g.adj[n1][n2] {
+				if heuristic == WeightedMinFill {
+					cost += float64(n1.Space().Size() * n2.Space().Size())
+				} else {
+					cost++
+				}
+			}
+		}
+	}
+	return cost / 2
+}
+
+// Eliminate a variable, connecting all of its neighbors
+func (g *interactionGraph) eliminate(v *variable.DiscreteRV) {
+	for n1 := range g.adj[v] {
+		delete(g.adj[n1], v)
+		for n2 := range g.adj[v] {
+			if n1 != n2 {
+				g.adj[n1][n2] = true
+			}
+		}
+	}
+	delete(g.adj, v)
+}
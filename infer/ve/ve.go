@@ -0,0 +1,266 @@
+package ve
+
+import (
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	"math"
+)
+
+// Options for variable elimination
+type Options struct {
+
+	// The heuristic used to choose the elimination order
+	Heuristic Heuristic
+
+	// If positive, refuse to run a plan whose largest intermediate table
+	// would have more than this many entries
+	MaxTableSize float64
+}
+
+// Get the default options for variable elimination
+func DefaultOptions() Options {
+	return Options{
+		Heuristic:    MinFill,
+		MaxTableSize: 1e8,
+	}
+}
+
+// A plan for eliminating the latent variables of a factor graph, other than
+// the query variables. Sizes are counted in table entries, and are floats
+// because they can overflow an int on graphs far too large to run.
+type Plan struct {
+
+	// The query variables, which are kept
+	Query []*variable.DiscreteRV
+
+	// The order in which the other latent variables are eliminated
+	Order []*variable.DiscreteRV
+
+	// The induced width of the order: the most neighbors a variable has when
+	// it is eliminated
+	Width int
+
+	// The size of the largest table built while eliminating a variable
+	MaxTableSize float64
+
+	// The total size of all tables built, an upper bound on the memory needed
+	TotalSize float64
+
+	tables []*factor.TableFactor
+	opts   Options
+}
+
+// Plan variable elimination on a factor graph, keeping the query variables,
+// without running it. Observed variables are evidence. The query variables
+// must be latent, and all latent variables must be discrete with finite
+// spaces.
+func NewPlan(graph factor.FactorGraph, query []*variable.DiscreteRV, opts Options) (*Plan, error) {
+	tables, err := Tables(graph)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		plan = &Plan{
+			Query:  query,
+			tables: tables,
+			opts:   opts,
+		}
+		isQuery = make(map[*variable.DiscreteRV]bool)
+		seen    = make(map[*variable.DiscreteRV]bool)
+		hidden  []*variable.DiscreteRV
+	)
+	for _, v := range query {
+		if !graph.HasVariable(v) {
+			return nil, stats.ErrfVarNotInGraph(v)
+		} else if v.IsObserved() {
+			return nil, stats.ErrObserved
+		}
+		isQuery[v] = true
+	}
+	for _, t := range tables {
+		for _, v := range t.Vars {
+			if !seen[v] && !isQuery[v] {
+				hidden = append(hidden, v)
+			}
+			seen[v] = true
+		}
+	}
+	plan.Order, plan.Width = Order(tables, hidden, opts.Heuristic)
+
+	// Simulate elimination to find the table sizes
+	var g = newInteractionGraph(tables)
+	for _, v := range plan.Order {
+		var size = float64(v.Space().Size())
+		for n := range g.adj[v] {
+			size *= float64(n.Space().Size())
+		}
+		plan.MaxTableSize = math.Max(plan.MaxTableSize, size)
+		plan.TotalSize += size + size/float64(v.Space().Size())
+		g.eliminate(v)
+	}
+	var size = 1.0
+	for _, v := range query {
+		size *= float64(v.Space().Size())
+	}
+	plan.MaxTableSize = math.Max(plan.MaxTableSize, size)
+	plan.TotalSize += size
+	return plan, nil
+}
+
+// Get an upper bound on the memory needed by the plan's tables, in bytes
+func (plan Plan) Bytes() float64 {
+	return 8 * plan.TotalSize
+}
+
+// Run the plan. Returns the unnormalized table over the query variables,
+// in the order of Query, and a log scale: the true values are the table's
+// values times exp(logScale). Returns an error if the plan's largest table
+// exceeds the limit in its options.
+func (plan Plan) Run() (*factor.TableFactor, float64, error) {
+	if plan.opts.MaxTableSize > 0 && plan.MaxTableSize > plan.opts.MaxTableSize {
+		return nil, 0, stats.Errorf("Variable elimination needs a table of %g entries, over the limit of %g",
+			plan.MaxTableSize, plan.opts.MaxTableSize)
+	}
+	var (
+		tables   = make([]*factor.TableFactor, len(plan.tables))
+		logScale float64
+	)
+	for i, t := range plan.tables {
+		tables[i] = factor.NewTableFactor(t.Vars, append([]float64(nil), t.Values...))
+		logScale += rescale(tables[i])
+	}
+	for _, v := range plan.Order {
+		var (
+			product *factor.TableFactor
+			rest    []*factor.TableFactor
+		)
+		for _, t := range tables {
+			if !hasVar(t, v) {
+				rest = append(rest, t)
+			} else if product == nil {
+				product = t
+			} else {
+				product = product.Product(t)
+			}
+		}
+		var table = product.SumOut(v)
+		logScale += rescale(table)
+		tables = append(rest, table)
+	}
+
+	var result = factor.NewTableFactor(plan.Query, nil)
+	for i := range result.Values {
+		result.Values[i] = 1
+	}
+	for _, t := range tables {
+		result = result.Product(t)
+	}
+	return result, logScale, nil
+}
+
+// Compute the distribution of the query variables given the evidence, by
+// variable elimination. Returns ErrZeroProb if the evidence has zero
+// probability.
+func Query(graph factor.FactorGraph, query []*variable.DiscreteRV, opts Options) (*factor.TableFactor, error) {
+	plan, err := NewPlan(graph, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	table, _, err := plan.Run()
+	if err != nil {
+		return nil, err
+	} else if table.Sum() == 0 {
+		return nil, stats.ErrZeroProb
+	}
+	table.Normalize()
+	return table, nil
+}
+
+// Compute the marginal distribution of every latent variable given the
+// evidence, by running variable elimination once per variable. Returns
+// ErrZeroProb if the evidence has zero probability.
+func Marginals(graph factor.FactorGraph, opts Options) (map[*variable.DiscreteRV][]float64, error) {
+	var marginals = make(map[*variable.DiscreteRV][]float64)
+	for _, v := range graph.LatentVariables() {
+		dv, ok := v.(*variable.DiscreteRV)
+		if !ok {
+			return nil, stats.ErrDiscreteOnly
+		}
+		table, err := Query(graph, []*variable.DiscreteRV{dv}, opts)
+		if err != nil {
+			return nil, err
+		}
+		marginals[dv] = table.Values
+	}
+	return marginals, nil
+}
+
+// Compute the natural log of the partition function: the sum of the product
+// of all factors over every joint outcome of the latent variables. With
+// evidence, this is the log of the unnormalized probability of the evidence.
+// Returns -Inf if the sum is zero.
+func LogPartition(graph factor.FactorGraph, opts Options) (float64, error) {
+	plan, err := NewPlan(graph, nil, opts)
+	if err != nil {
+		return 0, err
+	}
+	table, logScale, err := plan.Run()
+	if err != nil {
+		return 0, err
+	} else if sum := table.Sum(); sum == 0 {
+		return math.Inf(-1), nil
+	} else {
+		return math.Log(sum) + logScale, nil
+	}
+}
+
+// Convert each factor of a graph to a table over its latent variables,
+// conditioned on its observed variables. Returns ErrDiscreteOnly if a latent
+// variable is not discrete.
+func Tables(graph factor.FactorGraph) ([]*factor.TableFactor, error) {
+	var tables []*factor.TableFactor
+	for _, f := range graph.Factors {
+		for _, v := range f.Adjacent() {
+			if variable.IsObserved(v) {
+				continue
+			} else if dv, ok := v.(*variable.DiscreteRV); !ok {
+				return nil, stats.ErrDiscreteOnly
+			} else if dv.Space().Size() < 0 {
+				return nil, stats.ErrfInfiniteSpace(dv)
+			}
+		}
+		if t, ok := f.(*factor.TableFactor); ok {
+			tables = append(tables, t.ReduceEvidence())
+		} else {
+			tables = append(tables, factor.NewTableFactorFrom(f))
+		}
+	}
+	return tables, nil
+}
+
+// Divide a table by its largest value, to avoid underflow, and return the
+// log of that value. Tables of zeros are left unchanged.
+func rescale(table *factor.TableFactor) float64 {
+	var max float64
+	for _, v := range table.Values {
+		max = math.Max(max, v)
+	}
+	if max == 0 {
+		return 0
+	}
+	for i, v := range table.Values {
+		table.Values[i] = v / max
+	}
+	return math.Log(max)
+}
+
+// Ask whether a variable is one of a table's variables
+func hasVar(table *factor.TableFactor, v *variable.DiscreteRV) bool {
+	for _, other := range table.Vars {
+		if other == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package ve
+
+import (
+	"fmt"
+	"github.com/jesand/stats"
+	"github.com/jesand/stats/dist"
+	"github.com/jesand/stats/factor"
+	"github.com/jesand/stats/variable"
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Create a table factor over the given variables with random positive values
+func randomTable(vars ...*variable.DiscreteRV) *factor.TableFactor {
+	var table = factor.NewTableFactor(vars, nil)
+	for i := range table.Values {
+		table.Values[i] = 0.1 + rand.Float64()
+	}
+	return table
+}
+
+// Create a 3x3 grid of variables with three outcomes each, with random unary
+// and pairwise tables. Returns the graph and the variables in row-major order.
+func randomGrid() (*factor.FactorGraph, []*variable.DiscreteRV) {
+	var (
+		graph = factor.NewFactorGraph()
+		vars  []*variable.DiscreteRV
+	)
+	for i := 0; i < 9; i++ {
+		vars = append(vars, variable.NewDiscreteRV(0, dist.NewIntegerIntervalSpace(0, 2)))
+		graph.AddFactor(randomTable(vars[i]))
+	}
+	for i := 0; i < 9; i++ {
+		if i%3 < 2 {
+			graph.AddFactor(randomTable(vars[i], vars[i+1]))
+		}
+		if i < 6 {
+			graph.AddFactor(randomTable(vars[i], vars[i+3]))
+		}
+	}
+	return graph, vars
+}
+
+func TestOrder(t *testing.T) {
+	var boolVar = func() *variable.DiscreteRV {
+		return variable.NewDiscreteRV(0, dist.BooleanSpace)
+	}
+
+	Convey("A chain can be eliminated with width one", t, func() {
+		var (
+			vars   = []*variable.DiscreteRV{boolVar(), boolVar(), boolVar(), boolVar()}
+			tables []*factor.TableFactor
+		)
+		for i := 1; i < len(vars); i++ {
+			tables = append(tables, randomTable(vars[i-1], vars[i]))
+		}
+		for _, h := range []Heuristic{MinFill, MinDegree, WeightedMinFill} {
+			order, width := Order(tables, []*variable.DiscreteRV{vars[1], vars[2], vars[0], vars[3]}, h)
+			So(order, ShouldHaveLength, 4)
+			So(width, ShouldEqual, 1)
+		}
+	})
+
+	Convey("Weighted min-fill avoids connecting large variables", t, func() {
+		var (
+			big1   = variable.NewDiscreteRV(0, dist.NewIntegerIntervalSpace(0, 9))
+			big2   = variable.NewDiscreteRV(0, dist.NewIntegerIntervalSpace(0, 9))
+			small1 = boolVar()
+			small2 = boolVar()
+			a, b   = boolVar(), boolVar()
+			tables = []*factor.TableFactor{
+				randomTable(a, big1), randomTable(a, big2),
+				randomTable(b, small1), randomTable(b, small2),
+			}
+			vars = []*variable.DiscreteRV{a, b}
+		)
+		order, _ := Order(tables, vars, MinFill)
+		So(order[0], ShouldEqual, a)
+		order, _ = Order(tables, vars, WeightedMinFill)
+		So(order[0], ShouldEqual, b)
+	})
+}
+
+func TestVariableElimination(t *testing.T) {
+	Convey("Given a grid with evidence", t, func() {
+		var graph, vars = randomGrid()
+		vars[4].Observe(1)
+		marginals, logZ, err := factor.EnumerateMarginals(graph)
+		So(err, ShouldBeNil)
+
+		for _, h := range []Heuristic{MinFill, MinDegree, WeightedMinFill} {
+			var opts = DefaultOptions()
+			opts.Heuristic = h
+
+			Convey(fmt.Sprintf("Heuristic %d finds the log partition function", h), func() {
+				got, err := LogPartition(*graph, opts)
+				So(err, ShouldBeNil)
+				So(got, ShouldAlmostEqual, logZ, 1e-9)
+			})
+
+			Convey(fmt.Sprintf("Heuristic %d finds the marginals", h), func() {
+				got, err := Marginals(*graph, opts)
+				So(err, ShouldBeNil)
+				So(got, ShouldHaveLength, 8)
+				for i, v := range vars {
+					if i == 4 {
+						continue
+					}
+					for x, p := range marginals[i].Values {
+						So(got[v][x], ShouldAlmostEqual, p, 1e-9)
+					}
+				}
+			})
+
+			Convey(fmt.Sprintf("Heuristic %d finds joint conditionals", h), func() {
+				// The factor between vars 0 and 3 is the 10th added
+				var query = []*variable.DiscreteRV{vars[3], vars[0]}
+				got, err := Query(*graph, query, opts)
+				So(err, ShouldBeNil)
+				So(got.Vars, ShouldResemble, query)
+				var want = marginals[10]
+				So(want.Vars, ShouldResemble, []*variable.DiscreteRV{vars[0], vars[3]})
+				for x0 := 0; x0 < 3; x0++ {
+					for x3 := 0; x3 < 3; x3++ {
+						So(got.Value(dist.Outcome(x3), dist.Outcome(x0)), ShouldAlmostEqual,
+							want.Value(dist.Outcome(x0), dist.Outcome(x3)), 1e-9)
+					}
+				}
+			})
+		}
+	})
+
+	Convey("Given a plan", t, func() {
+		var graph, vars = randomGrid()
+		plan, err := NewPlan(*graph, []*variable.DiscreteRV{vars[0]}, DefaultOptions())
+		So(err, ShouldBeNil)
+		So(plan.Order, ShouldHaveLength, 8)
+		So(plan.Order, ShouldNotContain, vars[0])
+		So(plan.Width, ShouldBeBetweenOrEqual, 3, 4)
+		So(plan.MaxTableSize, ShouldEqual, math.Pow(3, float64(plan.Width+1)))
+		So(plan.Bytes(), ShouldBeGreaterThan, 8*plan.MaxTableSize)
+
+		Convey("It refuses to run over the size limit", func() {
+			var opts = DefaultOptions()
+			opts.MaxTableSize = 10
+			plan, err := NewPlan(*graph, nil, opts)
+			So(err, ShouldBeNil)
+			_, _, err = plan.Run()
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Long chains do not underflow", t, func() {
+		var (
+			graph = factor.NewFactorGraph()
+			prev  = variable.NewDiscreteRV(0, dist.BooleanSpace)
+		)
+		for i := 0; i < 1000; i++ {
+			var next = variable.NewDiscreteRV(0, dist.BooleanSpace)
+			graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{prev, next},
+				[]float64{1e-3, 1e-3, 1e-3, 1e-3}))
+			prev = next
+		}
+		logZ, err := LogPartition(*graph, DefaultOptions())
+		So(err, ShouldBeNil)
+		So(logZ, ShouldAlmostEqual, 1000*math.Log(2e-3)+math.Log(2), 1e-6)
+	})
+
+	Convey("Evidence with zero probability is reported", t, func() {
+		var (
+			a, b  = variable.NewDiscreteRV(0, dist.BooleanSpace), variable.NewDiscreteRV(0, dist.BooleanSpace)
+			graph = factor.NewFactorGraph()
+		)
+		graph.AddFactor(factor.NewTableFactor([]*variable.DiscreteRV{a, b}, []float64{1, 0, 1, 0}))
+		b.Observe(1)
+		_, err := Query(*graph, []*variable.DiscreteRV{a}, DefaultOptions())
+		So(err, ShouldEqual, stats.ErrZeroProb)
+		logZ, err := LogPartition(*graph, DefaultOptions())
+		So(err, ShouldBeNil)
+		So(math.IsInf(logZ, -1), ShouldBeTrue)
+
+		_, err = Query(*graph, []*variable.DiscreteRV{b}, DefaultOptions())
+		So(err, ShouldEqual, stats.ErrObserved)
+	})
+}
@@ -60,17 +60,52 @@ func (model MultipleBSCModel) HasInput(name string) bool {
 // Adds a new observation to the model for the given channel and input. If the
 // input is new, it will be created automatically.
 func (model *MultipleBSCModel) AddObservation(input, channel string, value bool) {
+	inputVar := model.input(input)
+	ch := model.Channels[channel]
+
+	// Add a worker noise factor to explain the assessment
+	output := variable.NewDiscreteRV(0, dist.BooleanSpace)
+	output.ObserveOutcome(dist.BooleanSpace.BoolOutcome(value))
+	model.FactorGraph.AddFactor(ch.Factor(inputVar, output))
+}
+
+// Removes the observations of an input from a channel, such as withdrawn
+// judgments. If the input has no observations left, it is removed from the
+// model unless it is observed. Returns the number of observations removed.
+func (model *MultipleBSCModel) RemoveObservation(input, channel string) int {
 	inputVar, ok := model.Inputs[input]
+	ch, chOk := model.Channels[channel]
+	if !ok || !chOk || !model.FactorGraph.HasVariable(inputVar) {
+		return 0
+	}
+	var factors []factor.Factor
+	for _, f := range model.FactorGraph.AdjToVariable(inputVar) {
+		if bf, ok := f.(*bsc.BSCFactor); ok && bf.NoiseRate == ch.NoiseRate {
+			factors = append(factors, f)
+		}
+	}
+	var removed = model.FactorGraph.RemoveFactors(factors...)
+	if !model.FactorGraph.HasVariable(inputVar) && !inputVar.IsObserved() {
+		delete(model.Inputs, input)
+	}
+	return removed
+}
+
+// Clamp an input to a known value, such as a gold-standard answer. If the
+// input is new, it will be created automatically. EM will not change it.
+func (model *MultipleBSCModel) ObserveInput(name string, value bool) {
+	model.input(name).ObserveOutcome(dist.BooleanSpace.BoolOutcome(value))
+}
+
+// Get the input variable with the given name, creating it if necessary
+func (model *MultipleBSCModel) input(name string) *variable.DiscreteRV {
+	inputVar, ok := model.Inputs[name]
 	if !ok {
 		inputVar = variable.NewDiscreteRV(0, dist.BooleanSpace)
-		model.Inputs[input] = inputVar
+		inputVar.SetName(name)
+		model.Inputs[name] = inputVar
 	}
-	ch := model.Channels[channel]
-
-	// Add a worker noise factor to explain the assessment
-	outcome := dist.BooleanSpace.BoolOutcome(value)
-	model.FactorGraph.AddFactor(ch.Factor(inputVar, variable.NewDiscreteRV(
-		outcome, dist.BooleanSpace)))
+	return inputVar
 }
 
 // Score the model, using the current parameter values
@@ -98,6 +133,10 @@ func (model *MultipleBSCModel) EM(maxRounds int, tolerance float64,
 
 		// Update input
 		for _, input := range model.Inputs {
+			if input.IsObserved() {
+				softScores[input] = input.Val()
+				continue
+			}
 			input.Set(0)
 			ifFalse := math.Exp(model.FactorGraph.ScoreVar(input))
 			input.Set(1)
@@ -126,6 +165,10 @@ func (model *MultipleBSCModel) EM(maxRounds int, tolerance float64,
 		for r2 := 1; (maxRounds == 0 || r2 <= maxRounds) &&
 			thisRound2-lastRound2 > tolerance; r2++ {
 			for _, ch := range model.Channels {
+				if ch.NoiseRate.IsObserved() ||
+					!model.FactorGraph.HasVariable(ch.NoiseRate) {
+					continue
+				}
 				var sum, count float64
 				for _, factor := range model.FactorGraph.AdjToVariable(ch.NoiseRate) {
 					if ch, ok := factor.(*bsc.BSCFactor); ok {
@@ -158,6 +201,10 @@ func (model *MultipleBSCModel) EM(maxRounds int, tolerance float64,
 
 	model.InputScores = make(map[string]float64)
 	for name, input := range model.Inputs {
+		if input.IsObserved() {
+			model.InputScores[name] = input.Val()
+			continue
+		}
 		input.Set(0)
 		ifFalse := math.Exp(model.FactorGraph.ScoreVar(input))
 		input.Set(1)
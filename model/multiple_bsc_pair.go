@@ -91,16 +91,66 @@ func (model MultipleBSCPairModel) HasInput(name string) bool {
 // Adds a new observation to the model for the given channel and input. If the
 // input is new, it will be created automatically.
 func (model *MultipleBSCPairModel) AddObservation(input, channel1, channel2 string, value bool) {
+	inputVar := model.input(input)
+	ch := model.Channels[channel1][channel2]
+
+	// Add a worker noise factor to explain the assessment
+	output := variable.NewDiscreteRV(0, dist.BooleanSpace)
+	output.ObserveOutcome(dist.BooleanSpace.BoolOutcome(value))
+	model.FactorGraph.AddFactor(ch.Factor(inputVar, output))
+}
+
+// Constrains three pairwise preference inputs to be transitive, where ab is
+// true if a is preferred to b, and so on. EM will not assign the inputs
+// inconsistent values. If the inputs are new, they will be created
+// automatically.
+func (model *MultipleBSCPairModel) AddTransitivity(ab, bc, ac string) {
+	model.FactorGraph.AddFactor(factor.NewTransitivityFactor(
+		model.input(ab), model.input(bc), model.input(ac)))
+}
+
+// Removes the observations of an input from a pair of channels, such as
+// withdrawn judgments. If the input has no observations left, it is removed
+// from the model unless it is observed. Returns the number of observations
+// removed.
+func (model *MultipleBSCPairModel) RemoveObservation(input, channel1, channel2 string) int {
 	inputVar, ok := model.Inputs[input]
+	if !ok || !model.HasChannel(channel1, channel2) ||
+		!model.FactorGraph.HasVariable(inputVar) {
+		return 0
+	}
+	var (
+		ch      = model.Channels[channel1][channel2]
+		factors []factor.Factor
+	)
+	for _, f := range model.FactorGraph.AdjToVariable(inputVar) {
+		if bf, ok := f.(*bsc.BSCPairFactor); ok && bf.NoiseRate1 == ch.NoiseRate1 &&
+			bf.NoiseRate2 == ch.NoiseRate2 {
+			factors = append(factors, f)
+		}
+	}
+	var removed = model.FactorGraph.RemoveFactors(factors...)
+	if !model.FactorGraph.HasVariable(inputVar) && !inputVar.IsObserved() {
+		delete(model.Inputs, input)
+	}
+	return removed
+}
+
+// Clamp an input to a known value, such as a gold-standard answer. If the
+// input is new, it will be created automatically. EM will not change it.
+func (model *MultipleBSCPairModel) ObserveInput(name string, value bool) {
+	model.input(name).ObserveOutcome(dist.BooleanSpace.BoolOutcome(value))
+}
+
+// Get the input variable with the given name, creating it if necessary
+func (model *MultipleBSCPairModel) input(name string) *variable.DiscreteRV {
+	inputVar, ok := model.Inputs[name]
 	if !ok {
 		inputVar = variable.NewDiscreteRV(0, dist.BooleanSpace)
-		model.Inputs[input] = inputVar
+		inputVar.SetName(name)
+		model.Inputs[name] = inputVar
 	}
-	ch := model.Channels[channel1][channel2]
-
-	// Add a worker noise factor to explain the assessment
-	model.FactorGraph.AddFactor(ch.Factor(inputVar, variable.NewDiscreteRV(
-		dist.BooleanSpace.BoolOutcome(value), dist.BooleanSpace)))
+	return inputVar
 }
 
 // Score the model, using the current parameter values
@@ -123,11 +173,17 @@ func (model *MultipleBSCPairModel) EM(maxRounds int, tolerance float64,
 	if callback != nil {
 		callback(model, round, "Initial")
 	}
+	// Always run the first round, in case the initial values violate a
+	// constraint and the initial score is -Inf
 	for round = 1; (maxRounds == 0 || round <= maxRounds) &&
-		thisRound-lastRound > tolerance; round++ {
+		(round == 1 || thisRound-lastRound > tolerance); round++ {
 
 		// Update input
 		for _, input := range model.Inputs {
+			if input.IsObserved() {
+				softScores[input] = input.Val()
+				continue
+			}
 			input.Set(0)
 			ifFalse := math.Exp(model.FactorGraph.ScoreVar(input))
 			input.Set(1)
@@ -157,6 +213,10 @@ func (model *MultipleBSCPairModel) EM(maxRounds int, tolerance float64,
 
 			// Update the first layer of noise rates
 			for _, noiseRate := range model.Noise1Rates {
+				if noiseRate.IsObserved() ||
+					!model.FactorGraph.HasVariable(noiseRate) {
+					continue
+				}
 				var count, sum float64
 				for _, factor := range model.FactorGraph.AdjToVariable(noiseRate) {
 					if ch, ok := factor.(*bsc.BSCPairFactor); ok {
@@ -184,6 +244,10 @@ func (model *MultipleBSCPairModel) EM(maxRounds int, tolerance float64,
 
 			// Update the second layer of noise rates
 			for _, noiseRate := range model.Noise2Rates {
+				if noiseRate.IsObserved() ||
+					!model.FactorGraph.HasVariable(noiseRate) {
+					continue
+				}
 				var count, sum float64
 				for _, factor := range model.FactorGraph.AdjToVariable(noiseRate) {
 					if ch, ok := factor.(*bsc.BSCPairFactor); ok {
@@ -216,6 +280,10 @@ func (model *MultipleBSCPairModel) EM(maxRounds int, tolerance float64,
 
 	model.InputScores = make(map[string]float64)
 	for name, input := range model.Inputs {
+		if input.IsObserved() {
+			model.InputScores[name] = input.Val()
+			continue
+		}
 		input.Set(0)
 		ifFalse := math.Exp(model.FactorGraph.ScoreVar(input))
 		input.Set(1)
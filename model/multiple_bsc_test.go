@@ -0,0 +1,80 @@
+package model
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+func TestMultipleBSCObservedInputs(t *testing.T) {
+	Convey("EM leaves observed inputs and outputs unchanged", t, func() {
+		model := NewMultipleBSCModel()
+		model.AddChannel("a", 0.2)
+		model.AddChannel("b", 0.2)
+		for _, input := range []string{"x", "y", "z"} {
+			model.AddObservation(input, "a", true)
+			model.AddObservation(input, "b", true)
+		}
+		model.ObserveInput("gold", false)
+		model.AddObservation("gold", "a", true)
+		model.AddObservation("gold", "b", false)
+
+		So(model.FactorGraph.ObservedVariables(), ShouldHaveLength, 9)
+		model.EM(10, 1e-6, nil)
+		So(model.Inputs["gold"].Val(), ShouldEqual, 0)
+		So(model.InputScores["gold"], ShouldEqual, 0)
+		So(model.FactorGraph.ObservedVariables(), ShouldHaveLength, 9)
+	})
+}
+
+func TestMultipleBSCRemoveObservation(t *testing.T) {
+	Convey("Withdrawn observations are removed from the model", t, func() {
+		model := NewMultipleBSCModel()
+		model.AddChannel("a", 0.2)
+		model.AddChannel("b", 0.2)
+		model.AddObservation("x", "a", true)
+		model.AddObservation("x", "b", false)
+		model.AddObservation("y", "b", true)
+
+		So(model.RemoveObservation("x", "a"), ShouldEqual, 1)
+		So(model.RemoveObservation("x", "a"), ShouldEqual, 0)
+		So(model.FactorGraph.Factors, ShouldHaveLength, 2)
+		So(model.HasInput("x"), ShouldBeTrue)
+		So(model.FactorGraph.HasVariable(model.Channels["a"].NoiseRate), ShouldBeFalse)
+
+		So(model.RemoveObservation("y", "b"), ShouldEqual, 1)
+		So(model.HasInput("y"), ShouldBeFalse)
+		So(model.RemoveObservation("z", "b"), ShouldEqual, 0)
+
+		model.EM(5, 1e-6, nil)
+		So(model.InputScores, ShouldContainKey, "x")
+	})
+
+	Convey("Withdrawn observations are removed from a pair model", t, func() {
+		model := NewMultipleBSCPairModel()
+		model.AddChannel("a", 0.2, "b", 0.3)
+		model.AddChannel("a", 0.2, "c", 0.3)
+		model.AddObservation("x", "a", "b", true)
+		model.AddObservation("x", "a", "c", true)
+		So(model.RemoveObservation("x", "a", "b"), ShouldEqual, 1)
+		So(model.FactorGraph.Factors, ShouldHaveLength, 1)
+		So(model.RemoveObservation("x", "a", "c"), ShouldEqual, 1)
+		So(model.HasInput("x"), ShouldBeFalse)
+	})
+}
+
+func TestMultipleBSCPairTransitivity(t *testing.T) {
+	Convey("EM respects transitivity constraints", t, func() {
+		model := NewMultipleBSCPairModel()
+		model.AddChannel("a", 0.2, "b", 0.2)
+		model.ObserveInput("x<y", true)
+		model.ObserveInput("y<z", true)
+		model.AddObservation("x<z", "a", "b", false)
+		model.AddTransitivity("x<y", "y<z", "x<z")
+
+		So(model.Score(), ShouldEqual, math.Inf(-1))
+		model.EM(10, 1e-6, nil)
+		So(model.Inputs["x<z"].Val(), ShouldEqual, 1)
+		So(model.Score(), ShouldNotEqual, math.Inf(-1))
+	})
+}
@@ -0,0 +1,49 @@
+package variable
+
+// A Listener is notified after the value of a random variable it subscribes
+// to has changed
+type Listener interface {
+	VariableChanged(v RandomVariable)
+}
+
+// Tracks changes to a random variable's value: a version number, which is
+// incremented on each change, and the listeners to notify. Embedded in
+// ContinuousRV and DiscreteRV.
+type changes struct {
+	version   uint64
+	listeners []Listener
+}
+
+// Get the number of times the variable's value has changed
+func (c changes) Version() uint64 {
+	return c.version
+}
+
+// Notify a listener whenever the variable's value changes. Subscribing the
+// same listener twice has no effect.
+func (c *changes) Subscribe(l Listener) {
+	for _, other := range c.listeners {
+		if other == l {
+			return
+		}
+	}
+	c.listeners = append(c.listeners, l)
+}
+
+// Stop notifying a listener of changes
+func (c *changes) Unsubscribe(l Listener) {
+	for i, other := range c.listeners {
+		if other == l {
+			c.listeners = append(c.listeners[:i], c.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// Record a change to the value of v, and notify its listeners
+func (c *changes) changed(v RandomVariable) {
+	c.version++
+	for _, l := range c.listeners {
+		l.VariableChanged(v)
+	}
+}
@@ -0,0 +1,80 @@
+package variable
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// The most recently assigned variable ID
+var lastID int64
+
+// Return a new ID, unique among the variables created by this process
+func nextID() int64 {
+	return atomic.AddInt64(&lastID, 1)
+}
+
+// Identifying information for a random variable: an integer ID, which is
+// assigned when the variable is created, an optional name, and arbitrary
+// string tags. Embedded in ContinuousRV and DiscreteRV.
+//
+// IDs come from a counter shared by the whole process, so they depend on the
+// order in which variables are created, and are not stable across runs or
+// processes. Use names to identify variables persistently.
+type Metadata struct {
+	id   int64
+	name string
+	tags map[string]string
+}
+
+// Get the variable's ID
+func (m Metadata) ID() int64 {
+	return m.id
+}
+
+// Replace the variable's ID, e.g. to restore a persisted graph. The caller is
+// responsible for keeping IDs unique.
+func (m *Metadata) SetID(id int64) {
+	m.id = id
+}
+
+// Get the variable's name, or the empty string if it has none
+func (m Metadata) Name() string {
+	return m.name
+}
+
+// Set the variable's name
+func (m *Metadata) SetName(name string) {
+	m.name = name
+}
+
+// Get the value of a tag, and whether it is set
+func (m Metadata) Tag(key string) (string, bool) {
+	val, ok := m.tags[key]
+	return val, ok
+}
+
+// Set the value of a tag
+func (m *Metadata) SetTag(key, val string) {
+	if m.tags == nil {
+		m.tags = make(map[string]string)
+	}
+	m.tags[key] = val
+}
+
+// Remove a tag
+func (m *Metadata) RemoveTag(key string) {
+	delete(m.tags, key)
+}
+
+// Get all tags. The map must not be modified.
+func (m Metadata) Tags() map[string]string {
+	return m.tags
+}
+
+// Describe the variable by its name, or by its ID if it has no name
+func (m Metadata) String() string {
+	if m.name != "" {
+		return m.name
+	}
+	return fmt.Sprintf("#%d", m.id)
+}
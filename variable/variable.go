@@ -1,10 +1,14 @@
 package variable
 
 import (
+	"fmt"
+	"github.com/jesand/stats"
 	"github.com/jesand/stats/dist"
 )
 
-// A random variable in a factor graph
+// A random variable in a factor graph. Variables may also support
+// observation, metadata and change notification, through the optional
+// ObservableVariable, IdentifiedVariable and NotifyingVariable interfaces.
 type RandomVariable interface {
 
 	// Get the variable's current value
@@ -17,18 +21,101 @@ type RandomVariable interface {
 	Equals(other RandomVariable) bool
 }
 
+// A random variable which can be clamped to an observed value
+type ObservableVariable interface {
+	RandomVariable
+
+	// Clamp the variable to an observed value. Inference leaves observed
+	// variables unchanged, and Set() panics until Release() is called.
+	Observe(val float64)
+
+	// Ask whether the variable is clamped to an observed value
+	IsObserved() bool
+
+	// Unclamp the variable, making it latent again. Its value is unchanged.
+	Release()
+}
+
+// A random variable with identifying metadata
+type IdentifiedVariable interface {
+	RandomVariable
+
+	// Get the variable's ID, which is unique among the variables created by
+	// this process unless changed with SetID()
+	ID() int64
+
+	// Get the variable's name, or the empty string if it has none
+	Name() string
+
+	// Describe the variable by its name, or by its ID if it has no name
+	String() string
+}
+
+// A random variable which notifies listeners when its value changes
+type NotifyingVariable interface {
+	RandomVariable
+
+	// Get the number of times the variable's value has changed
+	Version() uint64
+
+	// Notify a listener whenever the variable's value changes
+	Subscribe(l Listener)
+
+	// Stop notifying a listener of changes
+	Unsubscribe(l Listener)
+}
+
+// Ask whether a variable is clamped to an observed value. Variables which are
+// not ObservableVariables are never observed.
+func IsObserved(v RandomVariable) bool {
+	ov, ok := v.(ObservableVariable)
+	return ok && ov.IsObserved()
+}
+
+// A random variable which can validate assignments against its space
+type ValidatedVariable interface {
+	RandomVariable
+
+	// Set the variable's current value, or return a *DomainError and leave
+	// the value unchanged if it is not in the variable's space
+	TrySet(val float64) error
+
+	// Ask whether Set() validates its values
+	IsStrict() bool
+
+	// Choose whether Set() validates its values. In strict mode, Set()
+	// panics with a *DomainError when given a value outside the space.
+	SetStrict(strict bool)
+}
+
+// An error indicating an attempt to assign a value outside a variable's space
+type DomainError struct {
+	Value float64
+	Space dist.Space
+}
+
+// Return the error message
+func (err *DomainError) Error() string {
+	return fmt.Sprintf("Value %v not in the sample space %#v", err.Value, err.Space)
+}
+
 // Create a new continuous random variable
 func NewContinuousRV(val float64, space dist.RealSpace) *ContinuousRV {
 	return &ContinuousRV{
-		val:   val,
-		space: space,
+		Metadata: Metadata{id: nextID()},
+		val:      val,
+		space:    space,
 	}
 }
 
 // A continuous random variable
 type ContinuousRV struct {
-	val   float64
-	space dist.RealSpace
+	Metadata
+	changes
+	val      float64
+	space    dist.RealSpace
+	strict   bool
+	observed bool
 }
 
 func (rv ContinuousRV) Val() float64 {
@@ -36,7 +123,54 @@ func (rv ContinuousRV) Val() float64 {
 }
 
 func (rv *ContinuousRV) Set(val float64) {
-	rv.val = val
+	if rv.observed {
+		panic(stats.ErrObserved)
+	} else if rv.strict && !rv.space.Contains(val) {
+		panic(&DomainError{Value: val, Space: rv.space})
+	}
+	rv.setVal(val)
+}
+
+func (rv *ContinuousRV) TrySet(val float64) error {
+	if rv.observed {
+		return stats.ErrObserved
+	} else if !rv.space.Contains(val) {
+		return &DomainError{Value: val, Space: rv.space}
+	}
+	rv.setVal(val)
+	return nil
+}
+
+// Assign a new value, notifying listeners if it differs from the old one
+func (rv *ContinuousRV) setVal(val float64) {
+	if val != rv.val {
+		rv.val = val
+		rv.changed(rv)
+	}
+}
+
+func (rv ContinuousRV) IsStrict() bool {
+	return rv.strict
+}
+
+func (rv *ContinuousRV) SetStrict(strict bool) {
+	rv.strict = strict
+}
+
+func (rv *ContinuousRV) Observe(val float64) {
+	if !rv.space.Contains(val) {
+		panic(&DomainError{Value: val, Space: rv.space})
+	}
+	rv.setVal(val)
+	rv.observed = true
+}
+
+func (rv ContinuousRV) IsObserved() bool {
+	return rv.observed
+}
+
+func (rv *ContinuousRV) Release() {
+	rv.observed = false
 }
 
 func (rv ContinuousRV) Equals(other RandomVariable) bool {
@@ -54,15 +188,20 @@ func (rv ContinuousRV) Space() dist.RealSpace {
 // Create a new discrete random variable
 func NewDiscreteRV(val dist.Outcome, space dist.DiscreteRealSpace) *DiscreteRV {
 	return &DiscreteRV{
-		val:   val,
-		space: space,
+		Metadata: Metadata{id: nextID()},
+		val:      val,
+		space:    space,
 	}
 }
 
 // A discrete random variable
 type DiscreteRV struct {
-	val   dist.Outcome
-	space dist.DiscreteRealSpace
+	Metadata
+	changes
+	val      dist.Outcome
+	space    dist.DiscreteRealSpace
+	strict   bool
+	observed bool
 }
 
 func (rv DiscreteRV) Val() float64 {
@@ -74,11 +213,75 @@ func (rv DiscreteRV) Outcome() dist.Outcome {
 }
 
 func (rv *DiscreteRV) Set(val float64) {
-	rv.val = rv.space.Outcome(val)
+	if rv.observed {
+		panic(stats.ErrObserved)
+	} else if rv.strict && !rv.space.Contains(val) {
+		panic(&DomainError{Value: val, Space: rv.space})
+	}
+	rv.setOutcome(rv.space.Outcome(val))
+}
+
+func (rv *DiscreteRV) TrySet(val float64) error {
+	if rv.observed {
+		return stats.ErrObserved
+	} else if !rv.space.Contains(val) {
+		return &DomainError{Value: val, Space: rv.space}
+	}
+	rv.setOutcome(rv.space.Outcome(val))
+	return nil
 }
 
 func (rv *DiscreteRV) SetOutcome(val dist.Outcome) {
-	rv.val = val
+	if rv.observed {
+		panic(stats.ErrObserved)
+	} else if rv.strict && !rv.hasOutcome(val) {
+		panic(&DomainError{Value: float64(val), Space: rv.space})
+	}
+	rv.setOutcome(val)
+}
+
+// Assign a new outcome, notifying listeners if it differs from the old one
+func (rv *DiscreteRV) setOutcome(val dist.Outcome) {
+	if val != rv.val {
+		rv.val = val
+		rv.changed(rv)
+	}
+}
+
+func (rv DiscreteRV) hasOutcome(val dist.Outcome) bool {
+	return val >= 0 && (rv.space.Size() < 0 || int(val) < rv.space.Size())
+}
+
+func (rv DiscreteRV) IsStrict() bool {
+	return rv.strict
+}
+
+func (rv *DiscreteRV) SetStrict(strict bool) {
+	rv.strict = strict
+}
+
+func (rv *DiscreteRV) Observe(val float64) {
+	if !rv.space.Contains(val) {
+		panic(&DomainError{Value: val, Space: rv.space})
+	}
+	rv.setOutcome(rv.space.Outcome(val))
+	rv.observed = true
+}
+
+func (rv *DiscreteRV) ObserveOutcome(val dist.Outcome) {
+	if !rv.hasOutcome(val) {
+		panic(&DomainError{Value: float64(val), Space: rv.space})
+	}
+	rv.setOutcome(val)
+	rv.observed = true
+}
+
+func (rv DiscreteRV) IsObserved() bool {
+	return rv.observed
+}
+
+func (rv *DiscreteRV) Release() {
+	rv.observed = false
 }
 
 func (rv DiscreteRV) Equals(other RandomVariable) bool {
@@ -1,6 +1,8 @@
 package variable
 
 import (
+	"fmt"
+	"github.com/jesand/stats"
 	"github.com/jesand/stats/dist"
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
@@ -9,6 +11,10 @@ import (
 func TestContinuousRV(t *testing.T) {
 	Convey("Test ContinuousRV interfaces", t, func() {
 		So(ContinuousRV{}, ShouldImplement, (*RandomVariable)(nil))
+		So(&ContinuousRV{}, ShouldImplement, (*ObservableVariable)(nil))
+		So(&ContinuousRV{}, ShouldImplement, (*IdentifiedVariable)(nil))
+		So(&ContinuousRV{}, ShouldImplement, (*NotifyingVariable)(nil))
+		So(&ContinuousRV{}, ShouldImplement, (*ValidatedVariable)(nil))
 	})
 
 	Convey("Test ContinuousRV", t, func() {
@@ -24,6 +30,10 @@ func TestContinuousRV(t *testing.T) {
 func TestDiscreteRV(t *testing.T) {
 	Convey("Test DiscreteRV interfaces", t, func() {
 		So(DiscreteRV{}, ShouldImplement, (*RandomVariable)(nil))
+		So(&DiscreteRV{}, ShouldImplement, (*ObservableVariable)(nil))
+		So(&DiscreteRV{}, ShouldImplement, (*IdentifiedVariable)(nil))
+		So(&DiscreteRV{}, ShouldImplement, (*NotifyingVariable)(nil))
+		So(&DiscreteRV{}, ShouldImplement, (*ValidatedVariable)(nil))
 	})
 
 	Convey("Test DiscreteRV", t, func() {
@@ -39,3 +49,157 @@ func TestDiscreteRV(t *testing.T) {
 		So(rv.Outcome(), ShouldEqual, 1)
 	})
 }
+
+func TestValidatedAssignment(t *testing.T) {
+	Convey("Test ValidatedVariable interfaces", t, func() {
+		So(&ContinuousRV{}, ShouldImplement, (*ValidatedVariable)(nil))
+		So(&DiscreteRV{}, ShouldImplement, (*ValidatedVariable)(nil))
+	})
+
+	Convey("Test ContinuousRV validation", t, func() {
+		rv := NewContinuousRV(0.5, dist.UnitIntervalSpace)
+		So(rv.IsStrict(), ShouldBeFalse)
+		rv.Set(1.7)
+		So(rv.Val(), ShouldEqual, 1.7)
+
+		err := rv.TrySet(0.2)
+		So(err, ShouldBeNil)
+		So(rv.Val(), ShouldEqual, 0.2)
+
+		err = rv.TrySet(1.7)
+		So(err, ShouldHaveSameTypeAs, (*DomainError)(nil))
+		So(err.(*DomainError).Value, ShouldEqual, 1.7)
+		So(rv.Val(), ShouldEqual, 0.2)
+
+		rv.SetStrict(true)
+		So(rv.IsStrict(), ShouldBeTrue)
+		func() {
+			defer func() {
+				err, ok := recover().(*DomainError)
+				So(ok, ShouldBeTrue)
+				So(err.Value, ShouldEqual, 1.7)
+				So(err.Space, ShouldResemble, dist.UnitIntervalSpace)
+			}()
+			rv.Set(1.7)
+		}()
+		So(rv.Val(), ShouldEqual, 0.2)
+		rv.Set(0.9)
+		So(rv.Val(), ShouldEqual, 0.9)
+	})
+
+	Convey("Test DiscreteRV validation", t, func() {
+		rv := NewDiscreteRV(0, dist.BooleanSpace)
+		So(rv.TrySet(2), ShouldHaveSameTypeAs, (*DomainError)(nil))
+		So(rv.Val(), ShouldEqual, 0)
+		So(rv.TrySet(1), ShouldBeNil)
+		So(rv.Val(), ShouldEqual, 1)
+
+		rv.SetStrict(true)
+		So(func() { rv.Set(0.5) }, ShouldPanic)
+		So(func() { rv.SetOutcome(2) }, ShouldPanic)
+		rv.SetOutcome(0)
+		So(rv.Outcome(), ShouldEqual, 0)
+	})
+}
+
+func TestObservedVariables(t *testing.T) {
+	Convey("Test ContinuousRV observation", t, func() {
+		rv := NewContinuousRV(0.5, dist.UnitIntervalSpace)
+		So(rv.IsObserved(), ShouldBeFalse)
+		rv.Observe(0.25)
+		So(rv.IsObserved(), ShouldBeTrue)
+		So(rv.Val(), ShouldEqual, 0.25)
+		So(func() { rv.Set(0.3) }, ShouldPanicWith, stats.ErrObserved)
+		So(rv.TrySet(0.3), ShouldEqual, stats.ErrObserved)
+		So(rv.Val(), ShouldEqual, 0.25)
+		So(func() { rv.Observe(1.5) }, ShouldPanic)
+
+		rv.Release()
+		So(rv.IsObserved(), ShouldBeFalse)
+		So(rv.Val(), ShouldEqual, 0.25)
+		rv.Set(0.3)
+		So(rv.Val(), ShouldEqual, 0.3)
+	})
+
+	Convey("Test DiscreteRV observation", t, func() {
+		rv := NewDiscreteRV(0, dist.BooleanSpace)
+		rv.Observe(1)
+		So(rv.IsObserved(), ShouldBeTrue)
+		So(rv.Outcome(), ShouldEqual, 1)
+		So(func() { rv.Set(0) }, ShouldPanicWith, stats.ErrObserved)
+		So(func() { rv.SetOutcome(0) }, ShouldPanicWith, stats.ErrObserved)
+		So(func() { rv.ObserveOutcome(2) }, ShouldPanic)
+		rv.ObserveOutcome(0)
+		So(rv.Outcome(), ShouldEqual, 0)
+		rv.Release()
+		rv.SetOutcome(1)
+		So(rv.Outcome(), ShouldEqual, 1)
+	})
+}
+
+func TestVariableMetadata(t *testing.T) {
+	Convey("Test variable IDs", t, func() {
+		x := NewContinuousRV(0, dist.AllRealSpace)
+		y := NewDiscreteRV(0, dist.BooleanSpace)
+		So(x.ID(), ShouldBeGreaterThan, 0)
+		So(y.ID(), ShouldBeGreaterThan, x.ID())
+		y.SetID(42)
+		So(y.ID(), ShouldEqual, 42)
+	})
+
+	Convey("Test variable names and tags", t, func() {
+		x := NewContinuousRV(0, dist.AllRealSpace)
+		So(x.Name(), ShouldEqual, "")
+		So(x.String(), ShouldEqual, fmt.Sprintf("#%d", x.ID()))
+		x.SetName("bias")
+		So(x.Name(), ShouldEqual, "bias")
+		So(fmt.Sprint(x), ShouldEqual, "bias")
+
+		_, ok := x.Tag("item")
+		So(ok, ShouldBeFalse)
+		x.SetTag("item", "q17")
+		val, ok := x.Tag("item")
+		So(ok, ShouldBeTrue)
+		So(val, ShouldEqual, "q17")
+		So(x.Tags(), ShouldResemble, map[string]string{"item": "q17"})
+		x.RemoveTag("item")
+		So(x.Tags(), ShouldBeEmpty)
+	})
+}
+
+// A Listener which records the variables it is notified about
+type recordingListener struct {
+	changed []RandomVariable
+}
+
+func (l *recordingListener) VariableChanged(v RandomVariable) {
+	l.changed = append(l.changed, v)
+}
+
+func TestVariableListeners(t *testing.T) {
+	Convey("Test change notifications", t, func() {
+		var (
+			x = NewContinuousRV(0, dist.UnitIntervalSpace)
+			y = NewDiscreteRV(0, dist.BooleanSpace)
+			l = &recordingListener{}
+		)
+		x.Subscribe(l)
+		x.Subscribe(l)
+		y.Subscribe(l)
+
+		x.Set(0.5)
+		x.Set(0.5)
+		So(x.TrySet(2), ShouldNotBeNil)
+		y.SetOutcome(1)
+		x.Observe(0.25)
+		So(l.changed, ShouldResemble, []RandomVariable{x, y, x})
+		So(x.Version(), ShouldEqual, 2)
+		So(y.Version(), ShouldEqual, 1)
+
+		x.Unsubscribe(l)
+		x.Release()
+		x.Set(0.75)
+		So(l.changed, ShouldHaveLength, 3)
+		So(x.Version(), ShouldEqual, 3)
+	})
+}